@@ -1,17 +1,25 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
+	"time"
 
 	"github.com/praneethys/kafka-bullmq-benchmark/pkg/common"
-	"github.com/praneethys/kafka-bullmq-benchmark/pkg/kafka"
 	"github.com/praneethys/kafka-bullmq-benchmark/pkg/metrics"
-	"github.com/praneethys/kafka-bullmq-benchmark/pkg/redis"
+
+	// Backends register themselves with the common registry when linked in;
+	// adding a blank import here is all it takes to benchmark another queue
+	_ "github.com/praneethys/kafka-bullmq-benchmark/pkg/bullmq"
+	_ "github.com/praneethys/kafka-bullmq-benchmark/pkg/kafka"
+	_ "github.com/praneethys/kafka-bullmq-benchmark/pkg/memory"
+	_ "github.com/praneethys/kafka-bullmq-benchmark/pkg/redis"
 )
 
 func main() {
@@ -21,69 +29,124 @@ func main() {
 	producers := flag.Int("producers", 10, "Number of producer goroutines")
 	consumers := flag.Int("consumers", 10, "Number of consumer goroutines")
 	duration := flag.Int("duration", 300, "Maximum duration in seconds")
-	queueType := flag.String("queue", "both", "Queue type to test: kafka, redis, or both")
-	kafkaBrokers := flag.String("kafka-brokers", "localhost:9092", "Kafka broker addresses")
-	kafkaTopic := flag.String("kafka-topic", "benchmark-topic", "Kafka topic name")
-	redisAddr := flag.String("redis-addr", "localhost:6379", "Redis server address")
-	redisStream := flag.String("redis-stream", "benchmark-stream", "Redis stream key")
+	idleTimeout := flag.Int("idle-timeout", 30,
+		"Stop waiting once producers are done and no message has arrived for this many seconds (0 disables)")
+	maxAttempts := flag.Int("max-attempts", 1, "Handler calls per message before it is dead-lettered")
+	retryBackoff := flag.Duration("retry-backoff", 100*time.Millisecond, "Wait before the first handler retry, doubled for each further one")
+	failRate := flag.Float64("fail-rate", 0, "Probability (0-1) that a handler call fails, to exercise retries and dead-lettering")
+	batchSize := flag.Int("batch", 1, "Messages per produce call (values > 1 use batch produce)")
+	keyCount := flag.Int("keys", 0, "Distinct message keys per producer for per-key ordering checks (0 sends unkeyed messages)")
+	headerList := flag.String("headers", "",
+		"Comma-separated key=value headers added to every message; a value of "+common.HeaderValueUUID+" is unique per message")
+	codecName := flag.String("codec", "json",
+		"Wire format for broker backends ("+strings.Join(common.CodecNames(), ", ")+")")
+	queueList := flag.String("queue", "kafka,redis",
+		"Comma-separated backends to test, \"all\", or \"both\" for kafka,redis (available: "+strings.Join(common.BackendNames(), ", ")+")")
+	mode := flag.String("mode", common.ModeFull,
+		"Benchmark to run: "+common.ModeFull+" (produce and consume concurrently) or "+
+			common.ModeReplay+" (produce first, then read the history with a fresh consumer)")
 	outputDir := flag.String("output", "./results", "Output directory for results")
 
+	// Backend-specific flags (addresses, topics, ...)
+	for _, backend := range common.Backends() {
+		backend.RegisterFlags(flag.CommandLine)
+	}
+
 	flag.Parse()
 
+	backends, err := common.ParseBackendList(*queueList)
+	if err != nil {
+		log.Fatalf("Invalid -queue value: %v", err)
+	}
+
+	headers, err := parseHeaders(*headerList)
+	if err != nil {
+		log.Fatalf("Invalid -headers value: %v", err)
+	}
+
+	if _, err := common.LookupCodec(*codecName); err != nil {
+		log.Fatalf("Invalid -codec value: %v", err)
+	}
+
+	if *mode != common.ModeFull && *mode != common.ModeReplay {
+		log.Fatalf("Invalid -mode value %q: expected %s or %s", *mode, common.ModeFull, common.ModeReplay)
+	}
+
 	// Create output directory
 	if err := os.MkdirAll(*outputDir, 0o755); err != nil {
 		log.Fatalf("Failed to create output directory: %v", err)
 	}
 
-	// Setup signal handling
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	// Setup signal handling: an interrupt stops the running benchmark and
+	// still writes the report for whatever completed
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
 	config := &common.BenchmarkConfig{
-		MessageCount:    *messageCount,
-		MessageSize:     *messageSize,
-		ProducerCount:   *producers,
-		ConsumerCount:   *consumers,
-		DurationSeconds: *duration,
+		MessageCount:       *messageCount,
+		MessageSize:        *messageSize,
+		ProducerCount:      *producers,
+		ConsumerCount:      *consumers,
+		BatchSize:          *batchSize,
+		KeyCount:           *keyCount,
+		DurationSeconds:    *duration,
+		IdleTimeoutSeconds: *idleTimeout,
+		Headers:            headers,
+		Codec:              *codecName,
+		Retry: common.RetryPolicy{
+			MaxAttempts: *maxAttempts,
+			Backoff:     *retryBackoff,
+			MaxBackoff:  10 * time.Second,
+		},
+		FailureRate: *failRate,
+		Mode:        *mode,
 	}
 
 	fmt.Println("Kafka vs BullMQ (Redis Streams) Benchmark")
 	fmt.Println("==========================================")
 	fmt.Printf("Configuration:\n")
+	fmt.Printf("  Backends:       %s\n", *queueList)
+	fmt.Printf("  Mode:           %s\n", config.Mode)
 	fmt.Printf("  Messages:       %d\n", config.MessageCount)
 	fmt.Printf("  Message Size:   %d bytes\n", config.MessageSize)
 	fmt.Printf("  Producers:      %d\n", config.ProducerCount)
 	fmt.Printf("  Consumers:      %d\n", config.ConsumerCount)
+	fmt.Printf("  Batch Size:     %d\n", config.BatchSize)
+	fmt.Printf("  Codec:          %s\n", config.Codec)
+	if config.KeyCount > 0 {
+		fmt.Printf("  Keys:           %d per producer\n", config.KeyCount)
+	}
 	fmt.Printf("  Max Duration:   %d seconds\n", config.DurationSeconds)
+	if config.IdleTimeoutSeconds > 0 {
+		fmt.Printf("  Idle Timeout:   %d seconds\n", config.IdleTimeoutSeconds)
+	}
+	if len(config.Headers) > 0 {
+		fmt.Printf("  Headers:        %s\n", *headerList)
+	}
+	if config.FailureRate > 0 {
+		fmt.Printf("  Failure Rate:   %.2f%% (max %d attempts)\n", config.FailureRate*100, config.Retry.MaxAttempts)
+	}
 	fmt.Println()
 
 	var results []*common.BenchmarkResult
 
-	// Run Kafka benchmark
-	if *queueType == "kafka" || *queueType == "both" {
-		fmt.Println("Starting Kafka benchmark...")
-		kafkaResult, err := runKafkaBenchmark(config, *kafkaBrokers, *kafkaTopic)
-		if err != nil {
-			log.Printf("Kafka benchmark failed: %v", err)
-		} else {
-			results = append(results, kafkaResult)
-			metrics.PrintResults(kafkaResult)
+	for _, backend := range backends {
+		if ctx.Err() != nil {
+			break
 		}
-	}
 
-	// Run Redis benchmark
-	if *queueType == "redis" || *queueType == "both" {
-		fmt.Println("Starting Redis (BullMQ) benchmark...")
-		redisResult, err := runRedisBenchmark(config, *redisAddr, *redisStream)
+		fmt.Printf("Starting %s benchmark...\n", backend.Name())
+		result, err := runBenchmark(ctx, config, backend)
 		if err != nil {
-			log.Printf("Redis benchmark failed: %v", err)
-		} else {
-			results = append(results, redisResult)
-			metrics.PrintResults(redisResult)
+			log.Printf("%s benchmark failed: %v", backend.Name(), err)
+			continue
 		}
+
+		results = append(results, result)
+		metrics.PrintResults(result)
 	}
 
-	// Compare results if both were run
+	// Compare results if more than one backend was run
 	if len(results) > 1 {
 		metrics.CompareResults(results)
 	}
@@ -96,44 +159,58 @@ func main() {
 	fmt.Println("Benchmark completed successfully!")
 }
 
-func runKafkaBenchmark(config *common.BenchmarkConfig, brokers, topic string) (*common.BenchmarkResult, error) {
-	// Create Kafka producer queue
-	producerQueue, err := kafka.NewKafkaQueue(brokers, topic, "benchmark-producer-group")
-	if err != nil {
-		return nil, fmt.Errorf("failed to create Kafka producer: %w", err)
+// parseHeaders parses a comma-separated list of key=value pairs
+func parseHeaders(list string) (map[string]string, error) {
+	if strings.TrimSpace(list) == "" {
+		return nil, nil
 	}
-	defer func() {
-		if closeErr := producerQueue.Close(); closeErr != nil {
-			log.Printf("Error closing producer queue: %v", closeErr)
-		}
-	}()
 
-	// Create Kafka consumer queue
-	consumerQueue, err := kafka.NewKafkaQueue(brokers, topic, "benchmark-consumer-group")
-	if err != nil {
-		return nil, fmt.Errorf("failed to create Kafka consumer: %w", err)
-	}
-	defer func() {
-		if closeErr := consumerQueue.Close(); closeErr != nil {
-			log.Printf("Error closing consumer queue: %v", closeErr)
+	headers := make(map[string]string)
+	for _, pair := range strings.Split(list, ",") {
+		key, value, ok := strings.Cut(pair, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("expected key=value, got %q", pair)
 		}
-	}()
-
-	// Run benchmark
-	benchmark := metrics.NewBenchmark(config)
-	result, err := benchmark.RunFullBenchmark(producerQueue, consumerQueue)
-	if err != nil {
-		return nil, fmt.Errorf("benchmark failed: %w", err)
+		headers[key] = strings.TrimSpace(value)
 	}
 
-	return result, nil
+	return headers, nil
 }
 
-func runRedisBenchmark(config *common.BenchmarkConfig, addr, streamKey string) (*common.BenchmarkResult, error) {
-	// Create Redis producer queue
-	producerQueue, err := redis.NewRedisQueue(addr, streamKey, "benchmark-group", "producer")
+// runBenchmark runs the benchmark selected by config.Mode against one
+// backend, using a separate queue instance for each role
+func runBenchmark(ctx context.Context, config *common.BenchmarkConfig, backend common.Backend) (*common.BenchmarkResult, error) {
+	var codec common.Codec
+	if config.Codec != "" {
+		var err error
+		if codec, err = common.LookupCodec(config.Codec); err != nil {
+			return nil, err
+		}
+	}
+
+	// Let the backend create its topics first; cleanup is deferred before
+	// the queues' Close so it runs after them
+	if provisioner, ok := backend.(common.Provisioner); ok {
+		provisionCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		err := provisioner.Provision(provisionCtx)
+		cancel()
+		if err != nil {
+			return nil, fmt.Errorf("failed to provision %s: %w", backend.Name(), err)
+		}
+		defer func() {
+			cleanupCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			if err := provisioner.Cleanup(cleanupCtx); err != nil {
+				log.Printf("Error cleaning up %s: %v", backend.Name(), err)
+			}
+		}()
+	}
+
+	// Create producer queue
+	producerQueue, err := backend.NewQueue(common.BackendOptions{Role: common.RoleProducer, Codec: codec, Retry: config.Retry})
 	if err != nil {
-		return nil, fmt.Errorf("failed to create Redis producer: %w", err)
+		return nil, fmt.Errorf("failed to create %s producer: %w", backend.Name(), err)
 	}
 	defer func() {
 		if closeErr := producerQueue.Close(); closeErr != nil {
@@ -141,10 +218,23 @@ func runRedisBenchmark(config *common.BenchmarkConfig, addr, streamKey string) (
 		}
 	}()
 
-	// Create Redis consumer queue
-	consumerQueue, err := redis.NewRedisQueue(addr, streamKey, "benchmark-group", "consumer")
+	benchmark := metrics.NewBenchmark(config)
+
+	// The replay consumer is created once the history has been written
+	if config.Mode == common.ModeReplay {
+		result, err := benchmark.RunReplayBenchmark(ctx, producerQueue, func() (common.MessageQueue, error) {
+			return backend.NewQueue(common.BackendOptions{Role: common.RoleConsumer, Codec: codec, Retry: config.Retry, Replay: true})
+		})
+		if err != nil {
+			return nil, fmt.Errorf("benchmark failed: %w", err)
+		}
+		return result, nil
+	}
+
+	// Create consumer queue
+	consumerQueue, err := backend.NewQueue(common.BackendOptions{Role: common.RoleConsumer, Codec: codec, Retry: config.Retry})
 	if err != nil {
-		return nil, fmt.Errorf("failed to create Redis consumer: %w", err)
+		return nil, fmt.Errorf("failed to create %s consumer: %w", backend.Name(), err)
 	}
 	defer func() {
 		if closeErr := consumerQueue.Close(); closeErr != nil {
@@ -153,8 +243,7 @@ func runRedisBenchmark(config *common.BenchmarkConfig, addr, streamKey string) (
 	}()
 
 	// Run benchmark
-	benchmark := metrics.NewBenchmark(config)
-	result, err := benchmark.RunFullBenchmark(producerQueue, consumerQueue)
+	result, err := benchmark.RunFullBenchmark(ctx, producerQueue, consumerQueue)
 	if err != nil {
 		return nil, fmt.Errorf("benchmark failed: %w", err)
 	}
@@ -1,26 +1,49 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/praneethys/kafka-bullmq-benchmark/pkg/common"
+	"github.com/praneethys/kafka-bullmq-benchmark/pkg/kafka/kafkatest"
 )
 
-func skipIfNoKafka(t *testing.T) {
-	if os.Getenv("KAFKA_TEST") != "true" {
-		t.Skip("Skipping Kafka integration test. Set KAFKA_TEST=true to run.")
-	}
-}
-
 func skipIfNoRedis(t *testing.T) {
 	if os.Getenv("REDIS_TEST") != "true" {
 		t.Skip("Skipping Redis integration test. Set REDIS_TEST=true to run.")
 	}
 }
 
+// newTestBackend looks up a registered backend and configures it from args,
+// the same way main configures it from the command line. Every backend's
+// flags are registered, since backends such as kafka-txn share the flags of
+// another.
+func newTestBackend(t *testing.T, name string, args ...string) common.Backend {
+	t.Helper()
+
+	backend, err := common.LookupBackend(name)
+	if err != nil {
+		t.Fatalf("Backend %s not registered: %v", name, err)
+	}
+
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	for _, b := range common.Backends() {
+		b.RegisterFlags(fs)
+	}
+	if err := fs.Parse(args); err != nil {
+		t.Fatalf("Failed to parse %s flags: %v", name, err)
+	}
+
+	return backend
+}
+
 func TestRunKafkaBenchmark(t *testing.T) {
-	skipIfNoKafka(t)
+	brokers := kafkatest.Brokers(t)
 
 	config := &common.BenchmarkConfig{
 		MessageCount:    100,
@@ -30,12 +53,11 @@ func TestRunKafkaBenchmark(t *testing.T) {
 		DurationSeconds: 30,
 	}
 
-	brokers := "localhost:9092"
 	topic := "test-benchmark-kafka"
 
-	result, err := runKafkaBenchmark(config, brokers, topic)
+	result, err := runBenchmark(context.Background(), config, newTestBackend(t, "kafka", "-kafka-brokers", brokers, "-kafka-topic", topic))
 	if err != nil {
-		t.Fatalf("runKafkaBenchmark failed: %v", err)
+		t.Fatalf("runBenchmark(kafka) failed: %v", err)
 	}
 
 	if result == nil {
@@ -59,6 +81,43 @@ func TestRunKafkaBenchmark(t *testing.T) {
 	}
 }
 
+func TestRunKafkaReplayBenchmark(t *testing.T) {
+	brokers := kafkatest.Brokers(t)
+
+	config := &common.BenchmarkConfig{
+		MessageCount:       200,
+		MessageSize:        512,
+		ProducerCount:      2,
+		ConsumerCount:      2,
+		DurationSeconds:    30,
+		IdleTimeoutSeconds: 10,
+		Mode:               common.ModeReplay,
+	}
+
+	backend := newTestBackend(t, "kafka", "-kafka-brokers", brokers, "-kafka-topic", "test-benchmark-kafka-replay")
+
+	// A second replay reads the first one's history too, from a new group
+	for run := 1; run <= 2; run++ {
+		result, err := runBenchmark(context.Background(), config, backend)
+		if err != nil {
+			t.Fatalf("runBenchmark(kafka replay) run %d failed: %v", run, err)
+		}
+
+		if result.DeliveredCount != config.MessageCount || result.LostCount != 0 {
+			t.Errorf("Run %d: expected all %d messages replayed, got %d delivered and %d lost",
+				run, config.MessageCount, result.DeliveredCount, result.LostCount)
+		}
+
+		if want := (run - 1) * config.MessageCount; result.UnexpectedCount != want {
+			t.Errorf("Run %d: expected %d messages of earlier runs, got %d", run, want, result.UnexpectedCount)
+		}
+
+		if result.FirstMessageTime <= 0 {
+			t.Errorf("Run %d: expected the time to the first message, got %v", run, result.FirstMessageTime)
+		}
+	}
+}
+
 func TestRunKafkaBenchmarkInvalidBroker(t *testing.T) {
 	// This test verifies that we can create Kafka queues with invalid broker
 	// (they don't fail immediately), but the benchmark will eventually timeout
@@ -76,7 +135,7 @@ func TestRunKafkaBenchmarkInvalidBroker(t *testing.T) {
 	brokers := "invalid:9999"
 	topic := "test-topic"
 
-	result, err := runKafkaBenchmark(config, brokers, topic)
+	result, err := runBenchmark(context.Background(), config, newTestBackend(t, "kafka", "-kafka-brokers", brokers, "-kafka-topic", topic))
 	// The benchmark may complete but with errors or no messages processed
 	if err == nil && result != nil {
 		// Verify no messages were successfully processed
@@ -100,17 +159,17 @@ func TestRunRedisBenchmark(t *testing.T) {
 	addr := "localhost:6379"
 	streamKey := "test-benchmark-redis"
 
-	result, err := runRedisBenchmark(config, addr, streamKey)
+	result, err := runBenchmark(context.Background(), config, newTestBackend(t, "redis", "-redis-addr", addr, "-redis-stream", streamKey))
 	if err != nil {
-		t.Fatalf("runRedisBenchmark failed: %v", err)
+		t.Fatalf("runBenchmark(redis) failed: %v", err)
 	}
 
 	if result == nil {
 		t.Fatal("Expected non-nil result")
 	}
 
-	if result.QueueType != "Redis Streams (BullMQ)" {
-		t.Errorf("Expected QueueType 'Redis Streams (BullMQ)', got '%s'", result.QueueType)
+	if result.QueueType != "Redis Streams" {
+		t.Errorf("Expected QueueType 'Redis Streams', got '%s'", result.QueueType)
 	}
 
 	if result.MessageCount != config.MessageCount {
@@ -124,6 +183,61 @@ func TestRunRedisBenchmark(t *testing.T) {
 	if result.Duration <= 0 {
 		t.Error("Expected positive duration")
 	}
+
+	// Members of earlier runs stay in the group but are not listed
+	if len(result.GroupMembers) != config.ConsumerCount {
+		t.Errorf("Expected a group member per consumer goroutine, got %+v", result.GroupMembers)
+	}
+}
+
+func TestRunBullMQBenchmark(t *testing.T) {
+	skipIfNoRedis(t)
+
+	config := &common.BenchmarkConfig{
+		MessageCount:    100,
+		MessageSize:     512,
+		ProducerCount:   2,
+		ConsumerCount:   2,
+		DurationSeconds: 30,
+	}
+
+	queueName := fmt.Sprintf("test-benchmark-bullmq-%d", time.Now().UnixNano())
+	result, err := runBenchmark(context.Background(), config, newTestBackend(t, "bullmq", "-bullmq-addr", "localhost:6379", "-bullmq-queue", queueName))
+	if err != nil {
+		t.Fatalf("runBenchmark(bullmq) failed: %v", err)
+	}
+
+	if result.QueueType != "BullMQ" {
+		t.Errorf("Expected QueueType 'BullMQ', got '%s'", result.QueueType)
+	}
+
+	if result.DeliveredCount != config.MessageCount || result.DuplicateCount != 0 {
+		t.Errorf("Expected each of %d jobs processed once, got %d delivered and %d duplicates",
+			config.MessageCount, result.DeliveredCount, result.DuplicateCount)
+	}
+}
+
+func TestRunRedisReplayBenchmark(t *testing.T) {
+	skipIfNoRedis(t)
+
+	config := &common.BenchmarkConfig{
+		MessageCount:    100,
+		MessageSize:     512,
+		ProducerCount:   2,
+		ConsumerCount:   2,
+		DurationSeconds: 30,
+		Mode:            common.ModeReplay,
+	}
+
+	streamKey := fmt.Sprintf("test-benchmark-redis-replay-%d", time.Now().UnixNano())
+	result, err := runBenchmark(context.Background(), config, newTestBackend(t, "redis", "-redis-addr", "localhost:6379", "-redis-stream", streamKey))
+	if err != nil {
+		t.Fatalf("runBenchmark(redis replay) failed: %v", err)
+	}
+
+	if result.DeliveredCount != config.MessageCount {
+		t.Errorf("Expected %d replayed messages, got %d", config.MessageCount, result.DeliveredCount)
+	}
 }
 
 func TestRunRedisBenchmarkInvalidAddr(t *testing.T) {
@@ -138,14 +252,14 @@ func TestRunRedisBenchmarkInvalidAddr(t *testing.T) {
 	addr := "invalid:9999"
 	streamKey := "test-stream"
 
-	_, err := runRedisBenchmark(config, addr, streamKey)
+	_, err := runBenchmark(context.Background(), config, newTestBackend(t, "redis", "-redis-addr", addr, "-redis-stream", streamKey))
 	if err == nil {
 		t.Error("Expected error for invalid address, got nil")
 	}
 }
 
 func TestRunKafkaBenchmarkSmallLoad(t *testing.T) {
-	skipIfNoKafka(t)
+	brokers := kafkatest.Brokers(t)
 
 	if testing.Short() {
 		t.Skip("Skipping small load test in short mode")
@@ -159,12 +273,11 @@ func TestRunKafkaBenchmarkSmallLoad(t *testing.T) {
 		DurationSeconds: 30,
 	}
 
-	brokers := "localhost:9092"
 	topic := "test-benchmark-kafka-small"
 
-	result, err := runKafkaBenchmark(config, brokers, topic)
+	result, err := runBenchmark(context.Background(), config, newTestBackend(t, "kafka", "-kafka-brokers", brokers, "-kafka-topic", topic))
 	if err != nil {
-		t.Fatalf("runKafkaBenchmark small load failed: %v", err)
+		t.Fatalf("runBenchmark(kafka) small load failed: %v", err)
 	}
 
 	if result.SuccessCount != config.MessageCount {
@@ -232,9 +345,9 @@ func TestRunRedisBenchmarkSmallLoad(t *testing.T) {
 	addr := "localhost:6379"
 	streamKey := "test-benchmark-redis-small"
 
-	result, err := runRedisBenchmark(config, addr, streamKey)
+	result, err := runBenchmark(context.Background(), config, newTestBackend(t, "redis", "-redis-addr", addr, "-redis-stream", streamKey))
 	if err != nil {
-		t.Fatalf("runRedisBenchmark small load failed: %v", err)
+		t.Fatalf("runBenchmark(redis) small load failed: %v", err)
 	}
 
 	if result.SuccessCount != config.MessageCount {
@@ -273,7 +386,7 @@ func TestRunRedisBenchmarkSmallLoad(t *testing.T) {
 }
 
 func TestRunKafkaBenchmarkLargeMessages(t *testing.T) {
-	skipIfNoKafka(t)
+	brokers := kafkatest.Brokers(t)
 
 	if testing.Short() {
 		t.Skip("Skipping large message test in short mode")
@@ -287,12 +400,11 @@ func TestRunKafkaBenchmarkLargeMessages(t *testing.T) {
 		DurationSeconds: 30,
 	}
 
-	brokers := "localhost:9092"
 	topic := "test-benchmark-kafka-large"
 
-	result, err := runKafkaBenchmark(config, brokers, topic)
+	result, err := runBenchmark(context.Background(), config, newTestBackend(t, "kafka", "-kafka-brokers", brokers, "-kafka-topic", topic))
 	if err != nil {
-		t.Fatalf("runKafkaBenchmark large messages failed: %v", err)
+		t.Fatalf("runBenchmark(kafka) large messages failed: %v", err)
 	}
 
 	expectedBytes := int64(config.MessageCount * config.MessageSize)
@@ -323,9 +435,9 @@ func TestRunRedisBenchmarkLargeMessages(t *testing.T) {
 	addr := "localhost:6379"
 	streamKey := "test-benchmark-redis-large"
 
-	result, err := runRedisBenchmark(config, addr, streamKey)
+	result, err := runBenchmark(context.Background(), config, newTestBackend(t, "redis", "-redis-addr", addr, "-redis-stream", streamKey))
 	if err != nil {
-		t.Fatalf("runRedisBenchmark large messages failed: %v", err)
+		t.Fatalf("runBenchmark(redis) large messages failed: %v", err)
 	}
 
 	expectedBytes := int64(config.MessageCount * config.MessageSize)
@@ -339,7 +451,7 @@ func TestRunRedisBenchmarkLargeMessages(t *testing.T) {
 }
 
 func TestRunKafkaBenchmarkMultipleProducersConsumers(t *testing.T) {
-	skipIfNoKafka(t)
+	brokers := kafkatest.Brokers(t)
 
 	if testing.Short() {
 		t.Skip("Skipping multi producer/consumer test in short mode")
@@ -353,12 +465,11 @@ func TestRunKafkaBenchmarkMultipleProducersConsumers(t *testing.T) {
 		DurationSeconds: 30,
 	}
 
-	brokers := "localhost:9092"
 	topic := "test-benchmark-kafka-multi"
 
-	result, err := runKafkaBenchmark(config, brokers, topic)
+	result, err := runBenchmark(context.Background(), config, newTestBackend(t, "kafka", "-kafka-brokers", brokers, "-kafka-topic", topic))
 	if err != nil {
-		t.Fatalf("runKafkaBenchmark multi producers/consumers failed: %v", err)
+		t.Fatalf("runBenchmark(kafka) multi producers/consumers failed: %v", err)
 	}
 
 	if result.SuccessCount != config.MessageCount {
@@ -389,9 +500,9 @@ func TestRunRedisBenchmarkMultipleProducersConsumers(t *testing.T) {
 	addr := "localhost:6379"
 	streamKey := "test-benchmark-redis-multi"
 
-	result, err := runRedisBenchmark(config, addr, streamKey)
+	result, err := runBenchmark(context.Background(), config, newTestBackend(t, "redis", "-redis-addr", addr, "-redis-stream", streamKey))
 	if err != nil {
-		t.Fatalf("runRedisBenchmark multi producers/consumers failed: %v", err)
+		t.Fatalf("runBenchmark(redis) multi producers/consumers failed: %v", err)
 	}
 
 	if result.SuccessCount != config.MessageCount {
@@ -490,3 +601,278 @@ func TestOutputDirectoryCreation(t *testing.T) {
 		t.Error("Expected directory to have execute permissions")
 	}
 }
+
+func TestRunMemoryBenchmark(t *testing.T) {
+	config := &common.BenchmarkConfig{
+		MessageCount:    1000,
+		MessageSize:     256,
+		ProducerCount:   4,
+		ConsumerCount:   4,
+		DurationSeconds: 10,
+	}
+
+	backend := newTestBackend(t, "memory", "-memory-topic", "test-benchmark-memory", "-memory-capacity", "100")
+
+	result, err := runBenchmark(context.Background(), config, backend)
+	if err != nil {
+		t.Fatalf("runBenchmark(memory) failed: %v", err)
+	}
+
+	if result.QueueType != "In-Memory" {
+		t.Errorf("Expected QueueType 'In-Memory', got '%s'", result.QueueType)
+	}
+
+	if result.SuccessCount != config.MessageCount {
+		t.Errorf("Expected %d successful messages, got %d", config.MessageCount, result.SuccessCount)
+	}
+
+	expectedBytes := int64(config.MessageCount * config.MessageSize)
+	if result.BytesProcessed != expectedBytes {
+		t.Errorf("Expected %d bytes processed, got %d", expectedBytes, result.BytesProcessed)
+	}
+
+	if result.P50Latency > result.P99Latency {
+		t.Error("P50 should be <= P99")
+	}
+}
+
+func TestRunMemoryReplayBenchmark(t *testing.T) {
+	config := &common.BenchmarkConfig{
+		MessageCount:    1000,
+		MessageSize:     256,
+		ProducerCount:   4,
+		ConsumerCount:   4,
+		DurationSeconds: 10,
+		Mode:            common.ModeReplay,
+	}
+
+	backend := newTestBackend(t, "memory", "-memory-topic", "test-benchmark-memory-replay", "-memory-capacity", "1000")
+
+	result, err := runBenchmark(context.Background(), config, backend)
+	if err != nil {
+		t.Fatalf("runBenchmark(memory replay) failed: %v", err)
+	}
+
+	if result.Mode != common.ModeReplay {
+		t.Errorf("Expected mode %q, got %q", common.ModeReplay, result.Mode)
+	}
+
+	if result.DeliveredCount != config.MessageCount {
+		t.Errorf("Expected %d replayed messages, got %d", config.MessageCount, result.DeliveredCount)
+	}
+}
+
+// provisioningBackend wraps a backend and records the Provisioner calls
+type provisioningBackend struct {
+	common.Backend
+	provisionErr error
+	provisioned  bool
+	cleanedUp    bool
+}
+
+func (p *provisioningBackend) Provision(ctx context.Context) error {
+	p.provisioned = true
+	return p.provisionErr
+}
+
+func (p *provisioningBackend) Cleanup(ctx context.Context) error {
+	p.cleanedUp = true
+	return nil
+}
+
+func TestRunBenchmarkProvisioning(t *testing.T) {
+	config := &common.BenchmarkConfig{
+		MessageCount:    100,
+		MessageSize:     16,
+		ProducerCount:   1,
+		ConsumerCount:   1,
+		DurationSeconds: 10,
+	}
+
+	backend := &provisioningBackend{Backend: newTestBackend(t, "memory", "-memory-topic", "test-benchmark-provisioning")}
+	if _, err := runBenchmark(context.Background(), config, backend); err != nil {
+		t.Fatalf("runBenchmark failed: %v", err)
+	}
+
+	if !backend.provisioned || !backend.cleanedUp {
+		t.Errorf("Expected Provision and Cleanup to be called, got provisioned=%v cleanedUp=%v",
+			backend.provisioned, backend.cleanedUp)
+	}
+
+	// A failed provision aborts the run before anything is cleaned up
+	failing := &provisioningBackend{
+		Backend:      newTestBackend(t, "memory", "-memory-topic", "test-benchmark-provisioning-failed"),
+		provisionErr: errors.New("no brokers"),
+	}
+	if _, err := runBenchmark(context.Background(), config, failing); err == nil {
+		t.Error("Expected error when provisioning fails, got nil")
+	}
+
+	if failing.cleanedUp {
+		t.Error("Expected no Cleanup after a failed Provision")
+	}
+}
+
+func TestRunKafkaBenchmarkProvisionedTopic(t *testing.T) {
+	kafkatest.SkipIfMock(t)
+	brokers := kafkatest.Brokers(t)
+
+	config := &common.BenchmarkConfig{
+		MessageCount:    100,
+		MessageSize:     64,
+		ProducerCount:   2,
+		ConsumerCount:   2,
+		DurationSeconds: 30,
+	}
+
+	backend := newTestBackend(t, "kafka",
+		"-kafka-brokers", brokers,
+		"-kafka-topic", "test-benchmark-provisioned",
+		"-kafka-partitions", "4",
+		"-kafka-topic-config", "retention.ms=600000",
+		"-kafka-delete-topic")
+
+	result, err := runBenchmark(context.Background(), config, backend)
+	if err != nil {
+		t.Fatalf("runBenchmark(kafka) failed: %v", err)
+	}
+
+	if result.DeliveredCount != config.MessageCount {
+		t.Errorf("Expected %d delivered messages, got %d", config.MessageCount, result.DeliveredCount)
+	}
+}
+
+func TestRunKafkaMockBenchmark(t *testing.T) {
+	config := &common.BenchmarkConfig{
+		MessageCount:    100,
+		MessageSize:     64,
+		ProducerCount:   2,
+		ConsumerCount:   2,
+		DurationSeconds: 30,
+	}
+
+	// No broker needed: the backend starts its own three-broker cluster
+	backend := newTestBackend(t, "kafka",
+		"-kafka-mock", "3",
+		"-kafka-topic", "test-benchmark-mock",
+		"-kafka-partitions", "6",
+		"-kafka-replication", "3")
+
+	result, err := runBenchmark(context.Background(), config, backend)
+	if err != nil {
+		t.Fatalf("runBenchmark(kafka) failed: %v", err)
+	}
+
+	if result.DeliveredCount != config.MessageCount {
+		t.Errorf("Expected %d delivered messages, got %d", config.MessageCount, result.DeliveredCount)
+	}
+
+	if len(result.PartitionStats) != 6 {
+		t.Errorf("Expected 6 partitions, got %d", len(result.PartitionStats))
+	}
+}
+
+func TestRunKafkaMockTopicConfig(t *testing.T) {
+	config := &common.BenchmarkConfig{
+		MessageCount:    10,
+		MessageSize:     64,
+		ProducerCount:   1,
+		ConsumerCount:   1,
+		DurationSeconds: 5,
+	}
+
+	backend := newTestBackend(t, "kafka", "-kafka-mock", "1", "-kafka-topic-config", "retention.ms=600000")
+	if _, err := runBenchmark(context.Background(), config, backend); err == nil {
+		t.Error("Expected an error for -kafka-topic-config on the mock cluster, got nil")
+	}
+}
+
+func TestRunKafkaTxnBenchmark(t *testing.T) {
+	brokers := kafkatest.Brokers(t)
+
+	config := &common.BenchmarkConfig{
+		MessageCount:    200,
+		MessageSize:     64,
+		ProducerCount:   2,
+		ConsumerCount:   2,
+		BatchSize:       20,
+		DurationSeconds: 30,
+	}
+
+	backend := newTestBackend(t, "kafka-txn",
+		"-kafka-brokers", brokers,
+		"-kafka-topic", "test-benchmark-txn",
+		"-kafka-txn-size", "50",
+		"-kafka-transactional-id", "test-benchmark-txn")
+
+	result, err := runBenchmark(context.Background(), config, backend)
+	if err != nil {
+		t.Fatalf("runBenchmark(kafka-txn) failed: %v", err)
+	}
+
+	if result.QueueType != "Apache Kafka (transactional)" {
+		t.Errorf("Expected QueueType 'Apache Kafka (transactional)', got '%s'", result.QueueType)
+	}
+
+	if result.TransactionCount == 0 {
+		t.Error("Expected committed transactions")
+	}
+
+	if result.DeliveredCount != config.MessageCount {
+		t.Errorf("Expected %d delivered messages, got %d", config.MessageCount, result.DeliveredCount)
+	}
+
+	if result.ConsumerConfig["isolation.level"] != "read_committed" {
+		t.Errorf("Expected read_committed consumer, got isolation.level %q", result.ConsumerConfig["isolation.level"])
+	}
+}
+
+func TestBackendsRegistered(t *testing.T) {
+	for _, name := range []string{"kafka", "kafka-txn", "memory", "redis"} {
+		if _, err := common.LookupBackend(name); err != nil {
+			t.Errorf("Expected backend %s to be registered: %v", name, err)
+		}
+	}
+
+	backends, err := common.ParseBackendList("kafka,redis")
+	if err != nil {
+		t.Fatalf("Failed to parse default backend list: %v", err)
+	}
+
+	if len(backends) != 2 || backends[0].Name() != "kafka" || backends[1].Name() != "redis" {
+		t.Errorf("Expected [kafka redis], got %d backends", len(backends))
+	}
+}
+
+func TestParseHeaders(t *testing.T) {
+	headers, err := parseHeaders("content-type=application/json, tenant-id = acme,trace-id={uuid}")
+	if err != nil {
+		t.Fatalf("parseHeaders failed: %v", err)
+	}
+
+	expected := map[string]string{
+		"content-type": "application/json",
+		"tenant-id":    "acme",
+		"trace-id":     common.HeaderValueUUID,
+	}
+	if len(headers) != len(expected) {
+		t.Fatalf("Expected %d headers, got %d", len(expected), len(headers))
+	}
+	for key, value := range expected {
+		if headers[key] != value {
+			t.Errorf("Expected header %s=%s, got %s", key, value, headers[key])
+		}
+	}
+
+	if headers, err := parseHeaders(""); err != nil || headers != nil {
+		t.Errorf("Expected no headers for empty list, got %v, %v", headers, err)
+	}
+
+	if _, err := parseHeaders("no-equals-sign"); err == nil {
+		t.Error("Expected error for pair without '='")
+	}
+
+	if _, err := parseHeaders("=value"); err == nil {
+		t.Error("Expected error for empty key")
+	}
+}
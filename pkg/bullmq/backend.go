@@ -0,0 +1,73 @@
+package bullmq
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/praneethys/kafka-bullmq-benchmark/pkg/common"
+)
+
+func init() {
+	common.RegisterBackend(&backend{})
+}
+
+// backend exposes BullMQ job queues to the benchmark CLI through the common
+// registry
+type backend struct {
+	addr      string
+	queueName string
+	options   BullMQOptions
+}
+
+func (b *backend) Name() string {
+	return "bullmq"
+}
+
+func (b *backend) RegisterFlags(fs *flag.FlagSet) {
+	fs.StringVar(&b.addr, "bullmq-addr", "localhost:6379", "Redis server address of the BullMQ queue")
+	fs.StringVar(&b.queueName, "bullmq-queue", "benchmark", "BullMQ queue name")
+
+	defaults := DefaultBullMQOptions()
+	b.options = defaults
+	fs.StringVar(&b.options.Prefix, "bullmq-prefix", defaults.Prefix, "BullMQ key prefix")
+	fs.DurationVar(&b.options.LockDuration, "bullmq-lock-duration", defaults.LockDuration,
+		"How long a BullMQ worker owns a job before it counts as stalled")
+	fs.DurationVar(&b.options.StalledInterval, "bullmq-stalled-interval", defaults.StalledInterval,
+		"How often BullMQ workers recover the jobs of workers that died")
+	fs.IntVar(&b.options.MaxStalledCount, "bullmq-max-stalled-count", defaults.MaxStalledCount,
+		"How often a BullMQ job may stall before it is failed")
+	fs.IntVar(&b.options.KeepCompleted, "bullmq-keep-completed", defaults.KeepCompleted,
+		"Completed BullMQ jobs to keep (-1 keeps all, 0 removes them)")
+	fs.IntVar(&b.options.KeepFailed, "bullmq-keep-failed", defaults.KeepFailed,
+		"Failed BullMQ jobs to keep (-1 keeps all, 0 removes them)")
+	b.options.Connection.RegisterFlags(fs, "bullmq")
+}
+
+// NewQueue creates a queue for the role in opts. Job data is always JSON,
+// which BullMQ processors expect, so any other opts.Codec is rejected. Each
+// worker gets a name of its own, so the jobs of different runs and
+// processes tell which worker took them. A job is processed by one worker
+// only, so replay consumers simply work through the jobs left waiting.
+func (b *backend) NewQueue(opts common.BackendOptions) (common.MessageQueue, error) {
+	if json := (common.JSONCodec{}).Name(); opts.Codec != nil && opts.Codec.Name() != json {
+		return nil, fmt.Errorf("BullMQ job data is always %s, codec %s is not supported", json, opts.Codec.Name())
+	}
+
+	options := b.options
+	options.Connection = options.Connection.WithEnvPassword()
+
+	var queue *BullMQQueue
+	var err error
+	if opts.Role == common.RoleProducer {
+		queue, err = NewBullMQProducer(b.addr, b.queueName, options)
+	} else {
+		queue, err = NewBullMQConsumer(b.addr, b.queueName, "benchmark-worker-"+uuid.NewString(), options)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	queue.SetRetryPolicy(opts.Retry)
+	return queue, nil
+}
@@ -0,0 +1,485 @@
+package bullmq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/praneethys/kafka-bullmq-benchmark/pkg/common"
+	redisqueue "github.com/praneethys/kafka-bullmq-benchmark/pkg/redis"
+	"github.com/redis/go-redis/v9"
+)
+
+// markerTimeout is how long an idle worker blocks on the marker before
+// checking for cancellation
+const markerTimeout = 100 * time.Millisecond
+
+// BullMQQueue implements the MessageQueue interface as a BullMQ job queue.
+// It uses BullMQ's key layout and job lifecycle, so its jobs can be
+// processed by BullMQ workers and its workers process jobs added by BullMQ
+// queues: producers add jobs to the wait list, and workers move them to the
+// active list under a lock and then to the completed or failed set.
+type BullMQQueue struct {
+	client    *redis.Client
+	config    map[string]string // connection and queue settings, for the report
+	queueName string
+	keys      keys
+	options   BullMQOptions
+	codec     common.Codec
+	jobOpts   string // opts field of added jobs
+
+	// Workers only: the worker name recorded in processed jobs, and the
+	// source of lock tokens
+	workerName string
+	workerID   string
+	tokens     atomic.Uint64
+
+	retry    common.RetryPolicy
+	failures common.FailureCounters
+}
+
+// errNotWorker is returned by Consume on a producer-only queue
+var errNotWorker = errors.New("queue was created without a worker")
+
+// NewBullMQProducer creates a queue that only adds jobs to the BullMQ queue
+// queueName on the Redis server at addr
+func NewBullMQProducer(addr, queueName string, opts BullMQOptions) (*BullMQQueue, error) {
+	return newQueue(addr, queueName, "", opts)
+}
+
+// NewBullMQConsumer creates a queue that processes jobs of the BullMQ queue
+// queueName as the worker workerName, competing for jobs with every other
+// worker of the queue
+func NewBullMQConsumer(addr, queueName, workerName string, opts BullMQOptions) (*BullMQQueue, error) {
+	if workerName == "" {
+		return nil, fmt.Errorf("BullMQ worker name must not be empty")
+	}
+	return newQueue(addr, queueName, workerName, opts)
+}
+
+func newQueue(addr, queueName, workerName string, opts BullMQOptions) (*BullMQQueue, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+
+	client, err := redisqueue.NewClient(addr, opts.Connection)
+	if err != nil {
+		return nil, err
+	}
+	if err := loadScripts(context.Background(), client); err != nil {
+		_ = client.Close() //nolint:errcheck // Best effort cleanup on error path
+		return nil, err
+	}
+
+	q := &BullMQQueue{
+		client:     client,
+		config:     opts.reportedConfig(addr, queueName),
+		queueName:  queueName,
+		keys:       newKeys(opts.Prefix, queueName),
+		options:    opts,
+		codec:      common.JSONCodec{},
+		workerName: workerName,
+		workerID:   uuid.New().String(),
+	}
+	if q.jobOpts, err = encodeJobOptions(q.retry, opts); err != nil {
+		_ = client.Close() //nolint:errcheck // Best effort cleanup on error path
+		return nil, err
+	}
+	return q, nil
+}
+
+// ClientConfig returns the connection and queue settings, with the password
+// masked. Both roles share them.
+func (q *BullMQQueue) ClientConfig(common.Role) map[string]string {
+	return q.config
+}
+
+// Codec returns the wire format of job data. It is always JSON, which is
+// what BullMQ processors expect in job.data.
+func (q *BullMQQueue) Codec() common.Codec {
+	return q.codec
+}
+
+// SetRetryPolicy sets the attempts and backoff options of the jobs a
+// producer adds. Workers, both Consume and BullMQ's, retry each job by its
+// own options, so the policy of the producer is the one that applies.
+func (q *BullMQQueue) SetRetryPolicy(policy common.RetryPolicy) {
+	q.retry = policy
+	if jobOpts, err := encodeJobOptions(policy, q.options); err == nil {
+		q.jobOpts = jobOpts
+	}
+}
+
+// FailureStats reports the retries and failed jobs seen by Consume. Jobs
+// moved to the failed set, including those that stalled too often, count as
+// dead-lettered, or as discarded when failed jobs are not kept.
+func (q *BullMQQueue) FailureStats() common.FailureStats {
+	return q.failures.Stats()
+}
+
+// addJobArgs returns the keys and arguments of addJobScript for msg
+func (q *BullMQQueue) addJobArgs(msg *common.Message) ([]string, []interface{}, error) {
+	data, headers, err := encodeJob(q.codec, msg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	keys := []string{q.keys.wait, q.keys.paused, q.keys.meta, q.keys.id, q.keys.events, q.keys.marker}
+	return keys, []interface{}{q.keys.prefix, jobName, data, q.jobOpts, headers}, nil
+}
+
+// Produce adds a message to the queue as a job
+func (q *BullMQQueue) Produce(ctx context.Context, msg *common.Message) error {
+	keys, args, err := q.addJobArgs(msg)
+	if err != nil {
+		return err
+	}
+
+	if err := addJobScript.Run(ctx, q.client, keys, args...).Err(); err != nil {
+		return fmt.Errorf("failed to add job: %w", err)
+	}
+	return nil
+}
+
+// ProduceBatch adds all messages as jobs in one pipelined round trip, as
+// BullMQ's Queue.addBulk does
+func (q *BullMQQueue) ProduceBatch(ctx context.Context, msgs []*common.Message) error {
+	failed := 0
+	var firstErr error
+
+	pipe := q.client.Pipeline()
+	cmds := make([]*redis.Cmd, 0, len(msgs))
+	for _, msg := range msgs {
+		keys, args, err := q.addJobArgs(msg)
+		if err != nil {
+			failed++
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		// The script was loaded when the queue was created
+		cmds = append(cmds, addJobScript.EvalSha(ctx, pipe, keys, args...))
+	}
+
+	if len(cmds) > 0 {
+		// Exec reports the first failed command; count each one individually
+		_, _ = pipe.Exec(ctx) //nolint:errcheck // Per-command errors are checked below
+	}
+
+	for _, cmd := range cmds {
+		if err := cmd.Err(); err != nil {
+			failed++
+			if firstErr == nil {
+				firstErr = fmt.Errorf("failed to add job: %w", err)
+			}
+		}
+	}
+
+	if failed > 0 {
+		return &common.BatchError{Failed: failed, Total: len(msgs), Err: firstErr}
+	}
+
+	return nil
+}
+
+// Consume processes jobs with handler until ctx is cancelled, one at a
+// time, as a BullMQ worker with concurrency 1 does. A job whose handler
+// succeeds is moved to the completed set. A job whose handler fails is
+// released for another attempt while the attempts in its options last,
+// waiting out its backoff in the delayed set, and is then moved to the
+// failed set, BullMQ's equivalent of a dead-letter queue. Jobs interrupted
+// by cancellation go back to the wait list. While it runs, Consume also
+// takes part in BullMQ's stall check, which recovers the jobs of workers
+// that died.
+func (q *BullMQQueue) Consume(ctx context.Context, handler func(*common.Message) error) error {
+	if q.workerName == "" {
+		return errNotWorker
+	}
+
+	checkCtx, stopCheck := context.WithCancel(ctx)
+	checkDone := make(chan struct{})
+	go func() {
+		defer close(checkDone)
+		q.checkStalled(checkCtx)
+	}()
+	defer func() {
+		stopCheck()
+		<-checkDone
+	}()
+
+	// Jobs handled just before cancellation must still be finished,
+	// otherwise they would stay active until their lock expires
+	finishCtx := context.WithoutCancel(ctx)
+
+	var next *job
+	for {
+		if next == nil {
+			select {
+			case <-ctx.Done():
+				return nil
+			default:
+			}
+
+			var err error
+			if next, err = q.moveToActive(ctx); err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				return fmt.Errorf("worker error: %w", err)
+			}
+			if next == nil {
+				if err := q.waitForJob(ctx); err != nil && ctx.Err() == nil {
+					return fmt.Errorf("worker error: %w", err)
+				}
+				continue
+			}
+		}
+
+		next = q.process(ctx, finishCtx, next, handler)
+	}
+}
+
+// waitForJob blocks until producers set the marker of the queue, or for at
+// most markerTimeout
+func (q *BullMQQueue) waitForJob(ctx context.Context) error {
+	err := q.client.Do(ctx, "bzpopmin", q.keys.marker, markerTimeout.Seconds()).Err()
+	if err == redis.Nil {
+		return nil
+	}
+	return err
+}
+
+// process makes one attempt at a job and releases it. It returns the next
+// job if releasing took one.
+func (q *BullMQQueue) process(ctx, finishCtx context.Context, j *job, handler func(*common.Message) error) *job {
+	msg, err := decodeJob(q.codec, j)
+	if err != nil {
+		// Another attempt would fail the same way
+		q.failures.AddUndecodable()
+		return q.fail(ctx, finishCtx, j, err)
+	}
+
+	attemptsMade := j.attemptsMade()
+	if attemptsMade > 0 {
+		q.failures.AddRedelivery()
+	}
+
+	stop := q.keepLocked(finishCtx, j)
+	err = handler(msg)
+	stop()
+
+	switch {
+	case err == nil:
+		next, _ := q.moveToFinished(finishCtx, j, nil, ctx.Err() == nil) //nolint:errcheck // A lost lock leaves the job to the stall check
+		return next
+	case ctx.Err() != nil:
+		_ = q.moveToWait(finishCtx, j) //nolint:errcheck // Otherwise the job is recovered once its lock expires
+		return nil
+	}
+
+	if opts := j.retryOptions(); attemptsMade+1 < opts.Attempts {
+		_ = q.retryJob(finishCtx, j, err, opts.Backoff.delay(attemptsMade+1)) //nolint:errcheck // A lost lock leaves the job to the stall check
+		return nil
+	}
+	return q.fail(ctx, finishCtx, j, err)
+}
+
+// fail moves a job to the failed set for good and counts it. It returns the
+// next job if that took one.
+func (q *BullMQQueue) fail(ctx, finishCtx context.Context, j *job, cause error) *job {
+	next, err := q.moveToFinished(finishCtx, j, cause, ctx.Err() == nil)
+	if err == nil {
+		q.countFailed()
+	}
+	return next
+}
+
+// countFailed records a job moved to the failed set, which is removed right
+// away when failed jobs are not kept
+func (q *BullMQQueue) countFailed() {
+	if q.options.KeepFailed == 0 {
+		q.failures.AddDiscarded()
+	} else {
+		q.failures.AddDeadLettered()
+	}
+}
+
+// nextToken returns a new lock token, unique per worker and job like the
+// tokens of BullMQ workers
+func (q *BullMQQueue) nextToken() string {
+	return q.workerID + ":" + strconv.FormatUint(q.tokens.Add(1), 10)
+}
+
+// lockDuration returns the lock duration as the milliseconds the scripts take
+func (q *BullMQQueue) lockDuration() int64 {
+	return q.options.LockDuration.Milliseconds()
+}
+
+// moveToActive takes the next waiting job, or returns nil if there is none
+func (q *BullMQQueue) moveToActive(ctx context.Context) (*job, error) {
+	token := q.nextToken()
+	reply, err := moveToActiveScript.Run(ctx, q.client,
+		[]string{q.keys.wait, q.keys.active, q.keys.events, q.keys.meta, q.keys.marker, q.keys.delayed},
+		q.keys.prefix, token, q.lockDuration(), q.workerName).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to move job to active: %w", err)
+	}
+	return parseJobReply(reply, "", token)
+}
+
+// moveToFinished moves an active job to the completed set, or to the failed
+// set if cause is set. With fetchNext it also takes the next waiting job.
+func (q *BullMQQueue) moveToFinished(ctx context.Context, j *job, cause error, fetchNext bool) (*job, error) {
+	// BullMQ stores the JSON return value of the processor
+	target, set, property, value, keep := "completed", q.keys.completed, "returnvalue", "null", q.options.KeepCompleted
+	if cause != nil {
+		target, set, property, value, keep = "failed", q.keys.failed, "failedReason", cause.Error(), q.options.KeepFailed
+	}
+	fetch := "0"
+	if fetchNext {
+		fetch = "1"
+	}
+
+	token := q.nextToken()
+	reply, err := moveToFinishedScript.Run(ctx, q.client,
+		[]string{q.keys.active, q.keys.events, q.keys.meta, q.keys.stalled, set, q.keys.wait, q.keys.marker, q.keys.delayed},
+		q.keys.prefix, j.id, j.token, property, value, target, keep,
+		fetch, token, q.lockDuration(), q.workerName).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to move job %s to %s: %w", j.id, target, err)
+	}
+	return parseJobReply(reply, j.id, token)
+}
+
+// retryJob releases an active job whose attempt failed with cause, to be
+// attempted again once backoff has passed
+func (q *BullMQQueue) retryJob(ctx context.Context, j *job, cause error, backoff time.Duration) error {
+	code, err := retryJobScript.Run(ctx, q.client,
+		[]string{q.keys.active, q.keys.wait, q.keys.paused, q.keys.delayed, q.keys.events, q.keys.meta, q.keys.marker, q.keys.stalled},
+		q.keys.prefix, j.id, j.token, cause.Error(), backoff.Milliseconds()).Int64()
+	if err != nil {
+		return fmt.Errorf("failed to retry job %s: %w", j.id, err)
+	}
+	if code < 0 {
+		return scriptError(code, j.id)
+	}
+	return nil
+}
+
+// moveToWait hands an active job back to the wait list
+func (q *BullMQQueue) moveToWait(ctx context.Context, j *job) error {
+	code, err := moveToWaitScript.Run(ctx, q.client,
+		[]string{q.keys.active, q.keys.wait, q.keys.paused, q.keys.events, q.keys.meta, q.keys.marker, q.keys.stalled},
+		q.keys.prefix, j.id, j.token).Int64()
+	if err != nil {
+		return fmt.Errorf("failed to move job %s to wait: %w", j.id, err)
+	}
+	if code < 0 {
+		return scriptError(code, j.id)
+	}
+	return nil
+}
+
+// checkStalled runs the stall check now and then every stalled interval
+// until ctx is done, as BullMQ workers do
+func (q *BullMQQueue) checkStalled(ctx context.Context) {
+	ticker := time.NewTicker(q.options.StalledInterval)
+	defer ticker.Stop()
+
+	for {
+		_ = q.moveStalled(ctx) //nolint:errcheck // The next check tries again
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// moveStalled recovers the jobs left active by workers that died, unless
+// another worker checked within the stalled interval
+func (q *BullMQQueue) moveStalled(ctx context.Context) error {
+	counts, err := moveStalledScript.Run(ctx, q.client,
+		[]string{q.keys.stalled, q.keys.wait, q.keys.active, q.keys.failed, q.keys.stalledCheck,
+			q.keys.meta, q.keys.paused, q.keys.marker, q.keys.events},
+		q.keys.prefix, q.options.MaxStalledCount, q.options.KeepFailed, q.options.StalledInterval.Milliseconds()).Int64Slice()
+	if err != nil {
+		return fmt.Errorf("failed to check for stalled jobs: %w", err)
+	}
+	for i := int64(0); i < counts[0]; i++ {
+		q.countFailed()
+	}
+	return nil
+}
+
+// keepLocked extends the lock of j every half lock duration until the
+// returned function is called, as BullMQ workers do while a job runs
+func (q *BullMQQueue) keepLocked(ctx context.Context, j *job) (stop func()) {
+	interval := q.options.LockDuration / 2
+	lockKey := q.keys.prefix + j.id + ":lock"
+
+	var mu sync.Mutex
+	stopped := false
+	var timer *time.Timer
+	extend := func() {
+		// A lost lock is not renewed; finishing the job then reports it
+		_ = extendLockScript.Run(ctx, q.client, []string{lockKey, q.keys.stalled}, j.token, q.lockDuration(), j.id).Err() //nolint:errcheck // See above
+
+		mu.Lock()
+		defer mu.Unlock()
+		if !stopped {
+			timer.Reset(interval)
+		}
+	}
+
+	mu.Lock()
+	timer = time.AfterFunc(interval, extend)
+	mu.Unlock()
+
+	return func() {
+		mu.Lock()
+		defer mu.Unlock()
+		stopped = true
+		timer.Stop()
+	}
+}
+
+// Close closes the Redis client connection. Callers must cancel and wait
+// for every Consume call first.
+func (q *BullMQQueue) Close() error {
+	return q.client.Close()
+}
+
+// GetName returns the name of this queue implementation
+func (q *BullMQQueue) GetName() string {
+	return "BullMQ"
+}
+
+// Counts returns the number of jobs in each state of the queue, like
+// BullMQ's Queue.getJobCounts
+func (q *BullMQQueue) Counts(ctx context.Context) (map[string]int64, error) {
+	pipe := q.client.Pipeline()
+	cmds := map[string]*redis.IntCmd{
+		"wait":      pipe.LLen(ctx, q.keys.wait),
+		"paused":    pipe.LLen(ctx, q.keys.paused),
+		"active":    pipe.LLen(ctx, q.keys.active),
+		"delayed":   pipe.ZCard(ctx, q.keys.delayed),
+		"completed": pipe.ZCard(ctx, q.keys.completed),
+		"failed":    pipe.ZCard(ctx, q.keys.failed),
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, fmt.Errorf("failed to count jobs: %w", err)
+	}
+
+	counts := make(map[string]int64, len(cmds))
+	for state, cmd := range cmds {
+		counts[state] = cmd.Val()
+	}
+	return counts, nil
+}
@@ -0,0 +1,395 @@
+package bullmq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/praneethys/kafka-bullmq-benchmark/pkg/common"
+)
+
+const (
+	testAddr   = "localhost:6379"
+	testWorker = "test-worker"
+)
+
+func skipIfNoRedis(t *testing.T) {
+	// Check if REDIS_TEST environment variable is set
+	if os.Getenv("REDIS_TEST") != "true" {
+		t.Skip("Skipping Redis integration test. Set REDIS_TEST=true to run.")
+	}
+}
+
+// newTestQueues creates a producer and a worker of a queue unique to the
+// test, whose keys are deleted when it ends
+func newTestQueues(t *testing.T, opts BullMQOptions) (producer, worker *BullMQQueue) {
+	t.Helper()
+	skipIfNoRedis(t)
+
+	queueName := fmt.Sprintf("test-%s-%d", t.Name(), time.Now().UnixNano())
+	producer, err := NewBullMQProducer(testAddr, queueName, opts)
+	if err != nil {
+		t.Fatalf("Failed to create producer: %v", err)
+	}
+	worker, err = NewBullMQConsumer(testAddr, queueName, testWorker, opts)
+	if err != nil {
+		t.Fatalf("Failed to create worker: %v", err)
+	}
+
+	t.Cleanup(func() {
+		ctx := context.Background()
+		keys, _ := producer.client.Keys(ctx, producer.keys.prefix+"*").Result()
+		if len(keys) > 0 {
+			producer.client.Del(ctx, keys...)
+		}
+		producer.Close()
+		worker.Close()
+	})
+	return producer, worker
+}
+
+// consumeN runs Consume until handler has seen n messages, then stops it
+func consumeN(t *testing.T, queue *BullMQQueue, n int, handler func(*common.Message) error) []*common.Message {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var mu sync.Mutex
+	var received []*common.Message
+	err := queue.Consume(ctx, func(msg *common.Message) error {
+		err := handler(msg)
+		mu.Lock()
+		defer mu.Unlock()
+		received = append(received, msg)
+		if len(received) == n {
+			cancel()
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Consume failed: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(received) < n {
+		t.Fatalf("Expected %d handler calls, got %d", n, len(received))
+	}
+	return received
+}
+
+func succeed(*common.Message) error { return nil }
+
+func TestNewKeys(t *testing.T) {
+	k := newKeys("bull", "emails")
+
+	if k.prefix != "bull:emails:" || k.wait != "bull:emails:wait" || k.marker != "bull:emails:marker" {
+		t.Errorf("Expected BullMQ key names, got %+v", k)
+	}
+}
+
+func TestBullMQOptionsValidate(t *testing.T) {
+	if err := DefaultBullMQOptions().validate(); err != nil {
+		t.Errorf("Expected the defaults to be valid, got %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(*BullMQOptions)
+	}{
+		{name: "empty prefix", modify: func(o *BullMQOptions) { o.Prefix = "" }},
+		{name: "short lock", modify: func(o *BullMQOptions) { o.LockDuration = time.Millisecond }},
+		{name: "short stalled interval", modify: func(o *BullMQOptions) { o.StalledInterval = 0 }},
+		{name: "negative max stalled", modify: func(o *BullMQOptions) { o.MaxStalledCount = -1 }},
+		{name: "negative keep", modify: func(o *BullMQOptions) { o.KeepFailed = -2 }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := DefaultBullMQOptions()
+			tt.modify(&opts)
+			if err := opts.validate(); err == nil {
+				t.Error("Expected an error, got nil")
+			}
+		})
+	}
+}
+
+func TestNewBullMQQueueInvalidAddr(t *testing.T) {
+	if _, err := NewBullMQProducer("invalid:9999", "test", DefaultBullMQOptions()); err == nil {
+		t.Error("Expected error for invalid Redis address, got nil")
+	}
+
+	if _, err := NewBullMQConsumer(testAddr, "test", "", DefaultBullMQOptions()); err == nil {
+		t.Error("Expected error for an empty worker name, got nil")
+	}
+}
+
+func TestBullMQBackendRejectsCodec(t *testing.T) {
+	_, err := (&backend{}).NewQueue(common.BackendOptions{Role: common.RoleProducer, Codec: common.BinaryCodec{}})
+	if err == nil {
+		t.Error("Expected an error for a codec other than JSON, got nil")
+	}
+}
+
+func TestBullMQGetName(t *testing.T) {
+	if name := (&BullMQQueue{}).GetName(); name != "BullMQ" {
+		t.Errorf("Expected name 'BullMQ', got '%s'", name)
+	}
+}
+
+func TestBullMQProducerOnly(t *testing.T) {
+	producer, _ := newTestQueues(t, DefaultBullMQOptions())
+
+	err := producer.Consume(context.Background(), succeed)
+	if !errors.Is(err, errNotWorker) {
+		t.Errorf("Expected errNotWorker, got %v", err)
+	}
+}
+
+func TestBullMQJobLifecycle(t *testing.T) {
+	producer, worker := newTestQueues(t, DefaultBullMQOptions())
+	ctx := context.Background()
+	client := producer.client
+
+	msg := &common.Message{ID: "job-1", Payload: []byte("hello"), Timestamp: time.Now(), Headers: map[string]string{"k": "v"}}
+	if err := producer.Produce(ctx, msg); err != nil {
+		t.Fatalf("Produce failed: %v", err)
+	}
+
+	// Added jobs wait in the list under IDs from the id counter
+	if ids := client.LRange(ctx, producer.keys.wait, 0, -1).Val(); len(ids) != 1 || ids[0] != "1" {
+		t.Fatalf("Expected job 1 waiting, got %v", ids)
+	}
+	fields := client.HGetAll(ctx, producer.keys.prefix+"1").Val()
+	for _, field := range []string{"name", "data", "opts", "timestamp", "delay", "priority"} {
+		if _, ok := fields[field]; !ok {
+			t.Errorf("Expected job hash field %q, got %v", field, fields)
+		}
+	}
+
+	received := consumeN(t, worker, 1, succeed)
+	if received[0].ID != "job-1" || received[0].Headers["k"] != "v" || received[0].Trace.BrokerTime.IsZero() {
+		t.Errorf("Expected the message with its headers and job timestamp, got %+v", received[0])
+	}
+
+	if n := client.LLen(ctx, producer.keys.active).Val(); n != 0 {
+		t.Errorf("Expected no active jobs, got %d", n)
+	}
+	if ids := client.ZRange(ctx, producer.keys.completed, 0, -1).Val(); len(ids) != 1 || ids[0] != "1" {
+		t.Errorf("Expected job 1 completed, got %v", ids)
+	}
+	if client.Exists(ctx, producer.keys.prefix+"1:lock").Val() != 0 {
+		t.Error("Expected the job lock to be released")
+	}
+
+	fields = client.HGetAll(ctx, producer.keys.prefix+"1").Val()
+	if fields["returnvalue"] != "null" || fields["finishedOn"] == "" || fields["processedOn"] == "" ||
+		fields["atm"] != "1" || fields["ats"] != "1" || fields["pb"] != testWorker {
+		t.Errorf("Expected the finished job fields BullMQ sets, got %v", fields)
+	}
+
+	var events []string
+	for _, entry := range client.XRange(ctx, producer.keys.events, "-", "+").Val() {
+		events = append(events, entry.Values["event"].(string))
+	}
+	if fmt.Sprint(events) != "[added waiting active completed]" {
+		t.Errorf("Expected the job's lifecycle events, got %v", events)
+	}
+}
+
+func TestBullMQBatchAndKeepCompleted(t *testing.T) {
+	opts := DefaultBullMQOptions()
+	opts.KeepCompleted = 2
+	producer, worker := newTestQueues(t, opts)
+	ctx := context.Background()
+
+	msgs := make([]*common.Message, 5)
+	for i := range msgs {
+		msgs[i] = &common.Message{ID: fmt.Sprintf("batch-%d", i), Payload: []byte("data"), Timestamp: time.Now()}
+	}
+	if err := producer.ProduceBatch(ctx, msgs); err != nil {
+		t.Fatalf("ProduceBatch failed: %v", err)
+	}
+
+	// Jobs are processed in the order they were added
+	received := consumeN(t, worker, len(msgs), succeed)
+	for i, msg := range received {
+		if msg.ID != msgs[i].ID {
+			t.Errorf("Expected %s at position %d, got %s", msgs[i].ID, i, msg.ID)
+		}
+	}
+
+	if ids := producer.client.ZRange(ctx, producer.keys.completed, 0, -1).Val(); fmt.Sprint(ids) != "[4 5]" {
+		t.Errorf("Expected only the latest 2 completed jobs kept, got %v", ids)
+	}
+	if producer.client.Exists(ctx, producer.keys.prefix+"1").Val() != 0 {
+		t.Error("Expected the hash of a removed job to be deleted")
+	}
+}
+
+func TestBullMQRetryAndFail(t *testing.T) {
+	producer, worker := newTestQueues(t, DefaultBullMQOptions())
+	producer.SetRetryPolicy(common.RetryPolicy{MaxAttempts: 2, Backoff: time.Millisecond})
+	ctx := context.Background()
+
+	if err := producer.Produce(ctx, &common.Message{ID: "fails", Payload: []byte("x"), Timestamp: time.Now()}); err != nil {
+		t.Fatalf("Produce failed: %v", err)
+	}
+
+	consumeN(t, worker, 2, func(*common.Message) error { return errors.New("boom") })
+
+	if ids := producer.client.ZRange(ctx, producer.keys.failed, 0, -1).Val(); len(ids) != 1 {
+		t.Fatalf("Expected the job in the failed set, got %v", ids)
+	}
+	fields := producer.client.HGetAll(ctx, producer.keys.prefix+"1").Val()
+	if fields["failedReason"] != "boom" || fields["atm"] != "2" {
+		t.Errorf("Expected the failure reason and 2 attempts made, got %v", fields)
+	}
+
+	stats := worker.FailureStats()
+	if stats.Redeliveries != 1 || stats.DeadLettered != 1 {
+		t.Errorf("Expected 1 redelivery and 1 failed job, got %+v", stats)
+	}
+}
+
+func TestBullMQRetryDelayed(t *testing.T) {
+	producer, worker := newTestQueues(t, DefaultBullMQOptions())
+	producer.SetRetryPolicy(common.RetryPolicy{MaxAttempts: 2, Backoff: time.Minute})
+	ctx := context.Background()
+
+	if err := producer.Produce(ctx, &common.Message{ID: "delayed", Payload: []byte("x"), Timestamp: time.Now()}); err != nil {
+		t.Fatalf("Produce failed: %v", err)
+	}
+
+	// The failed attempt releases the job, which waits out its backoff in
+	// the delayed set instead of under the worker's lock
+	failed := make(chan struct{})
+	ctxConsume, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	go func() {
+		<-failed
+		time.Sleep(200 * time.Millisecond)
+		cancel()
+	}()
+	var once sync.Once
+	if err := worker.Consume(ctxConsume, func(*common.Message) error {
+		once.Do(func() { close(failed) })
+		return errors.New("boom")
+	}); err != nil {
+		t.Fatalf("Consume failed: %v", err)
+	}
+
+	if ids := producer.client.ZRange(ctx, producer.keys.delayed, 0, -1).Val(); len(ids) != 1 || ids[0] != "1" {
+		t.Fatalf("Expected job 1 delayed, got %v", ids)
+	}
+	if n := producer.client.LLen(ctx, producer.keys.active).Val(); n != 0 {
+		t.Errorf("Expected no active jobs, got %d", n)
+	}
+	fields := producer.client.HGetAll(ctx, producer.keys.prefix+"1").Val()
+	if fields["atm"] != "1" || fields["failedReason"] != "boom" || fields["delay"] != "60000" {
+		t.Errorf("Expected 1 attempt made and the backoff recorded, got %v", fields)
+	}
+}
+
+func TestBullMQCancelReturnsJobToWait(t *testing.T) {
+	producer, worker := newTestQueues(t, DefaultBullMQOptions())
+	producer.SetRetryPolicy(common.RetryPolicy{MaxAttempts: 2, Backoff: time.Minute})
+	ctx := context.Background()
+
+	if err := producer.Produce(ctx, &common.Message{ID: "interrupted", Payload: []byte("x"), Timestamp: time.Now()}); err != nil {
+		t.Fatalf("Produce failed: %v", err)
+	}
+
+	// Cancel while the handler runs, so its error is put down to the
+	// cancellation
+	consumeN(t, worker, 1, func(*common.Message) error { return errors.New("boom") })
+
+	if ids := producer.client.LRange(ctx, producer.keys.wait, 0, -1).Val(); len(ids) != 1 || ids[0] != "1" {
+		t.Errorf("Expected the job back in the wait list, got %v", ids)
+	}
+	if n := producer.client.LLen(ctx, producer.keys.active).Val(); n != 0 {
+		t.Errorf("Expected no active jobs, got %d", n)
+	}
+}
+
+func TestBullMQLockExtended(t *testing.T) {
+	opts := DefaultBullMQOptions()
+	opts.LockDuration = 200 * time.Millisecond
+	producer, worker := newTestQueues(t, opts)
+	ctx := context.Background()
+
+	if err := producer.Produce(ctx, &common.Message{ID: "slow", Payload: []byte("x"), Timestamp: time.Now()}); err != nil {
+		t.Fatalf("Produce failed: %v", err)
+	}
+
+	lockKey := producer.keys.prefix + "1:lock"
+	var held bool
+	consumeN(t, worker, 1, func(*common.Message) error {
+		time.Sleep(3 * opts.LockDuration)
+		held = producer.client.Exists(ctx, lockKey).Val() == 1
+		return nil
+	})
+
+	if !held {
+		t.Error("Expected the lock to be extended while the job ran")
+	}
+	if ids := producer.client.ZRange(ctx, producer.keys.completed, 0, -1).Val(); len(ids) != 1 {
+		t.Errorf("Expected the job completed after its first lock expired, got %v", ids)
+	}
+}
+
+func TestBullMQUndecodableJob(t *testing.T) {
+	producer, worker := newTestQueues(t, DefaultBullMQOptions())
+	ctx := context.Background()
+
+	// A job added by another client, with data that is not a message
+	keys := []string{producer.keys.wait, producer.keys.paused, producer.keys.meta, producer.keys.id, producer.keys.events, producer.keys.marker}
+	if err := addJobScript.Run(ctx, producer.client, keys, producer.keys.prefix, jobName, "not json", `{"attempts":3}`, "").Err(); err != nil {
+		t.Fatalf("Failed to add job: %v", err)
+	}
+	if err := producer.Produce(ctx, &common.Message{ID: "valid", Payload: []byte("x"), Timestamp: time.Now()}); err != nil {
+		t.Fatalf("Produce failed: %v", err)
+	}
+
+	consumeN(t, worker, 1, succeed)
+
+	if ids := producer.client.ZRange(ctx, producer.keys.failed, 0, -1).Val(); len(ids) != 1 || ids[0] != "1" {
+		t.Errorf("Expected the undecodable job failed without retries, got %v", ids)
+	}
+	if stats := worker.FailureStats(); stats.Undecodable != 1 || stats.DeadLettered != 1 {
+		t.Errorf("Expected 1 undecodable job dead-lettered, got %+v", stats)
+	}
+}
+
+func TestBullMQStalledJobRecovered(t *testing.T) {
+	opts := DefaultBullMQOptions()
+	opts.LockDuration = 50 * time.Millisecond
+	opts.StalledInterval = 100 * time.Millisecond
+	producer, worker := newTestQueues(t, opts)
+	ctx := context.Background()
+
+	if err := producer.Produce(ctx, &common.Message{ID: "stalled", Payload: []byte("x"), Timestamp: time.Now()}); err != nil {
+		t.Fatalf("Produce failed: %v", err)
+	}
+
+	// A worker that takes the job and dies, leaving it active
+	if j, err := worker.moveToActive(ctx); err != nil || j == nil {
+		t.Fatalf("Expected to take the job, got %v and %v", j, err)
+	}
+
+	received := consumeN(t, worker, 1, succeed)
+	if received[0].ID != "stalled" {
+		t.Errorf("Expected the stalled job processed, got %s", received[0].ID)
+	}
+
+	fields := producer.client.HGetAll(ctx, producer.keys.prefix+"1").Val()
+	if fields["stc"] != "1" || fields["finishedOn"] == "" {
+		t.Errorf("Expected the job completed after stalling once, got %v", fields)
+	}
+}
@@ -0,0 +1,200 @@
+package bullmq
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/praneethys/kafka-bullmq-benchmark/pkg/common"
+)
+
+// jobName is the name of the jobs the benchmark adds, which BullMQ
+// processors see as job.name
+const jobName = "benchmark"
+
+// headersField is the job hash field that carries message headers as a JSON
+// object. BullMQ has no job headers and ignores the field.
+const headersField = "headers"
+
+// job is a job taken by a worker, with the hash fields moveToActive returned
+type job struct {
+	id       string
+	token    string // holds the job lock
+	fields   map[string]string
+	received time.Time
+}
+
+// jobOptions is the subset of BullMQ's JobsOptions the benchmark sets, so
+// BullMQ workers processing its jobs retry and clean up the same way
+type jobOptions struct {
+	Attempts         int         `json:"attempts"`
+	Backoff          *jobBackoff `json:"backoff,omitempty"`
+	RemoveOnComplete interface{} `json:"removeOnComplete,omitempty"`
+	RemoveOnFail     interface{} `json:"removeOnFail,omitempty"`
+}
+
+// jobBackoff is BullMQ's BackoffOptions
+type jobBackoff struct {
+	Type  string `json:"type"`
+	Delay int64  `json:"delay"` // milliseconds
+}
+
+// UnmarshalJSON also accepts BullMQ's shorthand for a fixed backoff, the
+// delay as a plain number
+func (b *jobBackoff) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &b.Delay); err == nil {
+		b.Type = "fixed"
+		return nil
+	}
+	type plain jobBackoff
+	return json.Unmarshal(data, (*plain)(b))
+}
+
+// delay returns the wait before the next attempt of a job that failed
+// attemptsMade times, as BullMQ computes it for its built-in backoff types
+func (b *jobBackoff) delay(attemptsMade int) time.Duration {
+	if b == nil {
+		return 0
+	}
+	delay := float64(b.Delay)
+	if b.Type == "exponential" {
+		delay *= math.Pow(2, float64(attemptsMade-1))
+	}
+	return time.Duration(math.Round(delay)) * time.Millisecond
+}
+
+// retryOptions returns the attempts and backoff in the opts field of j,
+// which BullMQ workers retry the job by. A job without valid options is
+// attempted once.
+func (j *job) retryOptions() jobOptions {
+	var opts jobOptions
+	if err := json.Unmarshal([]byte(j.fields["opts"]), &opts); err != nil || opts.Attempts < 1 {
+		return jobOptions{Attempts: 1}
+	}
+	return opts
+}
+
+// attemptsMade returns how often j was attempted before this delivery
+func (j *job) attemptsMade() int {
+	n, _ := strconv.Atoi(j.fields["atm"]) //nolint:errcheck // A job never attempted has no atm field
+	return n
+}
+
+// encodeJobOptions returns the opts field of the jobs added with retry and
+// options. BullMQ's exponential backoff doubles the delay for every retry,
+// like common.RetryPolicy.
+func encodeJobOptions(retry common.RetryPolicy, options BullMQOptions) (string, error) {
+	opts := jobOptions{
+		Attempts:         max(retry.MaxAttempts, 1),
+		RemoveOnComplete: removeOption(options.KeepCompleted),
+		RemoveOnFail:     removeOption(options.KeepFailed),
+	}
+	if retry.Backoff > 0 {
+		opts.Backoff = &jobBackoff{Type: "exponential", Delay: retry.Backoff.Milliseconds()}
+	}
+
+	data, err := json.Marshal(opts)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal job options: %w", err)
+	}
+	return string(data), nil
+}
+
+// removeOption converts a count of finished jobs to keep into BullMQ's
+// removeOnComplete and removeOnFail: nil (keep all) for -1, true for 0 and
+// the count otherwise
+func removeOption(keep int) interface{} {
+	switch {
+	case keep < 0:
+		return nil
+	case keep == 0:
+		return true
+	default:
+		return keep
+	}
+}
+
+// encodeJob serializes msg into the data and headers of its job and records
+// the time spent doing so in msg.Trace
+func encodeJob(codec common.Codec, msg *common.Message) (data, headers string, err error) {
+	start := time.Now()
+
+	env, err := codec.Encode(msg)
+	if err != nil {
+		return "", "", err
+	}
+
+	if len(env.Headers) > 0 {
+		encoded, err := json.Marshal(env.Headers)
+		if err != nil {
+			return "", "", fmt.Errorf("failed to marshal headers: %w", err)
+		}
+		headers = string(encoded)
+	}
+
+	msg.Trace.EncodeTime = time.Since(start)
+	return string(env.Value), headers, nil
+}
+
+// decodeJob deserializes the job's data and records the time spent doing so
+// in the result's Trace. The job timestamp, set by the server when the job
+// was added, becomes the broker time.
+func decodeJob(codec common.Codec, j *job) (*common.Message, error) {
+	start := time.Now()
+
+	data, ok := j.fields["data"]
+	if !ok {
+		return nil, fmt.Errorf("job %s has no data field", j.id)
+	}
+
+	env := &common.Envelope{Value: []byte(data)}
+	if headers := j.fields[headersField]; headers != "" {
+		if err := json.Unmarshal([]byte(headers), &env.Headers); err != nil {
+			return nil, fmt.Errorf("job %s has invalid headers: %w", j.id, err)
+		}
+	}
+
+	msg, err := codec.Decode(env)
+	if err != nil {
+		return nil, err
+	}
+
+	msg.Trace.DecodeTime = time.Since(start)
+	msg.Trace.ReceiveTime = j.received
+	if ms, err := strconv.ParseInt(j.fields["timestamp"], 10, 64); err == nil {
+		msg.Trace.BrokerTime = time.UnixMilli(ms)
+	}
+	return msg, nil
+}
+
+// parseJobReply reads the reply of moveToActiveScript or
+// moveToFinishedScript: the ID and fields of the job taken, 0 if none was
+// taken, or a negative error code about jobID
+func parseJobReply(reply interface{}, jobID, token string) (*job, error) {
+	switch reply := reply.(type) {
+	case int64:
+		if reply < 0 {
+			return nil, scriptError(reply, jobID)
+		}
+		return nil, nil
+	case []interface{}:
+		if len(reply) != 2 {
+			break
+		}
+		id, ok := reply[0].(string)
+		values, ok2 := reply[1].([]interface{})
+		if !ok || !ok2 {
+			break
+		}
+
+		j := &job{id: id, token: token, fields: make(map[string]string, len(values)/2), received: time.Now()}
+		for i := 0; i+1 < len(values); i += 2 {
+			field, _ := values[i].(string)
+			j.fields[field], _ = values[i+1].(string)
+		}
+		return j, nil
+	}
+	return nil, fmt.Errorf("unexpected job reply %v", reply)
+}
@@ -0,0 +1,159 @@
+package bullmq
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/praneethys/kafka-bullmq-benchmark/pkg/common"
+)
+
+func TestEncodeJobOptions(t *testing.T) {
+	tests := []struct {
+		name     string
+		retry    common.RetryPolicy
+		complete int
+		fail     int
+		want     string
+	}{
+		{name: "defaults", complete: -1, fail: -1, want: `{"attempts":1}`},
+		{name: "remove", complete: 0, fail: 0, want: `{"attempts":1,"removeOnComplete":true,"removeOnFail":true}`},
+		{name: "keep count", complete: 1000, fail: -1, want: `{"attempts":1,"removeOnComplete":1000}`},
+		{
+			name:     "retries",
+			retry:    common.RetryPolicy{MaxAttempts: 3, Backoff: 100 * time.Millisecond},
+			complete: -1,
+			fail:     -1,
+			want:     `{"attempts":3,"backoff":{"type":"exponential","delay":100}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := BullMQOptions{KeepCompleted: tt.complete, KeepFailed: tt.fail}
+			got, err := encodeJobOptions(tt.retry, options)
+			if err != nil {
+				t.Fatalf("encodeJobOptions failed: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestJobRetryOptions(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     string
+		attempts int
+		delays   []time.Duration // after 1, 2 and 3 attempts made
+	}{
+		{name: "missing", opts: "", attempts: 1, delays: []time.Duration{0, 0, 0}},
+		{name: "no backoff", opts: `{"attempts":3}`, attempts: 3, delays: []time.Duration{0, 0, 0}},
+		{
+			name:     "exponential",
+			opts:     `{"attempts":4,"backoff":{"type":"exponential","delay":100}}`,
+			attempts: 4,
+			delays:   []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond},
+		},
+		{
+			name:     "fixed shorthand",
+			opts:     `{"attempts":2,"backoff":50}`,
+			attempts: 2,
+			delays:   []time.Duration{50 * time.Millisecond, 50 * time.Millisecond, 50 * time.Millisecond},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := (&job{fields: map[string]string{"opts": tt.opts}}).retryOptions()
+			if opts.Attempts != tt.attempts {
+				t.Errorf("Expected %d attempts, got %d", tt.attempts, opts.Attempts)
+			}
+			for i, want := range tt.delays {
+				if got := opts.Backoff.delay(i + 1); got != want {
+					t.Errorf("Expected a %v backoff after %d attempts, got %v", want, i+1, got)
+				}
+			}
+		})
+	}
+
+	if made := (&job{fields: map[string]string{"atm": "2"}}).attemptsMade(); made != 2 {
+		t.Errorf("Expected 2 attempts made, got %d", made)
+	}
+}
+
+func TestEncodeDecodeJob(t *testing.T) {
+	codec := common.JSONCodec{}
+	msg := &common.Message{
+		ID:        "msg-1",
+		Payload:   []byte("payload"),
+		Timestamp: time.Now(),
+		Headers:   map[string]string{"trace-id": "abc"},
+	}
+
+	data, headers, err := encodeJob(codec, msg)
+	if err != nil {
+		t.Fatalf("encodeJob failed: %v", err)
+	}
+
+	// BullMQ processors parse job.data as JSON
+	if !json.Valid([]byte(data)) {
+		t.Errorf("Expected JSON job data, got %q", data)
+	}
+
+	received := time.Now()
+	j := &job{
+		id:       "7",
+		fields:   map[string]string{"data": data, headersField: headers, "timestamp": "1700000000123"},
+		received: received,
+	}
+	decoded, err := decodeJob(codec, j)
+	if err != nil {
+		t.Fatalf("decodeJob failed: %v", err)
+	}
+
+	if decoded.ID != msg.ID || string(decoded.Payload) != "payload" || decoded.Headers["trace-id"] != "abc" {
+		t.Errorf("Expected the message back with its headers, got %+v", decoded)
+	}
+
+	if !decoded.Trace.BrokerTime.Equal(time.UnixMilli(1700000000123)) || !decoded.Trace.ReceiveTime.Equal(received) {
+		t.Errorf("Expected the job timestamp and receive time in the trace, got %+v", decoded.Trace)
+	}
+}
+
+func TestDecodeJobErrors(t *testing.T) {
+	codec := common.JSONCodec{}
+
+	if _, err := decodeJob(codec, &job{id: "1", fields: map[string]string{}}); err == nil {
+		t.Error("Expected an error for a job without data")
+	}
+
+	bad := &job{id: "2", fields: map[string]string{"data": "{}", headersField: "not json"}}
+	if _, err := decodeJob(codec, bad); err == nil {
+		t.Error("Expected an error for invalid headers")
+	}
+}
+
+func TestParseJobReply(t *testing.T) {
+	j, err := parseJobReply([]interface{}{"42", []interface{}{"name", jobName, "data", "{}"}}, "", "token")
+	if err != nil {
+		t.Fatalf("parseJobReply failed: %v", err)
+	}
+	if j.id != "42" || j.token != "token" || j.fields["name"] != jobName || j.fields["data"] != "{}" {
+		t.Errorf("Expected job 42 with its fields, got %+v", j)
+	}
+
+	if j, err := parseJobReply(int64(0), "1", "token"); j != nil || err != nil {
+		t.Errorf("Expected no job and no error for 0, got %v, %v", j, err)
+	}
+
+	if _, err := parseJobReply(int64(-6), "1", "token"); err == nil {
+		t.Error("Expected an error for a lock held by another worker")
+	}
+
+	if _, err := parseJobReply("unexpected", "1", "token"); err == nil {
+		t.Error("Expected an error for an unexpected reply")
+	}
+}
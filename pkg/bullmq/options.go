@@ -0,0 +1,119 @@
+package bullmq
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	redisqueue "github.com/praneethys/kafka-bullmq-benchmark/pkg/redis"
+)
+
+// BullMQOptions holds the settings a BullMQQueue is created with. Producers
+// and workers of the same queue should share them, since producers record
+// KeepCompleted and KeepFailed in the options of every job they add.
+type BullMQOptions struct {
+	// Prefix starts every key; BullMQ's default is "bull"
+	Prefix string
+	// LockDuration is how long a worker owns an active job before BullMQ
+	// considers it stalled. Workers extend the lock every half duration
+	// while the job runs.
+	LockDuration time.Duration
+	// StalledInterval is how often workers look for active jobs whose lock
+	// has expired, the jobs of workers that died. MaxStalledCount is how
+	// often a job may stall before it is failed instead of moved back to
+	// the wait list.
+	StalledInterval time.Duration
+	MaxStalledCount int
+	// KeepCompleted and KeepFailed are the finished jobs kept in the
+	// completed and failed sets, like BullMQ's removeOnComplete and
+	// removeOnFail counts: -1 keeps all, 0 removes each job once finished
+	KeepCompleted int
+	KeepFailed    int
+	// Connection holds the credentials and TLS settings
+	Connection redisqueue.RedisOptions
+}
+
+// DefaultBullMQOptions returns BullMQ's own defaults, except that only the
+// latest 1000 completed jobs are kept, so long runs do not fill the server
+// with finished jobs
+func DefaultBullMQOptions() BullMQOptions {
+	return BullMQOptions{
+		Prefix:          "bull",
+		LockDuration:    30 * time.Second,
+		StalledInterval: 30 * time.Second,
+		MaxStalledCount: 1,
+		KeepCompleted:   1000,
+		KeepFailed:      -1,
+	}
+}
+
+// validate checks the settings before connecting
+func (o BullMQOptions) validate() error {
+	if o.Prefix == "" {
+		return fmt.Errorf("BullMQ key prefix must not be empty")
+	}
+	if o.LockDuration < 10*time.Millisecond {
+		return fmt.Errorf("BullMQ lock duration must be at least 10ms, got %v", o.LockDuration)
+	}
+	if o.StalledInterval < 10*time.Millisecond {
+		return fmt.Errorf("BullMQ stalled interval must be at least 10ms, got %v", o.StalledInterval)
+	}
+	if o.MaxStalledCount < 0 {
+		return fmt.Errorf("BullMQ max stalled count must not be negative, got %d", o.MaxStalledCount)
+	}
+	if o.KeepCompleted < -1 || o.KeepFailed < -1 {
+		return fmt.Errorf("finished BullMQ jobs to keep must be -1 (all) or more")
+	}
+	return nil
+}
+
+// reportedConfig describes the queue settings for the benchmark report,
+// with the password masked
+func (o BullMQOptions) reportedConfig(addr, queueName string) map[string]string {
+	config := o.Connection.ReportedConfig(addr)
+	config["queue"] = queueName
+	config["prefix"] = o.Prefix
+	config["lock_duration"] = o.LockDuration.String()
+	config["stalled_interval"] = o.StalledInterval.String()
+	config["max_stalled_count"] = strconv.Itoa(o.MaxStalledCount)
+	config["keep_completed"] = strconv.Itoa(o.KeepCompleted)
+	config["keep_failed"] = strconv.Itoa(o.KeepFailed)
+	return config
+}
+
+// keys holds the Redis keys of one BullMQ queue, all named
+// <prefix>:<queue>:<suffix>
+type keys struct {
+	prefix       string // "<prefix>:<queue>:", which job IDs are appended to
+	wait         string // list of waiting job IDs, oldest at the tail
+	paused       string // waiting jobs while the queue is paused
+	active       string // list of jobs taken by workers
+	delayed      string // sorted set of jobs waiting out a retry backoff
+	completed    string // sorted set of completed jobs by finish time
+	failed       string // sorted set of failed jobs by finish time
+	stalled      string // set of active jobs BullMQ's stall check suspects
+	stalledCheck string // held for an interval by the worker that ran the stall check
+	meta         string // queue settings such as paused and opts.maxLenEvents
+	id           string // counter of job IDs
+	events       string // stream of job lifecycle events
+	marker       string // sorted set workers block on for new jobs
+}
+
+func newKeys(prefix, queueName string) keys {
+	base := prefix + ":" + queueName + ":"
+	return keys{
+		prefix:       base,
+		wait:         base + "wait",
+		paused:       base + "paused",
+		active:       base + "active",
+		delayed:      base + "delayed",
+		completed:    base + "completed",
+		failed:       base + "failed",
+		stalled:      base + "stalled",
+		stalledCheck: base + "stalled-check",
+		meta:         base + "meta",
+		id:           base + "id",
+		events:       base + "events",
+		marker:       base + "marker",
+	}
+}
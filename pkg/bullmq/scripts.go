@@ -0,0 +1,357 @@
+package bullmq
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// The scripts below implement the parts of BullMQ's job lifecycle the
+// benchmark uses, following the Lua scripts BullMQ 5 ships: addStandardJob,
+// moveToActive, moveToFinished, moveToDelayed, retryJob, extendLock,
+// moveJobFromActiveToWait and moveStalledJobsToWait. Job
+// keys are built from the key prefix passed in ARGV[1], as BullMQ does.
+// Timestamps come from the Redis server clock.
+
+// scriptHelpers holds the local functions shared by the scripts
+const scriptHelpers = `
+local rcall = redis.call
+
+-- now returns the server time in milliseconds
+local function now()
+  local t = rcall("TIME")
+  return tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+end
+
+-- maxEvents returns the approximate length the events stream is trimmed to
+local function maxEvents(metaKey)
+  return tonumber(rcall("HGET", metaKey, "opts.maxLenEvents")) or 10000
+end
+
+local function removeJob(jobKey)
+  rcall("DEL", jobKey, jobKey .. ":logs")
+end
+
+-- removeLock releases the job lock if token holds it, returning 0, or -2 if
+-- the lock is gone and -6 if another worker holds it
+local function removeLock(jobKey, stalledKey, token, jobId)
+  local lockKey = jobKey .. ":lock"
+  local lockToken = rcall("GET", lockKey)
+  if lockToken ~= token then
+    if not lockToken then
+      return -2
+    end
+    return -6
+  end
+  rcall("DEL", lockKey)
+  rcall("SREM", stalledKey, jobId)
+  return 0
+end
+
+-- addToWait queues a job to be taken by a worker, on the paused list while
+-- the queue is paused. push is LPUSH for the back of the queue and RPUSH
+-- for the front.
+local function addToWait(waitKey, pausedKey, metaKey, markerKey, push, jobId)
+  if rcall("HEXISTS", metaKey, "paused") == 1 then
+    rcall(push, pausedKey, jobId)
+  else
+    rcall(push, waitKey, jobId)
+    rcall("ZADD", markerKey, 0, "0")
+  end
+end
+
+-- addFinished adds a job to the completed or failed set, keeping at most
+-- keep jobs there (-1 keeps all, 0 removes the job instead)
+local function addFinished(setKey, prefix, jobId, keep, timestamp)
+  if keep == 0 then
+    removeJob(prefix .. jobId)
+    return
+  end
+  rcall("ZADD", setKey, timestamp, jobId)
+  if keep > 0 then
+    local old = rcall("ZREVRANGE", setKey, keep, -1)
+    for _, id in ipairs(old) do
+      removeJob(prefix .. id)
+    end
+    if #old > 0 then
+      rcall("ZREMRANGEBYRANK", setKey, 0, -(keep + 1))
+    end
+  end
+end
+
+-- delayedScore returns the score of a job due at timestamp in the delayed
+-- set, which BullMQ packs with the low bits of the job ID
+local function delayedScore(timestamp, jobId)
+  return timestamp * 0x1000 + (tonumber(jobId) or 0) % 0x1000
+end
+
+-- promoteDelayed moves the delayed jobs that are due to the wait list, as
+-- BullMQ's promoteDelayedJobs does
+local function promoteDelayed(delayedKey, waitKey, eventsKey, metaKey, prefix)
+  local due = rcall("ZRANGEBYSCORE", delayedKey, 0, delayedScore(now() + 1, 0) - 1, "LIMIT", 0, 1000)
+  for _, jobId in ipairs(due) do
+    rcall("ZREM", delayedKey, jobId)
+    rcall("LPUSH", waitKey, jobId)
+    rcall("HSET", prefix .. jobId, "delay", 0)
+    rcall("XADD", eventsKey, "MAXLEN", "~", maxEvents(metaKey), "*", "event", "waiting", "jobId", jobId, "prev", "delayed")
+  end
+end
+
+-- takeJob promotes the delayed jobs that are due, then moves the oldest
+-- waiting job to the active list and locks it for the worker, returning its
+-- ID and fields, or nil if no job is waiting
+local function takeJob(waitKey, activeKey, eventsKey, metaKey, markerKey, delayedKey, prefix, token, lockDuration, worker)
+  if rcall("HEXISTS", metaKey, "paused") == 1 then
+    return nil
+  end
+  promoteDelayed(delayedKey, waitKey, eventsKey, metaKey, prefix)
+  local jobId = rcall("RPOPLPUSH", waitKey, activeKey)
+  if not jobId then
+    return nil
+  end
+  local jobKey = prefix .. jobId
+  rcall("SET", jobKey .. ":lock", token, "PX", lockDuration)
+  rcall("XADD", eventsKey, "MAXLEN", "~", maxEvents(metaKey), "*", "event", "active", "jobId", jobId, "prev", "waiting")
+  rcall("HSET", jobKey, "processedOn", now(), "pb", worker)
+  rcall("HINCRBY", jobKey, "ats", 1)
+  -- Wake another worker while jobs are left
+  if rcall("LLEN", waitKey) > 0 then
+    rcall("ZADD", markerKey, 0, "0")
+  end
+  return {jobId, rcall("HGETALL", jobKey)}
+end
+`
+
+// addJobScript stores a job and appends it to the wait list, or to the
+// paused list while the queue is paused. It returns the job ID.
+//
+// KEYS: wait, paused, meta, id, events, marker
+// ARGV: key prefix, job name, data, opts, headers (empty for none)
+var addJobScript = redis.NewScript(scriptHelpers + `
+local jobId = tostring(rcall("INCR", KEYS[4]))
+local jobKey = ARGV[1] .. jobId
+local limit = maxEvents(KEYS[3])
+
+rcall("HSET", jobKey, "name", ARGV[2], "data", ARGV[3], "opts", ARGV[4],
+  "timestamp", now(), "delay", 0, "priority", 0)
+if ARGV[5] ~= "" then
+  rcall("HSET", jobKey, "headers", ARGV[5])
+end
+rcall("XADD", KEYS[5], "MAXLEN", "~", limit, "*", "event", "added", "jobId", jobId, "name", ARGV[2])
+
+if rcall("HEXISTS", KEYS[3], "paused") == 1 then
+  rcall("LPUSH", KEYS[2], jobId)
+else
+  rcall("LPUSH", KEYS[1], jobId)
+  rcall("ZADD", KEYS[6], 0, "0")
+end
+rcall("XADD", KEYS[5], "MAXLEN", "~", limit, "*", "event", "waiting", "jobId", jobId)
+
+return jobId
+`)
+
+// moveToActiveScript takes the next waiting job, returning its ID and
+// fields, or 0 if there is none
+//
+// KEYS: wait, active, events, meta, marker, delayed
+// ARGV: key prefix, lock token, lock duration (ms), worker name
+var moveToActiveScript = redis.NewScript(scriptHelpers + `
+return takeJob(KEYS[1], KEYS[2], KEYS[3], KEYS[4], KEYS[5], KEYS[6], ARGV[1], ARGV[2], ARGV[3], ARGV[4]) or 0
+`)
+
+// moveToFinishedScript releases an active job into the completed or failed
+// set, keeping at most the given number of finished jobs there (-1 keeps
+// all, 0 removes the job). With fetchNext set it then takes the next waiting
+// job, saving the worker a round trip, and returns it like
+// moveToActiveScript. Otherwise it returns 0, or a negative error code.
+//
+// KEYS: active, events, meta, stalled, completed or failed, wait, marker,
+// delayed
+// ARGV: key prefix, job ID, lock token, "returnvalue" or "failedReason",
+// value, "completed" or "failed", jobs to keep, fetchNext ("1" or "0"),
+// next lock token, lock duration (ms), worker name
+var moveToFinishedScript = redis.NewScript(scriptHelpers + `
+local prefix = ARGV[1]
+local jobId = ARGV[2]
+local jobKey = prefix .. jobId
+if rcall("EXISTS", jobKey) == 0 then
+  return -1
+end
+local code = removeLock(jobKey, KEYS[4], ARGV[3], jobId)
+if code < 0 then
+  return code
+end
+if rcall("LREM", KEYS[1], -1, jobId) == 0 then
+  return -3
+end
+
+local keep = tonumber(ARGV[7])
+local timestamp = now()
+rcall("HINCRBY", jobKey, "atm", 1)
+rcall("HSET", jobKey, ARGV[4], ARGV[5], "finishedOn", timestamp)
+addFinished(KEYS[5], prefix, jobId, keep, timestamp)
+rcall("XADD", KEYS[2], "MAXLEN", "~", maxEvents(KEYS[3]), "*", "event", ARGV[6], "jobId", jobId, ARGV[4], ARGV[5])
+
+if ARGV[8] == "1" then
+  return takeJob(KEYS[6], KEYS[1], KEYS[2], KEYS[3], KEYS[7], KEYS[8], prefix, ARGV[9], ARGV[10], ARGV[11]) or 0
+end
+return 0
+`)
+
+// retryJobScript releases an active job whose attempt failed so it is
+// attempted again: into the delayed set until its backoff has passed, or
+// straight back to the wait list without a backoff, as BullMQ's
+// moveToDelayed and retryJob do. It returns 0 or a negative error code.
+//
+// KEYS: active, wait, paused, delayed, events, meta, marker, stalled
+// ARGV: key prefix, job ID, lock token, failed reason, backoff (ms)
+var retryJobScript = redis.NewScript(scriptHelpers + `
+local jobId = ARGV[2]
+local jobKey = ARGV[1] .. jobId
+if rcall("EXISTS", jobKey) == 0 then
+  return -1
+end
+local code = removeLock(jobKey, KEYS[8], ARGV[3], jobId)
+if code < 0 then
+  return code
+end
+if rcall("LREM", KEYS[1], -1, jobId) == 0 then
+  return -3
+end
+
+rcall("HINCRBY", jobKey, "atm", 1)
+rcall("HSET", jobKey, "failedReason", ARGV[4])
+local limit = maxEvents(KEYS[6])
+local delay = tonumber(ARGV[5])
+if delay > 0 then
+  local due = now() + delay
+  rcall("ZADD", KEYS[4], delayedScore(due, jobId), jobId)
+  rcall("HSET", jobKey, "delay", delay)
+  rcall("XADD", KEYS[5], "MAXLEN", "~", limit, "*", "event", "delayed", "jobId", jobId, "delay", due)
+  -- Tell workers when the earliest delayed job is due
+  local marked = rcall("ZSCORE", KEYS[7], "1")
+  if not marked or tonumber(marked) > due then
+    rcall("ZADD", KEYS[7], due, "1")
+  end
+else
+  addToWait(KEYS[2], KEYS[3], KEYS[6], KEYS[7], "LPUSH", jobId)
+  rcall("XADD", KEYS[5], "MAXLEN", "~", limit, "*", "event", "waiting", "jobId", jobId, "prev", "failed")
+end
+return 0
+`)
+
+// moveToWaitScript hands an active job back to the head of the wait list,
+// where the next worker takes it from. It returns 0 or a negative error code.
+//
+// KEYS: active, wait, paused, events, meta, marker, stalled
+// ARGV: key prefix, job ID, lock token
+var moveToWaitScript = redis.NewScript(scriptHelpers + `
+local jobId = ARGV[2]
+local code = removeLock(ARGV[1] .. jobId, KEYS[7], ARGV[3], jobId)
+if code < 0 then
+  return code
+end
+if rcall("LREM", KEYS[1], -1, jobId) == 0 then
+  return -3
+end
+
+addToWait(KEYS[2], KEYS[3], KEYS[5], KEYS[6], "RPUSH", jobId)
+rcall("XADD", KEYS[4], "MAXLEN", "~", maxEvents(KEYS[5]), "*", "event", "waiting", "jobId", jobId, "prev", "active")
+return 0
+`)
+
+// extendLockScript renews a job lock held by token, returning 1, or 0 if
+// the lock was lost
+//
+// KEYS: lock, stalled
+// ARGV: lock token, lock duration (ms), job ID
+var extendLockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+  redis.call("SET", KEYS[1], ARGV[1], "PX", ARGV[2])
+  redis.call("SREM", KEYS[2], ARGV[3])
+  return 1
+end
+return 0
+`)
+
+// moveStalledScript recovers the jobs of workers that died, as BullMQ's
+// moveStalledJobsToWait does. Active jobs marked by the previous check whose
+// lock has expired since go back to the wait list, or to the failed set once
+// they stalled more than the allowed number of times. All active jobs are
+// then marked for the next check; workers holding their lock unmark them
+// when they extend or release it. The check runs at most once per interval
+// across all workers. It returns the number of jobs failed and moved back.
+//
+// KEYS: stalled, wait, active, failed, stalled-check, meta, paused, marker,
+// events
+// ARGV: key prefix, max stalled count, failed jobs to keep, interval (ms)
+var moveStalledScript = redis.NewScript(scriptHelpers + `
+if rcall("SET", KEYS[5], now(), "PX", ARGV[4], "NX") == false then
+  return {0, 0}
+end
+
+local prefix = ARGV[1]
+local maxStalled = tonumber(ARGV[2])
+local limit = maxEvents(KEYS[6])
+local failed, recovered = 0, 0
+for _, jobId in ipairs(rcall("SMEMBERS", KEYS[1])) do
+  local jobKey = prefix .. jobId
+  if rcall("EXISTS", jobKey .. ":lock") == 0 and rcall("LREM", KEYS[3], 1, jobId) > 0 then
+    if rcall("HINCRBY", jobKey, "stc", 1) > maxStalled then
+      local reason = "job stalled more than allowable limit"
+      local timestamp = now()
+      rcall("HSET", jobKey, "failedReason", reason, "finishedOn", timestamp)
+      addFinished(KEYS[4], prefix, jobId, tonumber(ARGV[3]), timestamp)
+      rcall("XADD", KEYS[9], "MAXLEN", "~", limit, "*", "event", "failed", "jobId", jobId, "failedReason", reason, "prev", "active")
+      failed = failed + 1
+    else
+      addToWait(KEYS[2], KEYS[7], KEYS[6], KEYS[8], "RPUSH", jobId)
+      rcall("XADD", KEYS[9], "MAXLEN", "~", limit, "*", "event", "waiting", "jobId", jobId, "prev", "active")
+      rcall("XADD", KEYS[9], "MAXLEN", "~", limit, "*", "event", "stalled", "jobId", jobId)
+      recovered = recovered + 1
+    end
+  end
+end
+rcall("DEL", KEYS[1])
+
+local active = rcall("LRANGE", KEYS[3], 0, -1)
+for from = 1, #active, 5000 do
+  rcall("SADD", KEYS[1], unpack(active, from, math.min(from + 4999, #active)))
+end
+return {failed, recovered}
+`)
+
+// scripts lists every script, for loading them up front
+var scripts = []*redis.Script{
+	addJobScript, moveToActiveScript, moveToFinishedScript, retryJobScript,
+	moveToWaitScript, extendLockScript, moveStalledScript,
+}
+
+// loadScripts caches the scripts on the server, so pipelines can run them
+// with EVALSHA
+func loadScripts(ctx context.Context, client *redis.Client) error {
+	for _, script := range scripts {
+		if err := script.Load(ctx, client).Err(); err != nil {
+			return fmt.Errorf("failed to load BullMQ script: %w", err)
+		}
+	}
+	return nil
+}
+
+// scriptError describes the negative codes the scripts return, which are
+// the codes BullMQ uses for the same conditions
+func scriptError(code int64, jobID string) error {
+	switch code {
+	case -1:
+		return fmt.Errorf("job %s does not exist", jobID)
+	case -2:
+		return fmt.Errorf("lock of job %s is missing", jobID)
+	case -3:
+		return fmt.Errorf("job %s is not in the active list", jobID)
+	case -6:
+		return fmt.Errorf("lock of job %s is held by another worker", jobID)
+	default:
+		return fmt.Errorf("job %s: unexpected script reply %d", jobID, code)
+	}
+}
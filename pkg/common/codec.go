@@ -0,0 +1,270 @@
+package common
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Envelope is the wire form of a Message: an opaque body plus string
+// attributes that the broker carries natively (Kafka record headers, Redis
+// stream fields)
+type Envelope struct {
+	Value   []byte
+	Headers map[string]string
+}
+
+// Codec converts messages to and from their wire form
+type Codec interface {
+	Name() string
+	Encode(msg *Message) (*Envelope, error)
+	Decode(env *Envelope) (*Message, error)
+}
+
+// JSONCodec serializes the whole message as JSON. It is the simplest format
+// to inspect, but base64 inflates the payload by about a third.
+type JSONCodec struct{}
+
+func (JSONCodec) Name() string {
+	return "json"
+}
+
+func (JSONCodec) Encode(msg *Message) (*Envelope, error) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal message: %w", err)
+	}
+	return &Envelope{Value: data, Headers: msg.Headers}, nil
+}
+
+func (JSONCodec) Decode(env *Envelope) (*Message, error) {
+	var msg Message
+	if err := json.Unmarshal(env.Value, &msg); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal message: %w", err)
+	}
+	msg.Headers = env.Headers
+	return &msg, nil
+}
+
+// BinaryCodec packs the message into a compact length-prefixed frame:
+//
+//	uvarint len(ID) | ID | int64 big-endian UnixNano timestamp |
+//	uvarint len(RunID) | RunID | uvarint ProducerID | uvarint Sequence |
+//	uvarint len(Key) | Key | uvarint KeySequence | payload
+//
+// The payload needs no length prefix since it runs to the end of the frame.
+type BinaryCodec struct{}
+
+func (BinaryCodec) Name() string {
+	return "binary"
+}
+
+func (BinaryCodec) Encode(msg *Message) (*Envelope, error) {
+	buf := make([]byte, 0, 6*binary.MaxVarintLen64+len(msg.ID)+8+len(msg.RunID)+len(msg.Key)+len(msg.Payload))
+	buf = binary.AppendUvarint(buf, uint64(len(msg.ID)))
+	buf = append(buf, msg.ID...)
+	buf = binary.BigEndian.AppendUint64(buf, uint64(msg.Timestamp.UnixNano()))
+	buf = binary.AppendUvarint(buf, uint64(len(msg.RunID)))
+	buf = append(buf, msg.RunID...)
+	buf = binary.AppendUvarint(buf, uint64(msg.ProducerID))
+	buf = binary.AppendUvarint(buf, msg.Sequence)
+	buf = binary.AppendUvarint(buf, uint64(len(msg.Key)))
+	buf = append(buf, msg.Key...)
+	buf = binary.AppendUvarint(buf, msg.KeySequence)
+	buf = append(buf, msg.Payload...)
+	return &Envelope{Value: buf, Headers: msg.Headers}, nil
+}
+
+func (BinaryCodec) Decode(env *Envelope) (*Message, error) {
+	r := frameReader{data: env.Value}
+
+	msg := &Message{Headers: env.Headers}
+	msg.ID = string(r.bytes())
+	msg.Timestamp = time.Unix(0, int64(r.fixed64()))
+	msg.RunID = string(r.bytes())
+	msg.ProducerID = int(r.uvarint())
+	msg.Sequence = r.uvarint()
+	msg.Key = string(r.bytes())
+	msg.KeySequence = r.uvarint()
+
+	if r.err != nil {
+		return nil, r.err
+	}
+	msg.Payload = r.data
+	return msg, nil
+}
+
+var errTruncatedFrame = errors.New("failed to decode message: truncated frame")
+
+// frameReader reads BinaryCodec fields in order. The first error sticks, so
+// callers only check it once at the end.
+type frameReader struct {
+	data []byte
+	err  error
+}
+
+func (r *frameReader) uvarint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(r.data)
+	if n <= 0 {
+		r.err = errTruncatedFrame
+		return 0
+	}
+	r.data = r.data[n:]
+	return v
+}
+
+func (r *frameReader) fixed64() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	if len(r.data) < 8 {
+		r.err = errTruncatedFrame
+		return 0
+	}
+	v := binary.BigEndian.Uint64(r.data)
+	r.data = r.data[8:]
+	return v
+}
+
+func (r *frameReader) bytes() []byte {
+	n := r.uvarint()
+	if r.err != nil {
+		return nil
+	}
+	if uint64(len(r.data)) < n {
+		r.err = errTruncatedFrame
+		return nil
+	}
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b
+}
+
+// Header names used by RawCodec to carry message metadata
+const (
+	RawHeaderID          = "bench-id"
+	RawHeaderTimestamp   = "bench-ts"
+	RawHeaderRunID       = "bench-run"
+	RawHeaderProducer    = "bench-producer"
+	RawHeaderSequence    = "bench-seq"
+	RawHeaderKey         = "bench-key"
+	RawHeaderKeySequence = "bench-key-seq"
+)
+
+// rawHeaders is the set of header names reserved by RawCodec
+var rawHeaders = map[string]bool{
+	RawHeaderID:          true,
+	RawHeaderTimestamp:   true,
+	RawHeaderRunID:       true,
+	RawHeaderProducer:    true,
+	RawHeaderSequence:    true,
+	RawHeaderKey:         true,
+	RawHeaderKeySequence: true,
+}
+
+// RawCodec sends the payload untouched as the body and moves the message
+// metadata into headers, which is how most production services frame their
+// messages. Run, sequence and key headers are only sent when set.
+type RawCodec struct{}
+
+func (RawCodec) Name() string {
+	return "raw"
+}
+
+func (RawCodec) Encode(msg *Message) (*Envelope, error) {
+	headers := make(map[string]string, len(msg.Headers)+len(rawHeaders))
+	for key, value := range msg.Headers {
+		headers[key] = value
+	}
+	headers[RawHeaderID] = msg.ID
+	headers[RawHeaderTimestamp] = strconv.FormatInt(msg.Timestamp.UnixNano(), 10)
+
+	if msg.RunID != "" {
+		headers[RawHeaderRunID] = msg.RunID
+	}
+	if msg.Sequence != 0 {
+		headers[RawHeaderProducer] = strconv.Itoa(msg.ProducerID)
+		headers[RawHeaderSequence] = strconv.FormatUint(msg.Sequence, 10)
+	}
+	if msg.Key != "" {
+		headers[RawHeaderKey] = msg.Key
+		headers[RawHeaderKeySequence] = strconv.FormatUint(msg.KeySequence, 10)
+	}
+
+	return &Envelope{Value: msg.Payload, Headers: headers}, nil
+}
+
+func (RawCodec) Decode(env *Envelope) (*Message, error) {
+	ts, err := strconv.ParseInt(env.Headers[RawHeaderTimestamp], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s header: %w", RawHeaderTimestamp, err)
+	}
+
+	msg := &Message{
+		ID:        env.Headers[RawHeaderID],
+		Payload:   env.Value,
+		Timestamp: time.Unix(0, ts),
+		RunID:     env.Headers[RawHeaderRunID],
+		Key:       env.Headers[RawHeaderKey],
+	}
+
+	if value, ok := env.Headers[RawHeaderSequence]; ok {
+		if msg.Sequence, err = strconv.ParseUint(value, 10, 64); err != nil {
+			return nil, fmt.Errorf("failed to decode %s header: %w", RawHeaderSequence, err)
+		}
+		if msg.ProducerID, err = strconv.Atoi(env.Headers[RawHeaderProducer]); err != nil {
+			return nil, fmt.Errorf("failed to decode %s header: %w", RawHeaderProducer, err)
+		}
+	}
+
+	if value, ok := env.Headers[RawHeaderKeySequence]; ok {
+		if msg.KeySequence, err = strconv.ParseUint(value, 10, 64); err != nil {
+			return nil, fmt.Errorf("failed to decode %s header: %w", RawHeaderKeySequence, err)
+		}
+	}
+
+	for key, value := range env.Headers {
+		if rawHeaders[key] {
+			continue
+		}
+		if msg.Headers == nil {
+			msg.Headers = make(map[string]string, len(env.Headers))
+		}
+		msg.Headers[key] = value
+	}
+
+	return msg, nil
+}
+
+var codecs = map[string]Codec{
+	JSONCodec{}.Name():   JSONCodec{},
+	BinaryCodec{}.Name(): BinaryCodec{},
+	RawCodec{}.Name():    RawCodec{},
+}
+
+// LookupCodec returns the built-in codec with the given name
+func LookupCodec(name string) (Codec, error) {
+	codec, ok := codecs[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown codec %q (available: %s)", name, strings.Join(CodecNames(), ", "))
+	}
+	return codec, nil
+}
+
+// CodecNames returns the names of the built-in codecs in sorted order
+func CodecNames() []string {
+	names := make([]string, 0, len(codecs))
+	for name := range codecs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
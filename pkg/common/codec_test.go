@@ -0,0 +1,157 @@
+package common
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func newCodecTestMessage() *Message {
+	return &Message{
+		ID:          "msg-1",
+		Payload:     []byte{0x00, 0x01, 0xfe, 0xff},
+		Timestamp:   time.Unix(1700000000, 123456789),
+		RunID:       "run-1",
+		ProducerID:  3,
+		Sequence:    42,
+		Key:         "key-7",
+		KeySequence: 6,
+		Headers:     map[string]string{"trace-id": "abc"},
+	}
+}
+
+func TestCodecsRoundTrip(t *testing.T) {
+	for _, name := range CodecNames() {
+		t.Run(name, func(t *testing.T) {
+			codec, err := LookupCodec(name)
+			if err != nil {
+				t.Fatalf("LookupCodec failed: %v", err)
+			}
+
+			msg := newCodecTestMessage()
+			env, err := codec.Encode(msg)
+			if err != nil {
+				t.Fatalf("Encode failed: %v", err)
+			}
+
+			decoded, err := codec.Decode(env)
+			if err != nil {
+				t.Fatalf("Decode failed: %v", err)
+			}
+
+			if decoded.ID != msg.ID {
+				t.Errorf("Expected ID '%s', got '%s'", msg.ID, decoded.ID)
+			}
+
+			if !bytes.Equal(decoded.Payload, msg.Payload) {
+				t.Errorf("Expected payload %v, got %v", msg.Payload, decoded.Payload)
+			}
+
+			if !decoded.Timestamp.Equal(msg.Timestamp) {
+				t.Errorf("Expected timestamp %v, got %v", msg.Timestamp, decoded.Timestamp)
+			}
+
+			if decoded.RunID != msg.RunID {
+				t.Errorf("Expected run ID '%s', got '%s'", msg.RunID, decoded.RunID)
+			}
+
+			if decoded.ProducerID != msg.ProducerID || decoded.Sequence != msg.Sequence {
+				t.Errorf("Expected producer %d sequence %d, got producer %d sequence %d",
+					msg.ProducerID, msg.Sequence, decoded.ProducerID, decoded.Sequence)
+			}
+
+			if decoded.Key != msg.Key || decoded.KeySequence != msg.KeySequence {
+				t.Errorf("Expected key %s sequence %d, got key %s sequence %d",
+					msg.Key, msg.KeySequence, decoded.Key, decoded.KeySequence)
+			}
+
+			if len(decoded.Headers) != 1 || decoded.Headers["trace-id"] != "abc" {
+				t.Errorf("Expected headers map[trace-id:abc], got %v", decoded.Headers)
+			}
+		})
+	}
+}
+
+func TestCodecPayloadOverhead(t *testing.T) {
+	msg := &Message{ID: "msg-1", Payload: make([]byte, 1024), Timestamp: time.Now()}
+
+	jsonEnv, _ := JSONCodec{}.Encode(msg)
+	binaryEnv, _ := BinaryCodec{}.Encode(msg)
+	rawEnv, _ := RawCodec{}.Encode(msg)
+
+	// base64 makes the JSON body at least 4/3 of the payload
+	if len(jsonEnv.Value) < 1024*4/3 {
+		t.Errorf("Expected JSON body of at least %d bytes, got %d", 1024*4/3, len(jsonEnv.Value))
+	}
+
+	// One byte each for the ID length and the five unset ordering fields
+	if want := 1 + len(msg.ID) + 8 + 5 + 1024; len(binaryEnv.Value) != want {
+		t.Errorf("Expected binary body of %d bytes, got %d", want, len(binaryEnv.Value))
+	}
+
+	if len(rawEnv.Value) != 1024 {
+		t.Errorf("Expected raw body of 1024 bytes, got %d", len(rawEnv.Value))
+	}
+}
+
+func TestRawCodecDoesNotModifyHeaders(t *testing.T) {
+	msg := newCodecTestMessage()
+	if _, err := (RawCodec{}).Encode(msg); err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+
+	if len(msg.Headers) != 1 {
+		t.Errorf("Expected message headers to be left alone, got %v", msg.Headers)
+	}
+}
+
+func TestRawCodecMissingTimestamp(t *testing.T) {
+	env := &Envelope{Value: []byte("payload"), Headers: map[string]string{RawHeaderID: "msg-1"}}
+	if _, err := (RawCodec{}).Decode(env); err == nil {
+		t.Error("Expected error for missing timestamp header, got nil")
+	}
+}
+
+func TestRawCodecUnsequenced(t *testing.T) {
+	msg := &Message{ID: "msg-1", Payload: []byte("p"), Timestamp: time.Now()}
+	env, _ := RawCodec{}.Encode(msg)
+
+	if _, ok := env.Headers[RawHeaderSequence]; ok {
+		t.Errorf("Expected no %s header for an unsequenced message", RawHeaderSequence)
+	}
+
+	if _, ok := env.Headers[RawHeaderRunID]; ok {
+		t.Errorf("Expected no %s header for a message without run ID", RawHeaderRunID)
+	}
+
+	decoded, err := RawCodec{}.Decode(env)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+
+	if decoded.Sequence != 0 || decoded.Key != "" || decoded.Headers != nil {
+		t.Errorf("Expected unsequenced message without headers, got %+v", decoded)
+	}
+}
+
+func TestBinaryCodecTruncatedFrame(t *testing.T) {
+	env, _ := BinaryCodec{}.Encode(newCodecTestMessage())
+
+	idEnd := 1 + len("msg-1")
+	runEnd := idEnd + 8 + 1 + len("run-1")
+	keyEnd := runEnd + 2 + 1 + len("key-7")
+
+	// Cut inside the ID length, the ID, the timestamp, the run ID, the key
+	// and just before the key sequence
+	for _, n := range []int{0, 1, idEnd - 1, idEnd + 7, runEnd - 1, keyEnd - 1, keyEnd} {
+		if _, err := (BinaryCodec{}).Decode(&Envelope{Value: env.Value[:n]}); err == nil {
+			t.Errorf("Expected error for frame truncated to %d bytes, got nil", n)
+		}
+	}
+}
+
+func TestLookupUnknownCodec(t *testing.T) {
+	if _, err := LookupCodec("protobuf"); err == nil {
+		t.Error("Expected error for unknown codec, got nil")
+	}
+}
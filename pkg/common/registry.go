@@ -0,0 +1,179 @@
+package common
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Role selects which side of a benchmark a queue instance serves
+type Role int
+
+const (
+	// RoleProducer queues only send messages
+	RoleProducer Role = iota
+	// RoleConsumer queues only receive messages
+	RoleConsumer
+)
+
+// String returns the lowercase role name
+func (r Role) String() string {
+	switch r {
+	case RoleProducer:
+		return "producer"
+	case RoleConsumer:
+		return "consumer"
+	default:
+		return fmt.Sprintf("role(%d)", int(r))
+	}
+}
+
+// BackendOptions holds the backend-independent settings used when a backend
+// creates a queue
+type BackendOptions struct {
+	Role Role
+	// Codec selects the wire format. Nil keeps the backend's default;
+	// backends that do not serialize messages ignore it.
+	Codec Codec
+	// Retry controls how consumers handle failing handlers
+	Retry RetryPolicy
+	// Replay asks for a consumer in a new consumer group of its own that
+	// starts at the oldest retained message, rather than in the shared
+	// benchmark group. Backends remove the group when the queue is closed
+	// where that is possible.
+	Replay bool
+}
+
+// Backend creates MessageQueue instances for one kind of broker. Backend
+// packages register an implementation from an init function so the CLI can
+// select it by name without knowing about the package.
+type Backend interface {
+	// Name is the identifier used to select the backend, e.g. "kafka"
+	Name() string
+	// RegisterFlags adds the backend's own settings (addresses, topic
+	// names, ...) to fs. The parsed values are used by NewQueue.
+	RegisterFlags(fs *flag.FlagSet)
+	// NewQueue creates a queue for the role in opts
+	NewQueue(opts BackendOptions) (MessageQueue, error)
+}
+
+// Provisioner is implemented by backends that create broker resources such as
+// topics before a run and can remove them afterwards
+type Provisioner interface {
+	// Provision prepares the broker for a run. It is called before any
+	// queue of the run is created.
+	Provision(ctx context.Context) error
+	// Cleanup undoes Provision where configured. It is called after every
+	// queue of the run has been closed.
+	Cleanup(ctx context.Context) error
+}
+
+var (
+	backendsMu sync.RWMutex
+	backends   = make(map[string]Backend)
+)
+
+// RegisterBackend makes a backend available by name. It panics if the name is
+// empty or already registered, since that is a programming error.
+func RegisterBackend(b Backend) {
+	backendsMu.Lock()
+	defer backendsMu.Unlock()
+
+	name := b.Name()
+	if name == "" {
+		panic("common: RegisterBackend called with empty backend name")
+	}
+	if _, dup := backends[name]; dup {
+		panic("common: RegisterBackend called twice for backend " + name)
+	}
+	backends[name] = b
+}
+
+// LookupBackend returns the backend registered under name
+func LookupBackend(name string) (Backend, error) {
+	backendsMu.RLock()
+	defer backendsMu.RUnlock()
+
+	b, ok := backends[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown backend %q (available: %s)", name, strings.Join(backendNamesLocked(), ", "))
+	}
+	return b, nil
+}
+
+// Backends returns all registered backends sorted by name
+func Backends() []Backend {
+	backendsMu.RLock()
+	defer backendsMu.RUnlock()
+
+	names := backendNamesLocked()
+	list := make([]Backend, len(names))
+	for i, name := range names {
+		list[i] = backends[name]
+	}
+	return list
+}
+
+// BackendNames returns the names of all registered backends in sorted order
+func BackendNames() []string {
+	backendsMu.RLock()
+	defer backendsMu.RUnlock()
+	return backendNamesLocked()
+}
+
+func backendNamesLocked() []string {
+	names := make([]string, 0, len(backends))
+	for name := range backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// backendAliases maps names kept from earlier versions of the -queue flag
+// to the backends they select
+var backendAliases = map[string][]string{
+	"both": {"kafka", "redis"},
+}
+
+// ParseBackendList resolves a comma-separated list of backend names, keeping
+// the given order and dropping duplicates. The keyword "all" selects every
+// registered backend, and "both" is short for "kafka,redis".
+func ParseBackendList(list string) ([]Backend, error) {
+	if strings.TrimSpace(list) == "all" {
+		return Backends(), nil
+	}
+
+	var names []string
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		if alias, ok := backendAliases[name]; ok {
+			names = append(names, alias...)
+		} else {
+			names = append(names, name)
+		}
+	}
+
+	var selected []Backend
+	seen := make(map[string]bool)
+	for _, name := range names {
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		b, err := LookupBackend(name)
+		if err != nil {
+			return nil, err
+		}
+		selected = append(selected, b)
+	}
+
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("no backend selected")
+	}
+	return selected, nil
+}
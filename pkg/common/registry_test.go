@@ -0,0 +1,138 @@
+package common
+
+import (
+	"errors"
+	"flag"
+	"testing"
+)
+
+// fakeBackend is a Backend that records how it was used
+type fakeBackend struct {
+	name  string
+	addr  string
+	roles []Role
+}
+
+func (f *fakeBackend) Name() string {
+	return f.name
+}
+
+func (f *fakeBackend) RegisterFlags(fs *flag.FlagSet) {
+	fs.StringVar(&f.addr, f.name+"-addr", "localhost:1234", "fake address")
+}
+
+func (f *fakeBackend) NewQueue(opts BackendOptions) (MessageQueue, error) {
+	f.roles = append(f.roles, opts.Role)
+	return nil, errors.New("fake backend has no queue")
+}
+
+func TestRoleString(t *testing.T) {
+	if RoleProducer.String() != "producer" {
+		t.Errorf("Expected 'producer', got '%s'", RoleProducer.String())
+	}
+
+	if RoleConsumer.String() != "consumer" {
+		t.Errorf("Expected 'consumer', got '%s'", RoleConsumer.String())
+	}
+}
+
+func TestRegisterAndLookupBackend(t *testing.T) {
+	fake := &fakeBackend{name: "test-registry-lookup"}
+	RegisterBackend(fake)
+
+	got, err := LookupBackend("test-registry-lookup")
+	if err != nil {
+		t.Fatalf("LookupBackend failed: %v", err)
+	}
+
+	if got != fake {
+		t.Error("LookupBackend returned a different backend")
+	}
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	got.RegisterFlags(fs)
+	if err := fs.Parse([]string{"-test-registry-lookup-addr", "example:99"}); err != nil {
+		t.Fatalf("Failed to parse flags: %v", err)
+	}
+
+	if fake.addr != "example:99" {
+		t.Errorf("Expected addr 'example:99', got '%s'", fake.addr)
+	}
+
+	found := false
+	for _, name := range BackendNames() {
+		if name == "test-registry-lookup" {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("Expected registered backend in BackendNames")
+	}
+}
+
+func TestLookupUnknownBackend(t *testing.T) {
+	if _, err := LookupBackend("does-not-exist"); err == nil {
+		t.Error("Expected error for unknown backend, got nil")
+	}
+}
+
+func TestRegisterBackendDuplicatePanics(t *testing.T) {
+	RegisterBackend(&fakeBackend{name: "test-registry-dup"})
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected panic on duplicate registration")
+		}
+	}()
+	RegisterBackend(&fakeBackend{name: "test-registry-dup"})
+}
+
+func TestParseBackendList(t *testing.T) {
+	RegisterBackend(&fakeBackend{name: "test-registry-a"})
+	RegisterBackend(&fakeBackend{name: "test-registry-b"})
+
+	backends, err := ParseBackendList(" test-registry-b, test-registry-a,test-registry-b ,")
+	if err != nil {
+		t.Fatalf("ParseBackendList failed: %v", err)
+	}
+
+	if len(backends) != 2 {
+		t.Fatalf("Expected 2 backends, got %d", len(backends))
+	}
+
+	if backends[0].Name() != "test-registry-b" || backends[1].Name() != "test-registry-a" {
+		t.Errorf("Expected order [test-registry-b test-registry-a], got [%s %s]",
+			backends[0].Name(), backends[1].Name())
+	}
+
+	if _, err := ParseBackendList("test-registry-a,unknown"); err == nil {
+		t.Error("Expected error for unknown backend in list")
+	}
+
+	if _, err := ParseBackendList(" , "); err == nil {
+		t.Error("Expected error for empty backend list")
+	}
+
+	all, err := ParseBackendList("all")
+	if err != nil {
+		t.Fatalf("ParseBackendList(all) failed: %v", err)
+	}
+
+	if len(all) != len(BackendNames()) {
+		t.Errorf("Expected %d backends for 'all', got %d", len(BackendNames()), len(all))
+	}
+}
+
+func TestParseBackendListAlias(t *testing.T) {
+	RegisterBackend(&fakeBackend{name: "kafka"})
+	RegisterBackend(&fakeBackend{name: "redis"})
+
+	backends, err := ParseBackendList("redis,both")
+	if err != nil {
+		t.Fatalf("ParseBackendList(both) failed: %v", err)
+	}
+
+	if len(backends) != 2 || backends[0].Name() != "redis" || backends[1].Name() != "kafka" {
+		t.Errorf("Expected both to add kafka and redis once, got %d backends", len(backends))
+	}
+}
@@ -0,0 +1,119 @@
+package common
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// Headers added to a message when it is moved to a dead-letter destination
+const (
+	HeaderDeadLetterError    = "dlq-error"    // last handler error
+	HeaderDeadLetterAttempts = "dlq-attempts" // handler calls made before giving up
+	HeaderDeadLetterSource   = "dlq-source"   // topic or stream the message was consumed from
+)
+
+// RetryPolicy controls how often Consume calls the handler for a message
+// before giving up on it
+type RetryPolicy struct {
+	MaxAttempts int           // handler calls per message; values below 1 mean 1
+	Backoff     time.Duration // wait before the first retry, doubled for each further one
+	MaxBackoff  time.Duration // upper bound for the wait; 0 means unbounded
+}
+
+// Delay returns the wait before the given retry, counting from 1
+func (p RetryPolicy) Delay(retry int) time.Duration {
+	delay := p.Backoff
+	for i := 1; i < retry && (p.MaxBackoff == 0 || delay < p.MaxBackoff); i++ {
+		delay *= 2
+	}
+	if p.MaxBackoff > 0 && delay > p.MaxBackoff {
+		return p.MaxBackoff
+	}
+	return delay
+}
+
+// Handle calls handler until it succeeds, the attempts are used up or ctx is
+// done while backing off. It returns the number of calls made and the last
+// handler error, or ctx.Err() if ctx ended the wait.
+func (p RetryPolicy) Handle(ctx context.Context, msg *Message, handler func(*Message) error) (attempts int, err error) {
+	maxAttempts := p.MaxAttempts
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+
+	for attempts = 1; ; attempts++ {
+		if err = handler(msg); err == nil || attempts == maxAttempts {
+			return attempts, err
+		}
+
+		timer := time.NewTimer(p.Delay(attempts))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return attempts, ctx.Err()
+		}
+	}
+}
+
+// FailureStats counts how a queue dealt with failing handlers
+type FailureStats struct {
+	Redeliveries int64 // handler calls after a failed attempt
+	DeadLettered int64 // messages moved to the dead-letter destination
+	Discarded    int64 // messages dropped after the last attempt without a dead-letter destination
+	// Undecodable messages never reached the handler; they are also counted
+	// as dead-lettered or discarded
+	Undecodable int64
+}
+
+// FailureReporter is implemented by queues that apply a RetryPolicy
+type FailureReporter interface {
+	FailureStats() FailureStats
+}
+
+// FailureCounters accumulates FailureStats from concurrent Consume calls
+type FailureCounters struct {
+	redeliveries atomic.Int64
+	deadLettered atomic.Int64
+	discarded    atomic.Int64
+	undecodable  atomic.Int64
+}
+
+// AddAttempts records the handler calls made for one message
+func (c *FailureCounters) AddAttempts(attempts int) {
+	if attempts > 1 {
+		c.redeliveries.Add(int64(attempts - 1))
+	}
+}
+
+// AddRedelivery records a single handler call after a failed attempt, for
+// queues whose retries are separate deliveries
+func (c *FailureCounters) AddRedelivery() {
+	c.redeliveries.Add(1)
+}
+
+// AddDeadLettered records a message moved to the dead-letter destination
+func (c *FailureCounters) AddDeadLettered() {
+	c.deadLettered.Add(1)
+}
+
+// AddDiscarded records a message given up on without a dead-letter destination
+func (c *FailureCounters) AddDiscarded() {
+	c.discarded.Add(1)
+}
+
+// AddUndecodable records a message that could not be decoded
+func (c *FailureCounters) AddUndecodable() {
+	c.undecodable.Add(1)
+}
+
+// Stats returns a snapshot of the counters
+func (c *FailureCounters) Stats() FailureStats {
+	return FailureStats{
+		Redeliveries: c.redeliveries.Load(),
+		DeadLettered: c.deadLettered.Load(),
+		Discarded:    c.discarded.Load(),
+		Undecodable:  c.undecodable.Load(),
+	}
+}
@@ -0,0 +1,90 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyDelay(t *testing.T) {
+	policy := RetryPolicy{Backoff: 10 * time.Millisecond, MaxBackoff: 50 * time.Millisecond}
+
+	expected := []time.Duration{10, 20, 40, 50, 50}
+	for i, want := range expected {
+		if got := policy.Delay(i + 1); got != want*time.Millisecond {
+			t.Errorf("Expected delay %v before retry %d, got %v", want*time.Millisecond, i+1, got)
+		}
+	}
+
+	unbounded := RetryPolicy{Backoff: time.Millisecond}
+	if got := unbounded.Delay(5); got != 16*time.Millisecond {
+		t.Errorf("Expected unbounded delay 16ms, got %v", got)
+	}
+}
+
+func TestRetryPolicyHandle(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond}
+	errFail := errors.New("fail")
+
+	calls := 0
+	attempts, err := policy.Handle(context.Background(), &Message{}, func(*Message) error {
+		calls++
+		if calls < 2 {
+			return errFail
+		}
+		return nil
+	})
+	if err != nil || attempts != 2 {
+		t.Errorf("Expected success after 2 attempts, got %d attempts and error %v", attempts, err)
+	}
+
+	attempts, err = policy.Handle(context.Background(), &Message{}, func(*Message) error {
+		return errFail
+	})
+	if !errors.Is(err, errFail) || attempts != 3 {
+		t.Errorf("Expected failure after 3 attempts, got %d attempts and error %v", attempts, err)
+	}
+}
+
+func TestRetryPolicyHandleSingleAttempt(t *testing.T) {
+	var policy RetryPolicy
+
+	calls := 0
+	attempts, err := policy.Handle(context.Background(), &Message{}, func(*Message) error {
+		calls++
+		return errors.New("fail")
+	})
+	if err == nil || attempts != 1 || calls != 1 {
+		t.Errorf("Expected one failed attempt, got %d attempts, %d calls and error %v", attempts, calls, err)
+	}
+}
+
+func TestRetryPolicyHandleCancelled(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 5, Backoff: time.Hour}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	attempts, err := policy.Handle(ctx, &Message{}, func(*Message) error {
+		cancel()
+		return errors.New("fail")
+	})
+	if !errors.Is(err, context.Canceled) || attempts != 1 {
+		t.Errorf("Expected cancellation after 1 attempt, got %d attempts and error %v", attempts, err)
+	}
+}
+
+func TestFailureCounters(t *testing.T) {
+	var counters FailureCounters
+	counters.AddAttempts(1)
+	counters.AddAttempts(3)
+	counters.AddRedelivery()
+	counters.AddDeadLettered()
+	counters.AddDiscarded()
+	counters.AddDiscarded()
+	counters.AddUndecodable()
+
+	stats := counters.Stats()
+	if stats.Redeliveries != 3 || stats.DeadLettered != 1 || stats.Discarded != 2 || stats.Undecodable != 1 {
+		t.Errorf("Expected {3 1 2 1}, got %+v", stats)
+	}
+}
@@ -1,35 +1,263 @@
 package common
 
-import "time"
+import (
+	"context"
+	"fmt"
+	"time"
+)
 
 // Message represents a benchmark message
 type Message struct {
 	ID        string    `json:"id"`
 	Payload   []byte    `json:"payload"`
 	Timestamp time.Time `json:"timestamp"`
+	// Ordering metadata set by the benchmark producers. RunID identifies the
+	// benchmark run, Sequence counts from 1 per producer and KeySequence from
+	// 1 per producer and key; zero means the message is not sequenced.
+	RunID       string `json:"run_id,omitempty"`
+	ProducerID  int    `json:"producer_id,omitempty"`
+	Sequence    uint64 `json:"seq,omitempty"`
+	Key         string `json:"key,omitempty"`
+	KeySequence uint64 `json:"key_seq,omitempty"`
+	// Headers are carried natively by each broker (Kafka record headers,
+	// extra Redis stream fields) rather than inside the serialized body
+	Headers map[string]string `json:"-"`
+	// Trace holds timings measured by the queue while handling this message.
+	// It is never sent over the wire.
+	Trace Trace `json:"-"`
+}
+
+// Trace holds per-message timings recorded by queue implementations
+type Trace struct {
+	EncodeTime time.Duration // set by Produce once the message is serialized
+	DecodeTime time.Duration // set by Consume before the handler runs
+	// BrokerTime is when the broker stored the message by its own clock
+	// (Kafka LogAppendTime, Redis stream entry ID); zero if unknown
+	BrokerTime time.Time
+	// ReceiveTime is when Consume got the message from the client library,
+	// before decoding
+	ReceiveTime time.Time
+	// Partition is the partition the message was consumed from, valid when
+	// Partitioned is set
+	Partition   int32
+	Partitioned bool
 }
 
 // MessageQueue interface for both Kafka and Redis implementations
 type MessageQueue interface {
-	Produce(msg *Message) error
-	Consume(handler func(*Message) error) error
+	// Produce sends a message and waits for the broker to accept it or for
+	// ctx to be done, whichever happens first
+	Produce(ctx context.Context, msg *Message) error
+	// Consume delivers messages to handler until ctx is cancelled. It returns
+	// nil on cancellation, and only after the last handler call has finished,
+	// so callers can wait on it to know the consumer has fully stopped.
+	Consume(ctx context.Context, handler func(*Message) error) error
+	// Close releases the underlying clients. It must only be called once all
+	// Consume calls on the queue have returned.
 	Close() error
 	GetName() string
 }
 
+// BatchProducer is implemented by queues that can send several messages with
+// a single round of broker acknowledgments
+type BatchProducer interface {
+	ProduceBatch(ctx context.Context, msgs []*Message) error
+}
+
+// ClientConfigReporter is implemented by queues whose client settings are
+// worth recording with the results, e.g. the librdkafka configuration
+type ClientConfigReporter interface {
+	// ClientConfig returns the settings of the client used for role
+	ClientConfig(role Role) map[string]string
+}
+
+// TransactionStats describes the transactions a queue has finished
+type TransactionStats struct {
+	Committed   int64
+	Aborted     int64
+	CommitTimes []time.Duration // duration of each successful commit
+}
+
+// TransactionReporter is implemented by queues that can produce in
+// transactions
+type TransactionReporter interface {
+	TransactionStats() TransactionStats
+}
+
+// CommitStats describes the consumer offset commits of a queue
+type CommitStats struct {
+	Commits   int64
+	Errors    int64
+	Latencies []time.Duration // duration of each commit the queue waited for
+}
+
+// CommitReporter is implemented by queues that commit consumer offsets
+type CommitReporter interface {
+	CommitStats() CommitStats
+}
+
+// RebalanceEvent describes one partition assignment or revocation of a
+// consumer group member
+type RebalanceEvent struct {
+	Time       time.Time
+	Kind       string // "assign" or "revoke"
+	Partitions []int32
+	// Paused is set on assignments: the time since the previous revocation,
+	// or since subscribing for the first one, during which the consumer had
+	// nothing to read
+	Paused time.Duration
+	// Lost is set on revocations of partitions the consumer lost without a
+	// chance to commit, e.g. after its session timed out
+	Lost bool
+}
+
+// RebalanceReporter is implemented by queues whose consumers are assigned
+// partitions by a consumer group
+type RebalanceReporter interface {
+	RebalanceEvents() []RebalanceEvent
+}
+
+// ReclaimStats describes the pending entries a queue's consumers took over
+// after another consumer left them unacknowledged, e.g. because it crashed
+type ReclaimStats struct {
+	Reclaimed int64 // entries claimed and handled again
+	Exhausted int64 // entries claimed after their last allowed delivery and dead-lettered
+	// Latencies holds how long each claimed entry had been pending since its
+	// last delivery
+	Latencies []time.Duration
+}
+
+// ReclaimReporter is implemented by queues whose consumers reclaim entries
+// left pending by other consumers
+type ReclaimReporter interface {
+	ReclaimStats() ReclaimStats
+}
+
+// GroupMember describes one consumer of a consumer group as the broker sees
+// it, e.g. an entry of Redis' XINFO CONSUMERS
+type GroupMember struct {
+	Name    string
+	Pending int64         // messages delivered to it and not yet acknowledged
+	Idle    time.Duration // since its last attempted read or claim
+	// Inactive is the time since its last successful read or claim, negative
+	// if it never had one. Brokers that do not track it report 0.
+	Inactive time.Duration
+}
+
+// GroupMemberReporter is implemented by queues that can list the members of
+// their consumer group
+type GroupMemberReporter interface {
+	GroupMembers() ([]GroupMember, error)
+}
+
+// ClientStatsSample is one periodic statistics report of a client library,
+// e.g. librdkafka's statistics.interval.ms callback
+type ClientStatsSample struct {
+	Time   time.Time
+	Role   Role   // client that reported it
+	Client string // instance of the client library, when a role has several
+	// Round trip time to the brokers over the last interval
+	BrokerRTT    time.Duration // average
+	BrokerRTTP99 time.Duration
+	QueueDepth   int64   // messages waiting in the client's internal queues
+	BatchSize    float64 // average messages per produce request over the last interval
+	// Totals since the client was created
+	Requests int64 // requests sent to the brokers
+	Retries  int64 // requests sent again after a failure
+}
+
+// ClientStatsReporter is implemented by queues whose client libraries report
+// statistics while they run
+type ClientStatsReporter interface {
+	ClientStats() []ClientStatsSample
+}
+
+// ClientStatsSummary condenses the statistics samples of one client
+type ClientStatsSummary struct {
+	Samples       int
+	AvgBrokerRTT  time.Duration
+	MaxBrokerRTT  time.Duration // highest p99 of any interval
+	AvgQueueDepth float64
+	MaxQueueDepth int64
+	AvgBatchSize  float64 // over the intervals that sent batches
+	Requests      int64
+	Retries       int64
+}
+
+// PartitionReporter is implemented by queues whose consumers read from
+// partitions, so idle partitions can be told apart from missing ones
+type PartitionReporter interface {
+	// Partitions returns the IDs of the partitions of the consumed topic
+	Partitions() ([]int32, error)
+}
+
+// BatchError reports a ProduceBatch call in which only some messages failed
+type BatchError struct {
+	Failed int
+	Total  int
+	Err    error // first failure seen
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("%d of %d messages failed: %v", e.Failed, e.Total, e.Err)
+}
+
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
+
 // BenchmarkConfig holds configuration for benchmarks
 type BenchmarkConfig struct {
 	MessageCount    int
 	MessageSize     int
 	ProducerCount   int
 	ConsumerCount   int
-	BatchSize       int
+	BatchSize       int // messages per produce call, used when > 1 and the queue is a BatchProducer
+	KeyCount        int // distinct message keys per producer; 0 sends unkeyed messages
 	DurationSeconds int
+	// IdleTimeoutSeconds ends a full benchmark early once producers are done
+	// and no message has arrived for this long; 0 waits for DurationSeconds
+	IdleTimeoutSeconds int
+	// Headers are attached to every produced message. A value of
+	// HeaderValueUUID is replaced with a fresh UUID per message.
+	Headers map[string]string
+	// Codec names the wire format (see LookupCodec); empty keeps each
+	// backend's default
+	Codec string
+	// Retry is applied by consumers to failing handlers
+	Retry RetryPolicy
+	// FailureRate is the probability that a handler call fails, to exercise
+	// the retry and dead-letter paths
+	FailureRate float64
+	// Mode selects the benchmark the CLI runs: ModeFull (the default when
+	// empty) or ModeReplay. ModeProducer and ModeConsumer only label results.
+	Mode string
 }
 
+// Benchmark modes. A full run produces and consumes concurrently; a replay
+// run produces every message first and then measures how fast a fresh
+// consumer reads the whole history from the beginning.
+const (
+	ModeFull   = "full"
+	ModeReplay = "replay"
+)
+
+// Result-only modes, set on the results of the producer-only and
+// consumer-only runs of the metrics package. They are not benchmarks the CLI
+// runs and are not valid in BenchmarkConfig.Mode.
+const (
+	ModeProducer = "producer"
+	ModeConsumer = "consumer"
+)
+
+// HeaderValueUUID is a BenchmarkConfig.Headers value that stands for a unique
+// ID per message, e.g. a trace ID
+const HeaderValueUUID = "{uuid}"
+
 // BenchmarkResult holds the results of a benchmark run
 type BenchmarkResult struct {
 	QueueType      string
+	Mode           string // one of the Mode constants
 	MessageCount   int
 	Duration       time.Duration
 	Throughput     float64 // messages per second
@@ -43,4 +271,98 @@ type BenchmarkResult struct {
 	SuccessCount   int
 	BytesProcessed int64
 	MBPerSecond    float64
+	// Latency breakdown: produce call to broker ack, broker append to
+	// consumer receipt, and consumer receipt to handler call
+	AckLatency     LatencyStats
+	DwellTime      LatencyStats
+	ProcessingTime LatencyStats
+	// Serialization cost, measured separately from broker round trips
+	Codec      string
+	EncodeTime LatencyStats
+	DecodeTime LatencyStats
+	// Replay runs: time from creating the fresh consumer until its first
+	// message, which includes joining the group and the first fetch
+	FirstMessageTime time.Duration
+	// Delivery accounting for messages of this run, by producer and sequence
+	DeliveredCount  int // distinct messages received
+	LostCount       int // produced without error but neither received nor given up on by a consumer
+	DuplicateCount  int // extra deliveries of an already received message
+	UnexpectedCount int // messages not produced by this run, e.g. left over from an earlier one
+	// Failure path, when FailureRate is set
+	InjectedFailureCount int // handler calls failed on purpose
+	RedeliveryCount      int // handler calls after a failed attempt
+	DeadLetterCount      int // messages moved to the dead-letter destination
+	DiscardedCount       int // messages dropped after their last attempt
+	UndecodableCount     int // messages given up on because they could not be decoded
+	// Ordering as observed by the consumer handlers
+	SequencedCount     int // consumed messages that carried a sequence number
+	OutOfOrderCount    int // sequence lower than one already seen from the same producer
+	KeyOutOfOrderCount int // key sequence lower than one already seen for the same producer and key
+	SequenceGapCount   int // sequence skipped ahead of the next expected one
+	OrderingViolations []OrderingViolation
+	// Producer transactions, for transactional queues
+	TransactionCount      int // committed transactions
+	TransactionAbortCount int // transactions aborted or failed to commit
+	TransactionCommitTime LatencyStats
+	// Consumer offset commits, for queues that report them
+	OffsetCommitCount      int
+	OffsetCommitErrorCount int
+	OffsetCommitLatency    LatencyStats // only commits the consumer waited for
+	// Consumer group rebalances, for queues that report them. RebalanceTime
+	// adds up the pauses of every assignment.
+	RebalanceCount  int
+	RebalanceTime   time.Duration
+	RebalanceEvents []RebalanceEvent
+	// Pending entries taken over from other consumers, for queues that
+	// report them. ReclaimLatency is how long they had been pending.
+	ReclaimedCount        int
+	ReclaimExhaustedCount int // dead-lettered after the last allowed delivery
+	ReclaimLatency        LatencyStats
+	// Consumer group members at the end of the run, for queues that list
+	// them
+	GroupMembers []GroupMember
+	// Client library statistics, for queues that report them. The time
+	// series is exported to a file of its own.
+	ProducerStats ClientStatsSummary
+	ConsumerStats ClientStatsSummary
+	ClientStats   []ClientStatsSample `json:"-"`
+	// Load balance. Skew is the busiest partition's (consumer's) message
+	// count over the mean, so 1 is perfectly even; idle ones count towards
+	// the mean.
+	PartitionStats   []PartitionStats // for partitioned queues, by partition ID
+	IdlePartitions   int
+	PartitionSkew    float64
+	ConsumerMessages []int // messages handled by each consumer goroutine
+	IdleConsumers    int
+	ConsumerSkew     float64
+	// Client settings in effect, for backends that report them
+	ProducerConfig map[string]string
+	ConsumerConfig map[string]string
+}
+
+// OrderingViolation describes one out-of-order delivery or sequence gap
+type OrderingViolation struct {
+	Kind       string // "out-of-order", "key-out-of-order" or "gap"
+	ProducerID int
+	Key        string
+	Expected   uint64 // next sequence number the consumer was waiting for
+	Got        uint64
+}
+
+// PartitionStats describes the messages consumed from one partition
+type PartitionStats struct {
+	Partition int32
+	Messages  int
+	Latency   LatencyStats // end-to-end latency of the partition's messages
+}
+
+// LatencyStats summarizes a distribution of durations
+type LatencyStats struct {
+	Count int
+	Avg   time.Duration
+	Min   time.Duration
+	P50   time.Duration
+	P95   time.Duration
+	P99   time.Duration
+	Max   time.Duration
 }
@@ -1,6 +1,7 @@
 package common
 
 import (
+	"errors"
 	"testing"
 	"time"
 )
@@ -135,3 +136,21 @@ func TestBenchmarkResult(t *testing.T) {
 		t.Errorf("Expected MBPerSecond 100.0, got %.2f", result.MBPerSecond)
 	}
 }
+
+func TestBatchError(t *testing.T) {
+	cause := errors.New("broker unavailable")
+	var err error = &BatchError{Failed: 2, Total: 5, Err: cause}
+
+	if err.Error() != "2 of 5 messages failed: broker unavailable" {
+		t.Errorf("Unexpected error message: %s", err.Error())
+	}
+
+	if !errors.Is(err, cause) {
+		t.Error("Expected BatchError to unwrap to its cause")
+	}
+
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) || batchErr.Failed != 2 {
+		t.Error("Expected errors.As to extract the BatchError")
+	}
+}
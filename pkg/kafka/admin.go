@@ -0,0 +1,149 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+// TopicSpec describes the topic a benchmark run should use
+type TopicSpec struct {
+	Name              string
+	Partitions        int
+	ReplicationFactor int
+	// Config holds topic-level settings such as retention.ms or
+	// message.timestamp.type
+	Config map[string]string
+}
+
+// metadataPollInterval is how often EnsureTopic re-reads metadata while a new
+// topic propagates through the cluster
+const metadataPollInterval = 100 * time.Millisecond
+
+// EnsureTopic creates the topic described by spec and waits until the cluster
+// reports it with the requested partition count, replication factor and
+// config. An existing topic is accepted only if it already matches, so a run
+// never silently uses a differently shaped topic. The admin client connects
+// with security, which must allow creating and describing the topic.
+func EnsureTopic(ctx context.Context, brokers string, security KafkaSecurity, spec TopicSpec) error {
+	adminConfig := security.adminConfig(brokers)
+	admin, err := kafka.NewAdminClient(&adminConfig)
+	if err != nil {
+		return fmt.Errorf("failed to create admin client: %w", err)
+	}
+	defer admin.Close()
+
+	results, err := admin.CreateTopics(ctx, []kafka.TopicSpecification{{
+		Topic:             spec.Name,
+		NumPartitions:     spec.Partitions,
+		ReplicationFactor: spec.ReplicationFactor,
+		Config:            spec.Config,
+	}})
+	if err != nil {
+		return fmt.Errorf("failed to create topic %s: %w", spec.Name, err)
+	}
+	for _, result := range results {
+		if code := result.Error.Code(); code != kafka.ErrNoError && code != kafka.ErrTopicAlreadyExists {
+			return fmt.Errorf("failed to create topic %s: %w", spec.Name, result.Error)
+		}
+	}
+
+	if err := waitForTopic(ctx, admin, spec); err != nil {
+		return err
+	}
+	return verifyTopicConfig(ctx, admin, spec)
+}
+
+// waitForTopic polls the cluster metadata until the topic is visible with all
+// of its partitions, then checks its shape against spec
+func waitForTopic(ctx context.Context, admin *kafka.AdminClient, spec TopicSpec) error {
+	ticker := time.NewTicker(metadataPollInterval)
+	defer ticker.Stop()
+
+	for {
+		metadata, err := admin.GetMetadata(&spec.Name, false, 5000)
+		if err == nil {
+			topic, ok := metadata.Topics[spec.Name]
+			if ok && topic.Error.Code() == kafka.ErrNoError && len(topic.Partitions) > 0 {
+				return verifyTopicMetadata(spec, topic)
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return fmt.Errorf("topic %s did not become available: %w", spec.Name, ctx.Err())
+		}
+	}
+}
+
+// verifyTopicMetadata checks the partition count and replication factor of
+// an existing topic
+func verifyTopicMetadata(spec TopicSpec, topic kafka.TopicMetadata) error {
+	if len(topic.Partitions) != spec.Partitions {
+		return fmt.Errorf("topic %s has %d partitions, expected %d",
+			spec.Name, len(topic.Partitions), spec.Partitions)
+	}
+
+	for _, partition := range topic.Partitions {
+		if len(partition.Replicas) != spec.ReplicationFactor {
+			return fmt.Errorf("topic %s partition %d has %d replicas, expected %d",
+				spec.Name, partition.ID, len(partition.Replicas), spec.ReplicationFactor)
+		}
+	}
+	return nil
+}
+
+// verifyTopicConfig checks that every setting in spec.Config is in effect
+func verifyTopicConfig(ctx context.Context, admin *kafka.AdminClient, spec TopicSpec) error {
+	if len(spec.Config) == 0 {
+		return nil
+	}
+
+	results, err := admin.DescribeConfigs(ctx, []kafka.ConfigResource{{
+		Type: kafka.ResourceTopic,
+		Name: spec.Name,
+	}})
+	if err != nil {
+		return fmt.Errorf("failed to describe topic %s: %w", spec.Name, err)
+	}
+
+	for _, result := range results {
+		if result.Error.Code() != kafka.ErrNoError {
+			return fmt.Errorf("failed to describe topic %s: %w", spec.Name, result.Error)
+		}
+		for name, want := range spec.Config {
+			entry, ok := result.Config[name]
+			if !ok {
+				return fmt.Errorf("topic %s does not report config %s", spec.Name, name)
+			}
+			if entry.Value != want {
+				return fmt.Errorf("topic %s has %s=%s, expected %s", spec.Name, name, entry.Value, want)
+			}
+		}
+	}
+	return nil
+}
+
+// DeleteTopic deletes a topic. A topic that does not exist is not an error.
+func DeleteTopic(ctx context.Context, brokers string, security KafkaSecurity, topic string) error {
+	adminConfig := security.adminConfig(brokers)
+	admin, err := kafka.NewAdminClient(&adminConfig)
+	if err != nil {
+		return fmt.Errorf("failed to create admin client: %w", err)
+	}
+	defer admin.Close()
+
+	results, err := admin.DeleteTopics(ctx, []string{topic})
+	if err != nil {
+		return fmt.Errorf("failed to delete topic %s: %w", topic, err)
+	}
+	for _, result := range results {
+		if code := result.Error.Code(); code != kafka.ErrNoError && code != kafka.ErrUnknownTopicOrPart {
+			return fmt.Errorf("failed to delete topic %s: %w", topic, result.Error)
+		}
+	}
+	return nil
+}
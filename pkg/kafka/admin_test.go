@@ -0,0 +1,67 @@
+package kafka
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/google/uuid"
+	"github.com/praneethys/kafka-bullmq-benchmark/pkg/kafka/kafkatest"
+)
+
+func TestVerifyTopicMetadata(t *testing.T) {
+	spec := TopicSpec{Name: "t", Partitions: 2, ReplicationFactor: 1}
+	partition := func(id int32, replicas ...int32) kafka.PartitionMetadata {
+		return kafka.PartitionMetadata{ID: id, Replicas: replicas}
+	}
+
+	matching := kafka.TopicMetadata{Topic: "t", Partitions: []kafka.PartitionMetadata{partition(0, 1), partition(1, 1)}}
+	if err := verifyTopicMetadata(spec, matching); err != nil {
+		t.Errorf("Expected matching topic to verify, got %v", err)
+	}
+
+	fewer := kafka.TopicMetadata{Topic: "t", Partitions: []kafka.PartitionMetadata{partition(0, 1)}}
+	if err := verifyTopicMetadata(spec, fewer); err == nil {
+		t.Error("Expected error for partition count mismatch, got nil")
+	}
+
+	replicated := kafka.TopicMetadata{Topic: "t", Partitions: []kafka.PartitionMetadata{partition(0, 1, 2), partition(1, 2, 3)}}
+	if err := verifyTopicMetadata(spec, replicated); err == nil {
+		t.Error("Expected error for replication factor mismatch, got nil")
+	}
+}
+
+func TestEnsureTopic(t *testing.T) {
+	kafkatest.SkipIfMock(t)
+	brokers := kafkatest.Brokers(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	spec := TopicSpec{
+		Name:              "test-provisioned-" + uuid.New().String(),
+		Partitions:        3,
+		ReplicationFactor: 1,
+		Config:            map[string]string{"retention.ms": "600000"},
+	}
+	if err := EnsureTopic(ctx, brokers, KafkaSecurity{}, spec); err != nil {
+		t.Fatalf("EnsureTopic failed: %v", err)
+	}
+	defer func() {
+		if err := DeleteTopic(ctx, brokers, KafkaSecurity{}, spec.Name); err != nil {
+			t.Errorf("DeleteTopic failed: %v", err)
+		}
+	}()
+
+	// A matching topic is reused
+	if err := EnsureTopic(ctx, brokers, KafkaSecurity{}, spec); err != nil {
+		t.Errorf("Expected existing topic to be accepted, got %v", err)
+	}
+
+	// A differently shaped one is rejected
+	spec.Partitions = 5
+	if err := EnsureTopic(ctx, brokers, KafkaSecurity{}, spec); err == nil {
+		t.Error("Expected error for partition count mismatch, got nil")
+	}
+}
@@ -0,0 +1,334 @@
+package kafka
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"maps"
+	"os"
+	"strings"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/google/uuid"
+	"github.com/praneethys/kafka-bullmq-benchmark/pkg/common"
+)
+
+func init() {
+	b := &backend{}
+	common.RegisterBackend(b)
+	common.RegisterBackend(&txnBackend{backend: b})
+}
+
+// backend exposes Kafka to the benchmark CLI through the common registry
+type backend struct {
+	brokers         string
+	topic           string
+	deadLetterTopic string
+
+	// Topic provisioning; partitions of 0 leaves topic creation to the broker
+	partitions  int
+	replication int
+	topicConfig string
+	deleteTopic bool
+
+	// In-process mock cluster replacing brokers for the length of a run
+	mockBrokers int
+	mock        *kafka.MockCluster
+
+	// Client settings: typed flags, then the config file, then the
+	// passthrough lists, each overriding the one before
+	options        KafkaOptions
+	configFile     string
+	producerConfig string
+	consumerConfig string
+}
+
+func (b *backend) Name() string {
+	return "kafka"
+}
+
+func (b *backend) RegisterFlags(fs *flag.FlagSet) {
+	fs.StringVar(&b.brokers, "kafka-brokers", "localhost:9092", "Kafka broker addresses")
+	fs.StringVar(&b.topic, "kafka-topic", "benchmark-topic", "Kafka topic name")
+	fs.StringVar(&b.deadLetterTopic, "kafka-dlq-topic", "benchmark-topic-dlq",
+		"Kafka topic for messages that failed every attempt (empty drops them)")
+	fs.IntVar(&b.partitions, "kafka-partitions", 0,
+		"Create the Kafka topic with this many partitions before the run (0 relies on broker auto-create)")
+	fs.IntVar(&b.replication, "kafka-replication", 1, "Replication factor of the created Kafka topic")
+	fs.StringVar(&b.topicConfig, "kafka-topic-config", "",
+		"Comma-separated key=value configs of the created Kafka topic, e.g. retention.ms=600000")
+	fs.BoolVar(&b.deleteTopic, "kafka-delete-topic", false, "Delete the created Kafka topic after the run")
+	fs.IntVar(&b.mockBrokers, "kafka-mock", 0,
+		"Run against an in-process mock Kafka cluster with this many brokers instead of -kafka-brokers (0 disables)")
+
+	defaults := DefaultKafkaOptions()
+	b.options = defaults
+	fs.StringVar(&b.options.Acks, "kafka-acks", defaults.Acks, "Kafka producer acks (0, 1 or all)")
+	fs.StringVar(&b.options.Compression, "kafka-compression", defaults.Compression,
+		"Kafka producer compression (none, gzip, snappy, lz4 or zstd)")
+	fs.IntVar(&b.options.LingerMs, "kafka-linger-ms", defaults.LingerMs, "Kafka producer linger.ms")
+	fs.IntVar(&b.options.BatchBytes, "kafka-batch-bytes", defaults.BatchBytes, "Kafka producer batch.size in bytes")
+	fs.BoolVar(&b.options.EnableIdempotence, "kafka-idempotence", defaults.EnableIdempotence, "Enable the idempotent Kafka producer")
+	fs.BoolVar(&b.options.EnableAutoCommit, "kafka-auto-commit", defaults.EnableAutoCommit,
+		"Kafka consumer enable.auto.commit in the auto commit mode")
+	fs.StringVar(&b.options.CommitMode, "kafka-commit-mode", defaults.CommitMode,
+		"Kafka consumer offset commit mode ("+strings.Join(CommitModes, ", ")+")")
+	fs.IntVar(&b.options.CommitBatchSize, "kafka-commit-batch", defaults.CommitBatchSize,
+		"Messages per offset commit in the batch commit mode")
+	fs.DurationVar(&b.options.CommitInterval, "kafka-commit-interval", defaults.CommitInterval,
+		"Time between offset commits in the async commit mode")
+	fs.StringVar(&b.options.GroupInstanceID, "kafka-group-instance-id", "",
+		"group.instance.id of the Kafka consumer, for static group membership (empty disables)")
+	fs.IntVar(&b.options.FetchMinBytes, "kafka-fetch-min-bytes", defaults.FetchMinBytes, "Kafka consumer fetch.min.bytes")
+	fs.IntVar(&b.options.FetchWaitMaxMs, "kafka-fetch-wait-max-ms", defaults.FetchWaitMaxMs, "Kafka consumer fetch.wait.max.ms")
+	fs.StringVar(&b.options.Security.Protocol, "kafka-security-protocol", "",
+		"Kafka security.protocol ("+strings.Join(SecurityProtocols, ", ")+"; empty keeps the librdkafka default)")
+	fs.StringVar(&b.options.Security.SASLMechanism, "kafka-sasl-mechanism", "",
+		"Kafka SASL mechanism ("+strings.Join(SASLMechanisms, ", ")+"; empty means PLAIN)")
+	fs.StringVar(&b.options.Security.SASLUsername, "kafka-sasl-username", "", "Kafka SASL username")
+	fs.StringVar(&b.options.Security.SASLPassword, "kafka-sasl-password", "",
+		"Kafka SASL password (defaults to $"+saslPasswordEnv+")")
+	fs.StringVar(&b.options.Security.CALocation, "kafka-ssl-ca", "", "PEM file of the CA that signed the Kafka broker certificates")
+	fs.StringVar(&b.options.Security.CertLocation, "kafka-ssl-cert", "", "PEM file of the Kafka client certificate, for mutual TLS")
+	fs.StringVar(&b.options.Security.KeyLocation, "kafka-ssl-key", "", "PEM file of the Kafka client key, for mutual TLS")
+	fs.StringVar(&b.options.Security.KeyPassword, "kafka-ssl-key-password", "", "Password of the Kafka client key")
+	fs.DurationVar(&b.options.StatsInterval, "kafka-stats-interval", defaults.StatsInterval,
+		"Interval of the librdkafka statistics recorded for both Kafka clients (0 disables)")
+	fs.StringVar(&b.configFile, "kafka-config-file", "",
+		"File of librdkafka key=value properties, optionally in [producer] and [consumer] sections")
+	fs.StringVar(&b.producerConfig, "kafka-producer-config", "",
+		"Comma-separated librdkafka key=value properties for the Kafka producer")
+	fs.StringVar(&b.consumerConfig, "kafka-consumer-config", "",
+		"Comma-separated librdkafka key=value properties for the Kafka consumer")
+}
+
+// saslPasswordEnv is read for the SASL password when -kafka-sasl-password is
+// not set, which keeps it out of the process list
+const saslPasswordEnv = "KAFKA_SASL_PASSWORD"
+
+// Provision starts the mock cluster when -kafka-mock is set, and creates the
+// benchmark topic when -kafka-partitions is set
+func (b *backend) Provision(ctx context.Context) error {
+	if b.mockBrokers > 0 {
+		return b.startMock()
+	}
+	if b.partitions <= 0 {
+		return nil
+	}
+
+	config, err := parseConfigList(b.topicConfig)
+	if err != nil {
+		return fmt.Errorf("invalid -kafka-topic-config value: %w", err)
+	}
+
+	return EnsureTopic(ctx, b.brokers, b.security(), TopicSpec{
+		Name:              b.topic,
+		Partitions:        b.partitions,
+		ReplicationFactor: b.replication,
+		Config:            config,
+	})
+}
+
+// startMock starts a mock cluster for the run and creates the benchmark
+// topic on it directly, since the mock has no admin API for topics. Without
+// -kafka-partitions the mock creates topics on first use.
+func (b *backend) startMock() error {
+	if b.topicConfig != "" {
+		return fmt.Errorf("-kafka-topic-config is not supported with -kafka-mock")
+	}
+	if b.options.Security != (KafkaSecurity{}) {
+		return fmt.Errorf("the -kafka-security-protocol, -kafka-sasl-* and -kafka-ssl-* flags are not supported with -kafka-mock")
+	}
+
+	mock, err := kafka.NewMockCluster(b.mockBrokers)
+	if err != nil {
+		return fmt.Errorf("failed to start mock cluster: %w", err)
+	}
+	if b.partitions > 0 {
+		if err := mock.CreateTopic(b.topic, b.partitions, b.replication); err != nil {
+			mock.Close()
+			return fmt.Errorf("failed to create topic %s: %w", b.topic, err)
+		}
+	}
+
+	b.mock = mock
+	fmt.Printf("Started mock Kafka cluster with %d brokers at %s\n", b.mockBrokers, mock.BootstrapServers())
+	return nil
+}
+
+// bootstrapServers returns the brokers queues connect to: the mock cluster's
+// while one is running, otherwise -kafka-brokers
+func (b *backend) bootstrapServers() string {
+	if b.mock != nil {
+		return b.mock.BootstrapServers()
+	}
+	return b.brokers
+}
+
+// Cleanup stops the mock cluster, or deletes the benchmark topic if it was
+// provisioned and -kafka-delete-topic is set
+func (b *backend) Cleanup(ctx context.Context) error {
+	if b.mock != nil {
+		b.mock.Close()
+		b.mock = nil
+		return nil
+	}
+	if b.partitions <= 0 || !b.deleteTopic {
+		return nil
+	}
+	return DeleteTopic(ctx, b.brokers, b.security(), b.topic)
+}
+
+func (b *backend) NewQueue(opts common.BackendOptions) (common.MessageQueue, error) {
+	options, err := b.kafkaOptions()
+	if err != nil {
+		return nil, err
+	}
+	queue, err := b.newQueue(b.Name(), opts, options)
+	if err != nil {
+		return nil, err
+	}
+	return queue, nil
+}
+
+// newQueue creates a queue for the role in opts with the given client
+// settings. Each role gets only its own client, so producers never join the
+// consumer group. Replay consumers join a new group that reads from the
+// earliest offsets; the broker expires it like any other unused group.
+//
+// Each backend consumes in a group of its own. A static member stays in its
+// group after Close until its session times out, so a kafka-txn run in the
+// group of the kafka run before it would wait for that member's partitions.
+// Replay consumers are never static members.
+func (b *backend) newQueue(name string, opts common.BackendOptions, options KafkaOptions) (*KafkaQueue, error) {
+	var queue *KafkaQueue
+	var err error
+	switch {
+	case opts.Role == common.RoleProducer:
+		queue, err = NewKafkaProducer(b.bootstrapServers(), b.topic, options)
+	case opts.Replay:
+		// A group without committed offsets starts wherever
+		// auto.offset.reset says, whatever the passthrough properties set
+		options.AutoOffsetReset = "earliest"
+		options.Consumer["auto.offset.reset"] = "earliest"
+		options.GroupInstanceID = ""
+		queue, err = NewKafkaConsumer(b.bootstrapServers(), b.topic, "benchmark-replay-"+uuid.New().String(), options)
+	default:
+		queue, err = NewKafkaConsumer(b.bootstrapServers(), b.topic, "benchmark-consumer-group-"+name, options)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Codec != nil {
+		queue.SetCodec(opts.Codec)
+	}
+	queue.SetRetryPolicy(opts.Retry)
+	queue.SetDeadLetterTopic(b.deadLetterTopic)
+	return queue, nil
+}
+
+// txnBackend runs the Kafka benchmark with a transactional producer and a
+// read_committed consumer. It shares every -kafka-* setting with the plain
+// backend, so "-queue kafka,kafka-txn" measures the cost of exactly-once
+// delivery against the at-least-once path in a single invocation.
+type txnBackend struct {
+	*backend
+	transactionSize int
+	transactionalID string
+}
+
+func (t *txnBackend) Name() string {
+	return "kafka-txn"
+}
+
+// RegisterFlags adds only the transaction settings; the rest are registered
+// by the plain backend
+func (t *txnBackend) RegisterFlags(fs *flag.FlagSet) {
+	fs.IntVar(&t.transactionSize, "kafka-txn-size", 100, "Messages per transaction in the kafka-txn backend")
+	fs.StringVar(&t.transactionalID, "kafka-transactional-id", "benchmark-producer",
+		"transactional.id of the kafka-txn producer")
+}
+
+func (t *txnBackend) NewQueue(opts common.BackendOptions) (common.MessageQueue, error) {
+	options, err := t.kafkaOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	// Only the producing side gets a transactional.id; a second producer
+	// with the same ID would fence the first
+	if opts.Role == common.RoleProducer {
+		options.TransactionalID = t.transactionalID
+		options.TransactionSize = t.transactionSize
+	} else {
+		options.IsolationLevel = "read_committed"
+	}
+
+	queue, err := t.newQueue(t.Name(), opts, options)
+	if err != nil {
+		return nil, err
+	}
+	return queue, nil
+}
+
+// security returns the security flags, with the SASL password taken from
+// the environment if the flag is not set
+func (b *backend) security() KafkaSecurity {
+	security := b.options.Security
+	if security.SASLPassword == "" {
+		security.SASLPassword = os.Getenv(saslPasswordEnv)
+	}
+	return security
+}
+
+// kafkaOptions combines the typed flags with the config file and the
+// passthrough properties
+func (b *backend) kafkaOptions() (KafkaOptions, error) {
+	options := b.options
+	options.Security = b.security()
+	options.Producer = make(map[string]string)
+	options.Consumer = make(map[string]string)
+
+	if b.configFile != "" {
+		producer, consumer, err := LoadConfigFile(b.configFile)
+		if err != nil {
+			return KafkaOptions{}, err
+		}
+		maps.Copy(options.Producer, producer)
+		maps.Copy(options.Consumer, consumer)
+	}
+
+	producer, err := parseConfigList(b.producerConfig)
+	if err != nil {
+		return KafkaOptions{}, fmt.Errorf("invalid -kafka-producer-config value: %w", err)
+	}
+	maps.Copy(options.Producer, producer)
+
+	consumer, err := parseConfigList(b.consumerConfig)
+	if err != nil {
+		return KafkaOptions{}, fmt.Errorf("invalid -kafka-consumer-config value: %w", err)
+	}
+	maps.Copy(options.Consumer, consumer)
+
+	return options, nil
+}
+
+// parseConfigList parses a comma-separated list of key=value properties
+func parseConfigList(list string) (map[string]string, error) {
+	if strings.TrimSpace(list) == "" {
+		return nil, nil
+	}
+
+	config := make(map[string]string)
+	for _, pair := range strings.Split(list, ",") {
+		key, value, ok := strings.Cut(pair, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("expected key=value, got %q", pair)
+		}
+		config[key] = strings.TrimSpace(value)
+	}
+
+	return config, nil
+}
@@ -0,0 +1,243 @@
+package kafka
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/praneethys/kafka-bullmq-benchmark/pkg/common"
+)
+
+// Offset commit modes of the consumer
+const (
+	// CommitAuto lets librdkafka commit the offsets of messages handed to
+	// Consume in the background, possibly before their handler has finished
+	CommitAuto = "auto"
+	// CommitSync commits each message synchronously after its handler
+	CommitSync = "sync"
+	// CommitBatch commits synchronously after every CommitBatchSize handled
+	// messages
+	CommitBatch = "batch"
+	// CommitAsync commits the handled messages every CommitInterval from a
+	// background goroutine, without holding up consumption. At most one
+	// commit is in flight; intervals that pass during a slow commit are
+	// skipped.
+	CommitAsync = "async"
+	// CommitStore stores each offset after its handler and lets librdkafka
+	// commit the stored offsets in the background
+	CommitStore = "store"
+)
+
+// CommitModes lists the valid commit modes
+var CommitModes = []string{CommitAuto, CommitSync, CommitBatch, CommitAsync, CommitStore}
+
+// validCommitMode reports whether mode is one of CommitModes; empty means
+// CommitAuto
+func validCommitMode(mode string) bool {
+	if mode == "" {
+		return true
+	}
+	for _, m := range CommitModes {
+		if m == mode {
+			return true
+		}
+	}
+	return false
+}
+
+// committer commits consumer offsets according to a commit mode and keeps
+// count of the commits and their cost
+type committer struct {
+	consumer  *kafka.Consumer
+	mode      string
+	batchSize int64
+	stored    atomic.Int64 // messages stored, for counting off batches
+
+	mu        sync.Mutex
+	commits   int64
+	errors    int64
+	latencies []time.Duration
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+func newCommitter(consumer *kafka.Consumer, opts KafkaOptions) *committer {
+	c := &committer{
+		consumer:  consumer,
+		mode:      opts.CommitMode,
+		batchSize: int64(max(opts.CommitBatchSize, 1)),
+	}
+
+	if c.mode == CommitAsync {
+		interval := opts.CommitInterval
+		if interval <= 0 {
+			interval = time.Second
+		}
+		c.stop = make(chan struct{})
+		c.wg.Add(1)
+		go c.commitPeriodically(interval)
+	}
+	return c
+}
+
+// handled is called once the handler is done with msg, successfully or by
+// giving up on it
+func (c *committer) handled(msg *kafka.Message) {
+	switch c.mode {
+	case CommitSync:
+		start := time.Now()
+		_, err := c.consumer.CommitMessage(msg)
+		c.record(time.Since(start), err)
+	case CommitBatch:
+		if !c.store(msg) {
+			return
+		}
+		if c.stored.Add(1)%c.batchSize == 0 {
+			c.commitStored()
+		}
+	case CommitAsync, CommitStore:
+		c.store(msg)
+	}
+}
+
+// store marks msg as handled for the next commit of stored offsets
+func (c *committer) store(msg *kafka.Message) bool {
+	if _, err := c.consumer.StoreMessage(msg); err != nil {
+		c.record(0, err)
+		return false
+	}
+	return true
+}
+
+// commitStored synchronously commits the stored offsets
+func (c *committer) commitStored() {
+	start := time.Now()
+	_, err := c.consumer.Commit()
+	if noOffset(err) {
+		// Nothing was handled since the last commit
+		return
+	}
+	c.record(time.Since(start), err)
+}
+
+// commitPeriodically commits the stored offsets every interval. Commits run
+// one at a time on this goroutine, and the ticker drops the ticks that pass
+// while one is in flight. Nothing waits for them, so they are counted
+// without a latency.
+func (c *committer) commitPeriodically(interval time.Duration) {
+	defer c.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+			_, err := c.consumer.Commit()
+			c.recordBackground(err)
+		}
+	}
+}
+
+// committed records the result of a commit librdkafka made in the
+// background, whose latency is unknown
+func (c *committer) committed(e kafka.OffsetsCommitted) {
+	err := e.Error
+	for _, tp := range e.Offsets {
+		if err == nil && tp.Error != nil {
+			err = tp.Error
+		}
+	}
+	c.recordBackground(err)
+}
+
+// noOffset reports whether err means there was nothing to commit
+func noOffset(err error) bool {
+	kafkaErr, ok := err.(kafka.Error)
+	return ok && kafkaErr.Code() == kafka.ErrNoOffset
+}
+
+// recordBackground counts one commit that nothing waited for
+func (c *committer) recordBackground(err error) {
+	if noOffset(err) {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err != nil {
+		c.errors++
+		return
+	}
+	c.commits++
+}
+
+// record counts one commit made by the committer itself
+func (c *committer) record(latency time.Duration, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err != nil {
+		c.errors++
+		return
+	}
+	c.commits++
+	c.latencies = append(c.latencies, latency)
+}
+
+// revoked commits the offsets stored but not yet committed before the
+// consumer's partitions are revoked
+func (c *committer) revoked() {
+	if c.mode == CommitBatch || c.mode == CommitAsync {
+		c.commitStored()
+	}
+}
+
+// close stops the periodic commits and commits whatever was handled since
+// the last one. It must be called before the consumer is closed.
+func (c *committer) close() {
+	if c.stop != nil {
+		close(c.stop)
+		c.wg.Wait()
+	}
+	if c.mode == CommitBatch || c.mode == CommitAsync {
+		c.commitStored()
+	}
+}
+
+func (c *committer) stats() common.CommitStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return common.CommitStats{
+		Commits:   c.commits,
+		Errors:    c.errors,
+		Latencies: append([]time.Duration(nil), c.latencies...),
+	}
+}
+
+// CommitStats reports the offset commits made so far
+func (k *KafkaQueue) CommitStats() common.CommitStats {
+	if k.commits == nil {
+		return common.CommitStats{}
+	}
+	return k.commits.stats()
+}
+
+// commitConfig returns the consumer properties that implement mode
+func commitConfig(mode string, autoCommit bool) kafka.ConfigMap {
+	switch mode {
+	case CommitSync:
+		return kafka.ConfigMap{"enable.auto.commit": false}
+	case CommitBatch, CommitAsync:
+		return kafka.ConfigMap{"enable.auto.commit": false, "enable.auto.offset.store": false}
+	case CommitStore:
+		return kafka.ConfigMap{"enable.auto.commit": true, "enable.auto.offset.store": false}
+	default:
+		return kafka.ConfigMap{"enable.auto.commit": autoCommit}
+	}
+}
@@ -0,0 +1,152 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/praneethys/kafka-bullmq-benchmark/pkg/common"
+	"github.com/praneethys/kafka-bullmq-benchmark/pkg/kafka/kafkatest"
+)
+
+func TestCommitConfig(t *testing.T) {
+	tests := []struct {
+		mode       string
+		autoCommit bool
+		autoStore  any // nil when left to librdkafka
+	}{
+		{CommitAuto, true, nil},
+		{CommitSync, false, nil},
+		{CommitBatch, false, false},
+		{CommitAsync, false, false},
+		{CommitStore, true, false},
+	}
+
+	for _, tt := range tests {
+		opts := DefaultKafkaOptions()
+		opts.CommitMode = tt.mode
+		config := opts.consumerConfig(testBrokers, testGroup)
+
+		if config["enable.auto.commit"] != tt.autoCommit {
+			t.Errorf("%s: expected enable.auto.commit=%v, got %v", tt.mode, tt.autoCommit, config["enable.auto.commit"])
+		}
+
+		if config["enable.auto.offset.store"] != tt.autoStore {
+			t.Errorf("%s: expected enable.auto.offset.store=%v, got %v", tt.mode, tt.autoStore, config["enable.auto.offset.store"])
+		}
+	}
+}
+
+func TestInvalidCommitMode(t *testing.T) {
+	opts := DefaultKafkaOptions()
+	opts.CommitMode = "eventually"
+
+	if _, err := NewKafkaQueueWithOptions(testBrokers, testTopic, testGroup, opts); err == nil {
+		t.Error("Expected error for unknown commit mode, got nil")
+	}
+}
+
+func TestKafkaCommitModes(t *testing.T) {
+	brokers := kafkatest.Brokers(t)
+
+	for _, mode := range CommitModes {
+		t.Run(mode, func(t *testing.T) {
+			topicName := testTopic + "-commit-" + mode
+			group := testGroup + "-commit-" + mode
+
+			opts := DefaultKafkaOptions()
+			opts.CommitMode = mode
+			opts.CommitBatchSize = 10
+			opts.CommitInterval = 200 * time.Millisecond
+			opts.Consumer = map[string]string{"auto.commit.interval.ms": "500"}
+
+			queue, err := NewKafkaQueueWithOptions(brokers, topicName, group, opts)
+			if err != nil {
+				t.Fatalf("Failed to create Kafka queue: %v", err)
+			}
+
+			const messageCount = 25
+			for i := 0; i < messageCount; i++ {
+				msg := &common.Message{ID: fmt.Sprintf("commit-%d", i), Payload: []byte("commit"), Timestamp: time.Now()}
+				if err := queue.Produce(context.Background(), msg); err != nil {
+					t.Fatalf("Failed to produce message: %v", err)
+				}
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+			defer cancel()
+
+			consumed := 0
+			_ = queue.Consume(ctx, func(*common.Message) error { //nolint:errcheck // Returns nil on cancel
+				consumed++
+				if consumed == messageCount {
+					// Leave time for a background commit
+					time.AfterFunc(time.Second, cancel)
+				}
+				return nil
+			})
+
+			stats := queue.CommitStats()
+			partitions, err := queue.Partitions()
+			if err != nil {
+				t.Fatalf("Failed to list partitions: %v", err)
+			}
+			if err := queue.Close(); err != nil {
+				t.Errorf("Failed to close queue: %v", err)
+			}
+
+			if stats.Errors != 0 {
+				t.Errorf("Expected no commit errors, got %d", stats.Errors)
+			}
+
+			switch mode {
+			case CommitSync:
+				if stats.Commits != messageCount || len(stats.Latencies) != messageCount {
+					t.Errorf("Expected %d timed commits, got %d commits and %d latencies",
+						messageCount, stats.Commits, len(stats.Latencies))
+				}
+			case CommitBatch:
+				if stats.Commits != messageCount/10 {
+					t.Errorf("Expected %d batch commits before close, got %d", messageCount/10, stats.Commits)
+				}
+			case CommitAsync:
+				// Nothing waits for the periodic commits, so they have no
+				// latency
+				if stats.Commits == 0 || len(stats.Latencies) != 0 {
+					t.Errorf("Expected untimed periodic commits, got %d commits and %d latencies",
+						stats.Commits, len(stats.Latencies))
+				}
+			}
+
+			// Every handled message is committed once the queue is closed
+			reader, err := kafka.NewConsumer(&kafka.ConfigMap{"bootstrap.servers": brokers, "group.id": group})
+			if err != nil {
+				t.Fatalf("Failed to create offset reader: %v", err)
+			}
+			defer reader.Close()
+
+			topicPartitions := make([]kafka.TopicPartition, len(partitions))
+			for i, partition := range partitions {
+				topicPartitions[i] = kafka.TopicPartition{Topic: &topicName, Partition: partition}
+			}
+			committed, err := reader.Committed(topicPartitions, 5000)
+			if err != nil {
+				t.Fatalf("Failed to read committed offsets: %v", err)
+			}
+
+			// Offsets count from 0 on every partition, so they add up to
+			// the messages committed
+			var total kafka.Offset
+			for _, tp := range committed {
+				if tp.Offset > 0 {
+					total += tp.Offset
+				}
+			}
+			if total < messageCount {
+				t.Errorf("Expected committed offsets adding up to at least %d, got %v", messageCount, total)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package kafka
+
+import (
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/praneethys/kafka-bullmq-benchmark/pkg/common"
+)
+
+// asyncDelivery travels with an asynchronously produced message as its
+// Opaque, so the delivery report can be matched to the message
+type asyncDelivery struct {
+	msg      *common.Message
+	enqueued time.Time
+	txn      *transaction // on a transactional queue
+}
+
+// deliveryHandler receives the delivery report of a message sent with
+// ProduceAsync
+type deliveryHandler = func(msg *common.Message, latency time.Duration, err error)
+
+// SetDeliveryHandler sets the function called with every delivery report of
+// ProduceAsync: the message, the time from enqueueing it to the broker's
+// acknowledgment, and the delivery error, if any. On a transactional queue
+// a delivered message is only reported once its transaction has finished:
+// the latency then includes the commit, and the error is the commit's. It
+// runs on the report reader goroutine, or on the one that finished the
+// transaction, so it must not block for long. A nil handler only counts the
+// reports.
+func (k *KafkaQueue) SetDeliveryHandler(handler deliveryHandler) {
+	if handler == nil {
+		k.deliveryHandler.Store(nil)
+		return
+	}
+	k.deliveryHandler.Store(&handler)
+}
+
+// DeliveryErrors returns how many ProduceAsync messages the broker failed to
+// acknowledge
+func (k *KafkaQueue) DeliveryErrors() int64 {
+	return k.deliveryErrors.Load()
+}
+
+// readDeliveryReports drains the producer's event channel, which receives the
+// delivery reports of ProduceAsync and the statistics reports, until the
+// producer is closed
+func (k *KafkaQueue) readDeliveryReports() {
+	defer k.reportsWg.Done()
+
+	for e := range k.producer.Events() {
+		if stats, ok := e.(*kafka.Stats); ok {
+			k.stats.record(stats.String(), common.RoleProducer)
+			continue
+		}
+		m, ok := e.(*kafka.Message)
+		if !ok {
+			continue
+		}
+		d, ok := m.Opaque.(*asyncDelivery)
+		if !ok {
+			continue
+		}
+
+		err := m.TopicPartition.Error
+		if err == nil && d.txn != nil {
+			if d.txn.hold(d) {
+				continue
+			}
+			err = d.txn.err
+		}
+		k.report(d, err)
+	}
+}
+
+// report passes the outcome of a ProduceAsync message to the delivery
+// handler
+func (k *KafkaQueue) report(d *asyncDelivery, err error) {
+	if err != nil {
+		k.deliveryErrors.Add(1)
+	}
+	if handler := k.deliveryHandler.Load(); handler != nil {
+		(*handler)(d.msg, time.Since(d.enqueued), err)
+	}
+	k.pendingReports.Add(-1)
+}
+
+// waitDeliveryReports waits until every ProduceAsync message has been
+// reported or the deadline passes, and returns how many are outstanding
+func (k *KafkaQueue) waitDeliveryReports(deadline time.Time) int {
+	for k.pendingReports.Load() > 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	return int(k.pendingReports.Load())
+}
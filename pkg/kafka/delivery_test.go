@@ -0,0 +1,83 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/praneethys/kafka-bullmq-benchmark/pkg/common"
+	"github.com/praneethys/kafka-bullmq-benchmark/pkg/kafka/kafkatest"
+)
+
+func TestKafkaDeliveryReports(t *testing.T) {
+	brokers := kafkatest.Brokers(t)
+
+	queue, err := NewKafkaQueue(brokers, testTopic+"-async", testGroup)
+	if err != nil {
+		t.Fatalf("Failed to create Kafka queue: %v", err)
+	}
+	defer queue.Close()
+
+	var mu sync.Mutex
+	reported := make(map[string]time.Duration)
+	queue.SetDeliveryHandler(func(msg *common.Message, latency time.Duration, err error) {
+		if err != nil {
+			t.Errorf("Unexpected delivery error for %s: %v", msg.ID, err)
+		}
+		mu.Lock()
+		reported[msg.ID] = latency
+		mu.Unlock()
+	})
+
+	const messageCount = 20
+	for i := 0; i < messageCount; i++ {
+		msg := &common.Message{ID: fmt.Sprintf("async-%d", i), Payload: []byte("async"), Timestamp: time.Now()}
+		if err := queue.ProduceAsync(context.Background(), msg); err != nil {
+			t.Fatalf("Failed to produce async message: %v", err)
+		}
+	}
+
+	// Flush returns only once every report has been handled
+	if remaining := queue.Flush(10000); remaining > 0 {
+		t.Fatalf("Expected 0 remaining messages, got %d", remaining)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(reported) != messageCount {
+		t.Errorf("Expected %d delivery reports, got %d", messageCount, len(reported))
+	}
+
+	for id, latency := range reported {
+		if latency <= 0 {
+			t.Errorf("Expected positive ack latency for %s, got %v", id, latency)
+		}
+	}
+
+	if errs := queue.DeliveryErrors(); errs != 0 {
+		t.Errorf("Expected 0 delivery errors, got %d", errs)
+	}
+}
+
+func TestKafkaDeliveryReportsWithoutHandler(t *testing.T) {
+	brokers := kafkatest.Brokers(t)
+
+	queue, err := NewKafkaQueue(brokers, testTopic+"-async", testGroup)
+	if err != nil {
+		t.Fatalf("Failed to create Kafka queue: %v", err)
+	}
+	defer queue.Close()
+
+	// Reports are still drained, so Flush does not wait for them in vain
+	msg := &common.Message{ID: "async-unhandled", Payload: []byte("async"), Timestamp: time.Now()}
+	if err := queue.ProduceAsync(context.Background(), msg); err != nil {
+		t.Fatalf("Failed to produce async message: %v", err)
+	}
+
+	if remaining := queue.Flush(10000); remaining > 0 {
+		t.Errorf("Expected 0 remaining messages, got %d", remaining)
+	}
+}
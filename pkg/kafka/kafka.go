@@ -2,8 +2,13 @@ package kafka
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
@@ -12,117 +17,435 @@ import (
 
 // KafkaQueue implements the MessageQueue interface for Apache Kafka
 type KafkaQueue struct {
-	producer *kafka.Producer
-	consumer *kafka.Consumer
-	topic    string
-	brokers  string
-	ctx      context.Context
-	cancel   context.CancelFunc
+	// Either client is nil on a queue created for the other side only; a
+	// consumer-only queue creates its producer for the first dead letter
+	producer     *kafka.Producer
+	producerOnce sync.Once
+	producerErr  error
+	consumer     *kafka.Consumer
+
+	topic   string
+	brokers string
+	codec   common.Codec
+
+	// Settings the clients were created with, for the report
+	options        KafkaOptions
+	producerConfig map[string]string
+	consumerConfig map[string]string
+
+	retry           common.RetryPolicy
+	deadLetterTopic string
+	failures        common.FailureCounters
+	commits         *committer // nil without a consumer
+	rebalances      rebalanceLog
+	stats           statsLog
+
+	// Delivery reports of ProduceAsync
+	deliveryHandler atomic.Pointer[deliveryHandler]
+	deliveryErrors  atomic.Int64
+	pendingReports  atomic.Int64
+	reportsWg       sync.WaitGroup
+
+	// Transactions, when created with a TransactionalID
+	txnSize        int
+	txnMu          sync.Mutex
+	txn            *transaction
+	txnCommitted   int64
+	txnAborted     int64
+	txnCommitTimes []time.Duration
+	txnStop        chan struct{}
+	txnWg          sync.WaitGroup
 }
 
-// NewKafkaQueue creates a new Kafka queue instance
+// errNoProducer and errNoConsumer are returned when a queue is used for the
+// side it was not created for
+var (
+	errNoProducer = errors.New("queue was created without a producer")
+	errNoConsumer = errors.New("queue was created without a consumer")
+)
+
+// NewKafkaQueue creates a Kafka queue with both a producer and a consumer
+// and DefaultKafkaOptions
 func NewKafkaQueue(brokers, topic, consumerGroup string) (*KafkaQueue, error) {
-	// High-throughput producer configuration
-	producer, err := kafka.NewProducer(&kafka.ConfigMap{
-		"bootstrap.servers":                     brokers,
-		"acks":                                  "1", // Wait for leader acknowledgment
-		"compression.type":                      "lz4",
-		"linger.ms":                             10,
-		"batch.size":                            1000000,
-		"queue.buffering.max.messages":          100000,
-		"queue.buffering.max.kbytes":            1048576, // 1GB
-		"max.in.flight.requests.per.connection": 5,
-	})
+	return NewKafkaQueueWithOptions(brokers, topic, consumerGroup, DefaultKafkaOptions())
+}
+
+// NewKafkaQueueWithOptions creates a Kafka queue with both a producer and a
+// consumer, configured from opts. Benchmarks should use NewKafkaProducer and
+// NewKafkaConsumer instead, since a queue that only produces would otherwise
+// still join consumerGroup.
+func NewKafkaQueueWithOptions(brokers, topic, consumerGroup string, opts KafkaOptions) (*KafkaQueue, error) {
+	if !validCommitMode(opts.CommitMode) {
+		return nil, fmt.Errorf("unknown commit mode %q (available: %s)", opts.CommitMode, strings.Join(CommitModes, ", "))
+	}
+	if err := opts.Security.validate(); err != nil {
+		return nil, err
+	}
+
+	queue := newKafkaQueue(brokers, topic, opts)
+	if err := queue.startProducer(); err != nil {
+		return nil, err
+	}
+	if err := queue.startConsumer(consumerGroup, opts); err != nil {
+		_ = queue.Close() //nolint:errcheck // Best effort cleanup on error path
+		return nil, err
+	}
+	return queue, nil
+}
+
+// NewKafkaProducer creates a Kafka queue that only produces to topic. It
+// never joins a consumer group.
+func NewKafkaProducer(brokers, topic string, opts KafkaOptions) (*KafkaQueue, error) {
+	if err := opts.Security.validate(); err != nil {
+		return nil, err
+	}
+
+	queue := newKafkaQueue(brokers, topic, opts)
+	if err := queue.startProducer(); err != nil {
+		return nil, err
+	}
+	return queue, nil
+}
+
+// NewKafkaConsumer creates a Kafka queue that only consumes topic as a member
+// of consumerGroup. A producer for the dead-letter topic is created on the
+// first dead-lettered message; it is never transactional.
+func NewKafkaConsumer(brokers, topic, consumerGroup string, opts KafkaOptions) (*KafkaQueue, error) {
+	if !validCommitMode(opts.CommitMode) {
+		return nil, fmt.Errorf("unknown commit mode %q (available: %s)", opts.CommitMode, strings.Join(CommitModes, ", "))
+	}
+	if err := opts.Security.validate(); err != nil {
+		return nil, err
+	}
+
+	opts.TransactionalID = ""
+	queue := newKafkaQueue(brokers, topic, opts)
+	if err := queue.startConsumer(consumerGroup, opts); err != nil {
+		return nil, err
+	}
+	return queue, nil
+}
+
+func newKafkaQueue(brokers, topic string, opts KafkaOptions) *KafkaQueue {
+	return &KafkaQueue{
+		topic:   topic,
+		brokers: brokers,
+		codec:   common.JSONCodec{},
+		options: opts,
+	}
+}
+
+// startProducer creates the producer, starts reading its delivery reports
+// and, with a TransactionalID, prepares it for transactions
+func (k *KafkaQueue) startProducer() error {
+	producerConfig := k.options.producerConfig(k.brokers)
+	producer, err := kafka.NewProducer(&producerConfig)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create producer: %w", err)
-	}
-
-	// High-throughput consumer configuration
-	consumer, err := kafka.NewConsumer(&kafka.ConfigMap{
-		"bootstrap.servers":         brokers,
-		"group.id":                  consumerGroup,
-		"auto.offset.reset":         "earliest",
-		"enable.auto.commit":        true,
-		"fetch.min.bytes":           1024,
-		"fetch.wait.max.ms":         100,
-		"max.partition.fetch.bytes": 10485760, // 10MB
+		return fmt.Errorf("failed to create producer: %w", err)
+	}
+
+	k.producer = producer
+	k.producerConfig = reportedConfig(producerConfig)
+	k.reportsWg.Add(1)
+	go k.readDeliveryReports()
+
+	if k.options.TransactionalID != "" {
+		if err := k.initTransactions(max(k.options.TransactionSize, 1)); err != nil {
+			k.closeProducer()
+			return err
+		}
+	}
+	return nil
+}
+
+// ensureProducer creates the producer of a consumer-only queue the first
+// time something needs to be produced. It only writes dead letters, so it
+// reports no statistics that could be mistaken for the measured producer's.
+func (k *KafkaQueue) ensureProducer() error {
+	k.producerOnce.Do(func() {
+		if k.producer == nil {
+			k.options.StatsInterval = 0
+			k.producerErr = k.startProducer()
+		}
 	})
+	return k.producerErr
+}
+
+// startConsumer creates the consumer and subscribes it to the topic
+func (k *KafkaQueue) startConsumer(consumerGroup string, opts KafkaOptions) error {
+	consumerConfig := opts.consumerConfig(k.brokers, consumerGroup)
+	consumer, err := kafka.NewConsumer(&consumerConfig)
 	if err != nil {
-		producer.Close()
-		return nil, fmt.Errorf("failed to create consumer: %w", err)
+		return fmt.Errorf("failed to create consumer: %w", err)
 	}
 
-	if err := consumer.Subscribe(topic, nil); err != nil {
-		producer.Close()
+	// The callback uses the committer as soon as the first Poll runs
+	k.commits = newCommitter(consumer, opts)
+	k.rebalances.subscribed()
+	if err := consumer.Subscribe(k.topic, k.onRebalance); err != nil {
+		k.commits.close()
 		_ = consumer.Close() //nolint:errcheck // Best effort cleanup on error path
-		return nil, fmt.Errorf("failed to subscribe to topic: %w", err)
+		return fmt.Errorf("failed to subscribe to topic: %w", err)
 	}
 
-	ctx, cancel := context.WithCancel(context.Background())
+	k.consumer = consumer
+	k.consumerConfig = reportedConfig(consumerConfig)
+	return nil
+}
 
-	return &KafkaQueue{
-		producer: producer,
-		consumer: consumer,
-		topic:    topic,
-		brokers:  brokers,
-		ctx:      ctx,
-		cancel:   cancel,
-	}, nil
+// ClientConfig returns the librdkafka settings of the client used for role,
+// with credentials masked
+func (k *KafkaQueue) ClientConfig(role common.Role) map[string]string {
+	if role == common.RoleProducer {
+		return k.producerConfig
+	}
+	return k.consumerConfig
+}
+
+// SetCodec changes the wire format used by Produce and Consume. Producers and
+// consumers of the same topic must use the same codec.
+func (k *KafkaQueue) SetCodec(codec common.Codec) {
+	k.codec = codec
+}
+
+// Codec returns the wire format used by this queue
+func (k *KafkaQueue) Codec() common.Codec {
+	return k.codec
+}
+
+// SetRetryPolicy controls how often Consume retries a failing handler
+func (k *KafkaQueue) SetRetryPolicy(policy common.RetryPolicy) {
+	k.retry = policy
+}
+
+// SetDeadLetterTopic sets the topic that receives messages whose handler
+// failed every attempt. An empty topic drops them instead.
+func (k *KafkaQueue) SetDeadLetterTopic(topic string) {
+	k.deadLetterTopic = topic
+}
+
+// FailureStats reports the retries and dead-lettered messages seen by Consume
+func (k *KafkaQueue) FailureStats() common.FailureStats {
+	return k.failures.Stats()
 }
 
-// Produce sends a message to Kafka
-func (k *KafkaQueue) Produce(msg *common.Message) error {
-	data, err := json.Marshal(msg)
+// newKafkaMessage serializes msg into a Kafka message for this queue's topic
+// and records the time spent doing so in msg.Trace
+func (k *KafkaQueue) newKafkaMessage(msg *common.Message) (*kafka.Message, error) {
+	start := time.Now()
+
+	env, err := k.codec.Encode(msg)
 	if err != nil {
-		return fmt.Errorf("failed to marshal message: %w", err)
+		return nil, err
 	}
 
-	kafkaMsg := &kafka.Message{
+	var headers []kafka.Header
+	if len(env.Headers) > 0 {
+		headers = make([]kafka.Header, 0, len(env.Headers))
+		for key, value := range env.Headers {
+			headers = append(headers, kafka.Header{Key: key, Value: []byte(value)})
+		}
+	}
+
+	msg.Trace.EncodeTime = time.Since(start)
+
+	// Keyed messages share a partition, which is what gives Kafka its per-key
+	// ordering; unkeyed ones spread over partitions by ID
+	key := msg.Key
+	if key == "" {
+		key = msg.ID
+	}
+
+	return &kafka.Message{
 		TopicPartition: kafka.TopicPartition{
 			Topic:     &k.topic,
 			Partition: kafka.PartitionAny,
 		},
-		Value: data,
-		Key:   []byte(msg.ID),
+		Value:   env.Value,
+		Key:     []byte(key),
+		Headers: headers,
+	}, nil
+}
+
+// decodeKafkaMessage deserializes a consumed Kafka message and records the
+// time spent doing so in the result's Trace
+func (k *KafkaQueue) decodeKafkaMessage(kafkaMsg *kafka.Message) (*common.Message, error) {
+	start := time.Now()
+
+	env := &common.Envelope{Value: kafkaMsg.Value}
+	if len(kafkaMsg.Headers) > 0 {
+		env.Headers = make(map[string]string, len(kafkaMsg.Headers))
+		for _, h := range kafkaMsg.Headers {
+			env.Headers[h.Key] = string(h.Value)
+		}
 	}
 
-	deliveryChan := make(chan kafka.Event)
-	if err := k.producer.Produce(kafkaMsg, deliveryChan); err != nil {
+	msg, err := k.codec.Decode(env)
+	if err != nil {
+		return nil, err
+	}
+
+	msg.Trace.DecodeTime = time.Since(start)
+	return msg, nil
+}
+
+// Produce sends a message to Kafka and waits for its delivery report
+func (k *KafkaQueue) Produce(ctx context.Context, msg *common.Message) error {
+	if err := ctx.Err(); err != nil {
 		return fmt.Errorf("failed to produce message: %w", err)
 	}
 
-	e := <-deliveryChan
+	if k.producer == nil {
+		return fmt.Errorf("failed to produce message: %w", errNoProducer)
+	}
+
+	kafkaMsg, err := k.newKafkaMessage(msg)
+	if err != nil {
+		return err
+	}
+
+	return k.send(ctx, kafkaMsg)
+}
+
+// send produces kafkaMsg and waits for its delivery report and, on a
+// transactional queue, for its transaction to commit
+func (k *KafkaQueue) send(ctx context.Context, kafkaMsg *kafka.Message) error {
+	// Buffered so the delivery report never blocks librdkafka's poller when
+	// we stop waiting for it because ctx is done
+	deliveryChan := make(chan kafka.Event, 1)
+	txn, err := k.enqueue(kafkaMsg, deliveryChan)
+	if err != nil {
+		return fmt.Errorf("failed to produce message: %w", err)
+	}
+
+	var e kafka.Event
+	select {
+	case e = <-deliveryChan:
+	case <-ctx.Done():
+		return fmt.Errorf("delivery wait aborted: %w", ctx.Err())
+	}
+
 	m, ok := e.(*kafka.Message)
 	if !ok {
-		close(deliveryChan)
 		return fmt.Errorf("unexpected event type")
 	}
-	close(deliveryChan)
 
 	if m.TopicPartition.Error != nil {
 		return fmt.Errorf("delivery failed: %w", m.TopicPartition.Error)
 	}
 
+	return waitTransaction(ctx, txn)
+}
+
+// ProduceBatch enqueues all messages before waiting for any delivery report,
+// letting librdkafka pack them into as few produce requests as possible
+func (k *KafkaQueue) ProduceBatch(ctx context.Context, msgs []*common.Message) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("failed to produce batch: %w", err)
+	}
+	if k.producer == nil {
+		return fmt.Errorf("failed to produce batch: %w", errNoProducer)
+	}
+
+	// Sized to the batch so delivery reports never block the poller
+	deliveryChan := make(chan kafka.Event, len(msgs))
+
+	failed := 0
+	var firstErr error
+	fail := func(err error) {
+		failed++
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	// A batch can span transactions; each one's size is remembered so a
+	// failed commit fails exactly its messages
+	var txns []*transaction
+	txnMessages := make(map[*transaction]int)
+
+	enqueued := 0
+	for _, msg := range msgs {
+		kafkaMsg, err := k.newKafkaMessage(msg)
+		if err != nil {
+			fail(err)
+			continue
+		}
+		txn, err := k.enqueue(kafkaMsg, deliveryChan)
+		if err != nil {
+			fail(fmt.Errorf("failed to produce message: %w", err))
+			continue
+		}
+		if txn != nil {
+			if txnMessages[txn] == 0 {
+				txns = append(txns, txn)
+			}
+			txnMessages[txn]++
+		}
+		enqueued++
+	}
+
+	for i := 0; i < enqueued; i++ {
+		select {
+		case e := <-deliveryChan:
+			m, ok := e.(*kafka.Message)
+			if !ok {
+				fail(fmt.Errorf("unexpected event type"))
+				continue
+			}
+			if m.TopicPartition.Error != nil {
+				fail(fmt.Errorf("delivery failed: %w", m.TopicPartition.Error))
+			}
+		case <-ctx.Done():
+			return &common.BatchError{
+				Failed: failed + enqueued - i,
+				Total:  len(msgs),
+				Err:    fmt.Errorf("delivery wait aborted: %w", ctx.Err()),
+			}
+		}
+	}
+
+	// A failed delivery always fails the commit, so on a transactional
+	// queue the failed commits account for every enqueued message lost
+	if len(txns) > 0 {
+		failed = len(msgs) - enqueued
+		for _, txn := range txns {
+			if err := waitTransaction(ctx, txn); err != nil {
+				failed += txnMessages[txn]
+				if firstErr == nil {
+					firstErr = err
+				}
+			}
+		}
+	}
+
+	if failed > 0 {
+		return &common.BatchError{Failed: failed, Total: len(msgs), Err: firstErr}
+	}
+
 	return nil
 }
 
-// ProduceAsync sends a message to Kafka without waiting for acknowledgment
-func (k *KafkaQueue) ProduceAsync(msg *common.Message) error {
-	data, err := json.Marshal(msg)
-	if err != nil {
-		return fmt.Errorf("failed to marshal message: %w", err)
+// ProduceAsync sends a message to Kafka without waiting for acknowledgment.
+// The delivery report reaches the handler set with SetDeliveryHandler.
+func (k *KafkaQueue) ProduceAsync(ctx context.Context, msg *common.Message) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("failed to produce message: %w", err)
 	}
 
-	kafkaMsg := &kafka.Message{
-		TopicPartition: kafka.TopicPartition{
-			Topic:     &k.topic,
-			Partition: kafka.PartitionAny,
-		},
-		Value: data,
-		Key:   []byte(msg.ID),
+	if k.producer == nil {
+		return fmt.Errorf("failed to produce message: %w", errNoProducer)
 	}
 
-	if err := k.producer.Produce(kafkaMsg, nil); err != nil {
+	kafkaMsg, err := k.newKafkaMessage(msg)
+	if err != nil {
+		return err
+	}
+
+	kafkaMsg.Opaque = &asyncDelivery{msg: msg, enqueued: time.Now()}
+	k.pendingReports.Add(1)
+	if _, err := k.enqueue(kafkaMsg, nil); err != nil {
+		k.pendingReports.Add(-1)
 		return fmt.Errorf("failed to produce message: %w", err)
 	}
 
@@ -130,57 +453,191 @@ func (k *KafkaQueue) ProduceAsync(msg *common.Message) error {
 }
 
 // Consume reads messages from Kafka and processes them with the provided handler
-func (k *KafkaQueue) Consume(handler func(*common.Message) error) error {
+// until ctx is cancelled. A failing handler is retried according to the retry
+// policy, after which the message goes to the dead-letter topic. Kafka has no
+// per-message redelivery, so retries happen in place and hold up the
+// partition, just like a real consumer would.
+func (k *KafkaQueue) Consume(ctx context.Context, handler func(*common.Message) error) error {
+	if k.consumer == nil {
+		return errNoConsumer
+	}
+
 	for {
 		select {
-		case <-k.ctx.Done():
+		case <-ctx.Done():
 			return nil
 		default:
-			msg, err := k.consumer.ReadMessage(100 * time.Millisecond)
-			if err != nil {
-				kafkaErr, ok := err.(kafka.Error)
-				if ok && kafkaErr.Code() == kafka.ErrTimedOut {
+			var msg *kafka.Message
+			switch e := k.consumer.Poll(100).(type) {
+			case *kafka.Message:
+				msg = e
+			case kafka.OffsetsCommitted:
+				k.commits.committed(e)
+				continue
+			case *kafka.Stats:
+				k.stats.record(e.String(), common.RoleConsumer)
+				continue
+			case kafka.Error:
+				// librdkafka recovers from anything but fatal errors by
+				// itself, e.g. a subscribed topic that the producer has
+				// not created yet
+				if !e.IsFatal() {
 					continue
 				}
 				// Check if context is cancelled before returning error
 				select {
-				case <-k.ctx.Done():
+				case <-ctx.Done():
 					return nil
 				default:
-					return fmt.Errorf("consumer error: %w", err)
+					return fmt.Errorf("consumer error: %w", e)
 				}
+			default:
+				// Timeouts and events we have no use for
+				continue
+			}
+			if msg.TopicPartition.Error != nil {
+				return fmt.Errorf("consumer error: %w", msg.TopicPartition.Error)
 			}
 
-			var message common.Message
-			if err := json.Unmarshal(msg.Value, &message); err != nil {
+			received := time.Now()
+			message, err := k.decodeKafkaMessage(msg)
+			if err != nil {
+				// No retry would decode it
+				k.failures.AddUndecodable()
+				k.deadLetter(msg, 0, err)
+				k.commits.handled(msg)
 				continue
 			}
 
-			if err := handler(&message); err != nil {
-				continue
+			message.Trace.ReceiveTime = received
+			message.Trace.Partition = msg.TopicPartition.Partition
+			message.Trace.Partitioned = true
+			// CreateTime is the producer's clock, which says nothing about
+			// the broker; only LogAppendTime topics report dwell
+			if msg.TimestampType == kafka.TimestampLogAppendTime {
+				message.Trace.BrokerTime = msg.Timestamp
+			}
+
+			attempts, err := k.retry.Handle(ctx, message, handler)
+			k.failures.AddAttempts(attempts)
+			if err != nil && ctx.Err() == nil {
+				k.deadLetter(msg, attempts, err)
+			}
+			// A message interrupted by shutdown is left uncommitted so the
+			// next consumer sees it again
+			if err == nil || ctx.Err() == nil {
+				k.commits.handled(msg)
 			}
 		}
 	}
 }
 
-// Flush waits for all messages to be delivered
+// Partitions returns the IDs of the partitions of the topic, from the
+// cluster metadata
+func (k *KafkaQueue) Partitions() ([]int32, error) {
+	if k.consumer == nil {
+		return nil, errNoConsumer
+	}
+
+	metadata, err := k.consumer.GetMetadata(&k.topic, false, 5000)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get metadata of topic %s: %w", k.topic, err)
+	}
+
+	topic, ok := metadata.Topics[k.topic]
+	if !ok || topic.Error.Code() != kafka.ErrNoError {
+		return nil, fmt.Errorf("topic %s is not in the cluster metadata", k.topic)
+	}
+
+	partitions := make([]int32, len(topic.Partitions))
+	for i, partition := range topic.Partitions {
+		partitions[i] = partition.ID
+	}
+	slices.Sort(partitions)
+	return partitions, nil
+}
+
+// deadLetter copies a message whose handler failed every attempt to the
+// dead-letter topic, adding headers that describe the failure
+func (k *KafkaQueue) deadLetter(msg *kafka.Message, attempts int, cause error) {
+	if k.deadLetterTopic == "" {
+		k.failures.AddDiscarded()
+		return
+	}
+	if err := k.ensureProducer(); err != nil {
+		k.failures.AddDiscarded()
+		return
+	}
+
+	headers := append(slices.Clip(msg.Headers),
+		kafka.Header{Key: common.HeaderDeadLetterError, Value: []byte(cause.Error())},
+		kafka.Header{Key: common.HeaderDeadLetterAttempts, Value: []byte(strconv.Itoa(attempts))},
+		kafka.Header{Key: common.HeaderDeadLetterSource, Value: []byte(k.topic)},
+	)
+
+	// Wait for the broker so the counter only includes messages that
+	// actually reached the dead-letter topic
+	err := k.send(context.Background(), &kafka.Message{
+		TopicPartition: kafka.TopicPartition{
+			Topic:     &k.deadLetterTopic,
+			Partition: kafka.PartitionAny,
+		},
+		Key:     msg.Key,
+		Value:   msg.Value,
+		Headers: headers,
+	})
+	if err != nil {
+		k.failures.AddDiscarded()
+		return
+	}
+	k.failures.AddDeadLettered()
+}
+
+// Flush commits the open transaction, if any, and waits for all messages to
+// be delivered and their delivery reports handled. It returns the number of
+// messages still outstanding after timeoutMs.
 func (k *KafkaQueue) Flush(timeoutMs int) int {
-	return k.producer.Flush(timeoutMs)
+	if k.producer == nil {
+		return 0
+	}
+
+	deadline := time.Now().Add(time.Duration(timeoutMs) * time.Millisecond)
+	k.commitOpen()
+	if n := k.producer.Flush(timeoutMs); n > 0 {
+		return n
+	}
+	return k.waitDeliveryReports(deadline)
 }
 
-// Close closes the Kafka producer and consumer
+// Close closes the Kafka producer and consumer. Callers must cancel and wait
+// for every Consume call first, since the consumer cannot be closed while a
+// read is in flight.
 func (k *KafkaQueue) Close() error {
-	// Cancel context to stop all consumers
-	k.cancel()
-
-	// Give consumers a moment to exit gracefully
-	time.Sleep(200 * time.Millisecond)
+	if k.consumer != nil {
+		k.commits.close()
+	}
+	k.closeProducer()
+	if k.consumer == nil {
+		return nil
+	}
+	return k.consumer.Close()
+}
 
+// closeProducer aborts an open transaction and closes the producer once its
+// delivery reports have been read
+func (k *KafkaQueue) closeProducer() {
+	if k.producer == nil {
+		return
+	}
+	k.closeTransactions()
 	k.producer.Close()
-	return k.consumer.Close()
+	k.reportsWg.Wait()
 }
 
 // GetName returns the name of this queue implementation
 func (k *KafkaQueue) GetName() string {
+	if k.transactional() {
+		return "Apache Kafka (transactional)"
+	}
 	return "Apache Kafka"
 }
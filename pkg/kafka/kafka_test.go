@@ -1,30 +1,30 @@
 package kafka
 
 import (
-	"os"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
 	"github.com/praneethys/kafka-bullmq-benchmark/pkg/common"
+	"github.com/praneethys/kafka-bullmq-benchmark/pkg/kafka/kafkatest"
 )
 
+// Tests that need a broker get one from kafkatest.Brokers; testBrokers only
+// fills in configs that are never connected
 const (
 	testBrokers = "localhost:9092"
 	testTopic   = "test-topic"
 	testGroup   = "test-group"
 )
 
-func skipIfNoKafka(t *testing.T) {
-	// Check if KAFKA_TEST environment variable is set
-	if os.Getenv("KAFKA_TEST") != "true" {
-		t.Skip("Skipping Kafka integration test. Set KAFKA_TEST=true to run.")
-	}
-}
-
 func TestNewKafkaQueue(t *testing.T) {
-	skipIfNoKafka(t)
+	brokers := kafkatest.Brokers(t)
 
-	queue, err := NewKafkaQueue(testBrokers, testTopic, testGroup)
+	queue, err := NewKafkaQueue(brokers, testTopic, testGroup)
 	if err != nil {
 		t.Fatalf("Failed to create Kafka queue: %v", err)
 	}
@@ -38,8 +38,8 @@ func TestNewKafkaQueue(t *testing.T) {
 		t.Errorf("Expected topic '%s', got '%s'", testTopic, queue.topic)
 	}
 
-	if queue.brokers != testBrokers {
-		t.Errorf("Expected brokers '%s', got '%s'", testBrokers, queue.brokers)
+	if queue.brokers != brokers {
+		t.Errorf("Expected brokers '%s', got '%s'", brokers, queue.brokers)
 	}
 
 	if queue.producer == nil {
@@ -62,10 +62,62 @@ func TestNewKafkaQueueInvalidBroker(t *testing.T) {
 	}
 }
 
+func TestKafkaSingleRoleQueues(t *testing.T) {
+	// Creating clients does not need a running broker
+	producer, err := NewKafkaProducer("invalid:9999", testTopic, DefaultKafkaOptions())
+	if err != nil {
+		t.Fatalf("Failed to create producer queue: %v", err)
+	}
+	defer producer.Close()
+
+	if producer.consumer != nil || producer.ClientConfig(common.RoleConsumer) != nil {
+		t.Error("Expected the producer queue to have no consumer")
+	}
+
+	err = producer.Consume(context.Background(), func(*common.Message) error { return nil })
+	if !errors.Is(err, errNoConsumer) {
+		t.Errorf("Expected errNoConsumer, got %v", err)
+	}
+
+	consumer, err := NewKafkaConsumer("invalid:9999", testTopic, testGroup, DefaultKafkaOptions())
+	if err != nil {
+		t.Fatalf("Failed to create consumer queue: %v", err)
+	}
+	defer consumer.Close()
+
+	if consumer.producer != nil || consumer.ClientConfig(common.RoleProducer) != nil {
+		t.Error("Expected the consumer queue to have no producer")
+	}
+
+	msg := &common.Message{ID: "msg-1", Payload: []byte("payload"), Timestamp: time.Now()}
+	if err := consumer.Produce(context.Background(), msg); !errors.Is(err, errNoProducer) {
+		t.Errorf("Expected errNoProducer, got %v", err)
+	}
+
+	if n := consumer.Flush(100); n != 0 {
+		t.Errorf("Expected nothing to flush, got %d", n)
+	}
+}
+
+func TestParseConfigList(t *testing.T) {
+	config, err := parseConfigList("retention.ms=600000, cleanup.policy = delete")
+	if err != nil {
+		t.Fatalf("parseConfigList failed: %v", err)
+	}
+
+	if len(config) != 2 || config["retention.ms"] != "600000" || config["cleanup.policy"] != "delete" {
+		t.Errorf("Expected two parsed configs, got %v", config)
+	}
+
+	if _, err := parseConfigList("retention.ms"); err == nil {
+		t.Error("Expected error for config without value, got nil")
+	}
+}
+
 func TestKafkaGetName(t *testing.T) {
-	skipIfNoKafka(t)
+	brokers := kafkatest.Brokers(t)
 
-	queue, err := NewKafkaQueue(testBrokers, testTopic, testGroup)
+	queue, err := NewKafkaQueue(brokers, testTopic, testGroup)
 	if err != nil {
 		t.Fatalf("Failed to create Kafka queue: %v", err)
 	}
@@ -78,9 +130,9 @@ func TestKafkaGetName(t *testing.T) {
 }
 
 func TestKafkaProduce(t *testing.T) {
-	skipIfNoKafka(t)
+	brokers := kafkatest.Brokers(t)
 
-	queue, err := NewKafkaQueue(testBrokers, testTopic+"-produce", testGroup)
+	queue, err := NewKafkaQueue(brokers, testTopic+"-produce", testGroup)
 	if err != nil {
 		t.Fatalf("Failed to create Kafka queue: %v", err)
 	}
@@ -92,7 +144,7 @@ func TestKafkaProduce(t *testing.T) {
 		Timestamp: time.Now(),
 	}
 
-	err = queue.Produce(msg)
+	err = queue.Produce(context.Background(), msg)
 	if err != nil {
 		t.Errorf("Failed to produce message: %v", err)
 	}
@@ -105,9 +157,9 @@ func TestKafkaProduce(t *testing.T) {
 }
 
 func TestKafkaProduceAsync(t *testing.T) {
-	skipIfNoKafka(t)
+	brokers := kafkatest.Brokers(t)
 
-	queue, err := NewKafkaQueue(testBrokers, testTopic+"-async", testGroup)
+	queue, err := NewKafkaQueue(brokers, testTopic+"-async", testGroup)
 	if err != nil {
 		t.Fatalf("Failed to create Kafka queue: %v", err)
 	}
@@ -119,7 +171,7 @@ func TestKafkaProduceAsync(t *testing.T) {
 		Timestamp: time.Now(),
 	}
 
-	err = queue.ProduceAsync(msg)
+	err = queue.ProduceAsync(context.Background(), msg)
 	if err != nil {
 		t.Errorf("Failed to produce async message: %v", err)
 	}
@@ -131,14 +183,37 @@ func TestKafkaProduceAsync(t *testing.T) {
 	}
 }
 
+func TestKafkaProduceBatch(t *testing.T) {
+	brokers := kafkatest.Brokers(t)
+
+	queue, err := NewKafkaQueue(brokers, testTopic+"-batch", testGroup)
+	if err != nil {
+		t.Fatalf("Failed to create Kafka queue: %v", err)
+	}
+	defer queue.Close()
+
+	msgs := make([]*common.Message, 50)
+	for i := range msgs {
+		msgs[i] = &common.Message{
+			ID:        fmt.Sprintf("batch-msg-%d", i),
+			Payload:   []byte("batch payload"),
+			Timestamp: time.Now(),
+		}
+	}
+
+	if err := queue.ProduceBatch(context.Background(), msgs); err != nil {
+		t.Errorf("Failed to produce batch: %v", err)
+	}
+}
+
 func TestKafkaProduceAndConsume(t *testing.T) {
-	skipIfNoKafka(t)
+	brokers := kafkatest.Brokers(t)
 
 	topicName := testTopic + "-produce-consume"
 	groupName := testGroup + "-pc"
 
 	// Create producer queue
-	producerQueue, err := NewKafkaQueue(testBrokers, topicName, groupName)
+	producerQueue, err := NewKafkaQueue(brokers, topicName, groupName)
 	if err != nil {
 		t.Fatalf("Failed to create producer queue: %v", err)
 	}
@@ -150,6 +225,7 @@ func TestKafkaProduceAndConsume(t *testing.T) {
 			ID:        "msg-1",
 			Payload:   []byte("payload-1"),
 			Timestamp: time.Now(),
+			Headers:   map[string]string{"trace-id": "trace-1", "tenant-id": "acme"},
 		},
 		{
 			ID:        "msg-2",
@@ -164,7 +240,7 @@ func TestKafkaProduceAndConsume(t *testing.T) {
 	}
 
 	for _, msg := range testMessages {
-		if prodErr := producerQueue.Produce(msg); prodErr != nil {
+		if prodErr := producerQueue.Produce(context.Background(), msg); prodErr != nil {
 			t.Fatalf("Failed to produce message %s: %v", msg.ID, prodErr)
 		}
 	}
@@ -175,7 +251,7 @@ func TestKafkaProduceAndConsume(t *testing.T) {
 	time.Sleep(500 * time.Millisecond)
 
 	// Create consumer queue with different consumer group to read from beginning
-	consumerQueue, err := NewKafkaQueue(testBrokers, topicName, groupName+"-consumer")
+	consumerQueue, err := NewKafkaQueue(brokers, topicName, groupName+"-consumer")
 	if err != nil {
 		t.Fatalf("Failed to create consumer queue: %v", err)
 	}
@@ -184,14 +260,20 @@ func TestKafkaProduceAndConsume(t *testing.T) {
 	// Consume messages
 	receivedCount := 0
 	receivedMessages := make(map[string]bool)
+	receivedHeaders := make(map[string]map[string]string)
 
-	done := make(chan bool)
+	ctx, cancel := context.WithCancel(context.Background())
+	consumerDone := make(chan struct{})
+
+	done := make(chan bool, 1)
 	go func() {
-		err := consumerQueue.Consume(func(msg *common.Message) error {
+		defer close(consumerDone)
+		err := consumerQueue.Consume(ctx, func(msg *common.Message) error {
 			receivedMessages[msg.ID] = true
+			receivedHeaders[msg.ID] = msg.Headers
 			receivedCount++
 
-			if receivedCount >= len(testMessages) {
+			if receivedCount == len(testMessages) {
 				done <- true
 			}
 			return nil
@@ -209,18 +291,161 @@ func TestKafkaProduceAndConsume(t *testing.T) {
 		t.Errorf("Timeout waiting for messages. Received %d/%d", receivedCount, len(testMessages))
 	}
 
+	// Stop the consumer and wait for it before the deferred Close runs
+	cancel()
+	<-consumerDone
+
 	// Verify all messages were received
 	for _, msg := range testMessages {
 		if !receivedMessages[msg.ID] {
 			t.Errorf("Message %s was not received", msg.ID)
 		}
+
+		for key, value := range msg.Headers {
+			if got := receivedHeaders[msg.ID][key]; got != value {
+				t.Errorf("Message %s: expected header %s=%s, got %q", msg.ID, key, value, got)
+			}
+		}
+	}
+}
+
+func TestKafkaCodecs(t *testing.T) {
+	brokers := kafkatest.Brokers(t)
+
+	for _, name := range common.CodecNames() {
+		t.Run(name, func(t *testing.T) {
+			codec, _ := common.LookupCodec(name)
+			topicName := testTopic + "-codec-" + name
+
+			producerQueue, err := NewKafkaQueue(brokers, topicName, testGroup+"-codec-"+name)
+			if err != nil {
+				t.Fatalf("Failed to create producer queue: %v", err)
+			}
+			defer producerQueue.Close()
+			producerQueue.SetCodec(codec)
+
+			msg := &common.Message{
+				ID:        "codec-" + name,
+				Payload:   []byte{0x00, 0xff, 0x10},
+				Timestamp: time.Now(),
+				Headers:   map[string]string{"trace-id": "abc"},
+			}
+			if err := producerQueue.Produce(context.Background(), msg); err != nil {
+				t.Fatalf("Failed to produce message: %v", err)
+			}
+
+			if msg.Trace.EncodeTime <= 0 {
+				t.Errorf("Expected encode time to be recorded, got %v", msg.Trace.EncodeTime)
+			}
+
+			consumerQueue, err := NewKafkaQueue(brokers, topicName, testGroup+"-codec-consumer-"+name)
+			if err != nil {
+				t.Fatalf("Failed to create consumer queue: %v", err)
+			}
+			defer consumerQueue.Close()
+			consumerQueue.SetCodec(codec)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			var received *common.Message
+			_ = consumerQueue.Consume(ctx, func(m *common.Message) error { //nolint:errcheck // Returns nil on cancel
+				received = m
+				cancel()
+				return nil
+			})
+
+			if received == nil {
+				t.Fatal("Timeout waiting for message")
+			}
+
+			if received.ID != msg.ID || string(received.Payload) != string(msg.Payload) {
+				t.Errorf("Expected message %s with payload %v, got %s with %v", msg.ID, msg.Payload, received.ID, received.Payload)
+			}
+
+			if received.Headers["trace-id"] != "abc" {
+				t.Errorf("Expected header trace-id=abc, got %v", received.Headers)
+			}
+		})
+	}
+}
+
+func TestKafkaDeadLetter(t *testing.T) {
+	brokers := kafkatest.Brokers(t)
+
+	topicName := testTopic + "-dlq-source"
+	deadLetterTopic := testTopic + "-dlq"
+
+	queue, err := NewKafkaQueue(brokers, topicName, testGroup+"-dlq")
+	if err != nil {
+		t.Fatalf("Failed to create Kafka queue: %v", err)
+	}
+	defer queue.Close()
+	queue.SetRetryPolicy(common.RetryPolicy{MaxAttempts: 3, Backoff: 10 * time.Millisecond})
+	queue.SetDeadLetterTopic(deadLetterTopic)
+
+	msg := &common.Message{ID: "dlq-msg", Payload: []byte("poison"), Timestamp: time.Now()}
+	if err := queue.Produce(context.Background(), msg); err != nil {
+		t.Fatalf("Failed to produce message: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	attempts := 0
+	_ = queue.Consume(ctx, func(*common.Message) error { //nolint:errcheck // Returns nil on cancel
+		attempts++
+		if attempts == 3 {
+			// Let Consume dead-letter the message before stopping
+			time.AfterFunc(time.Second, cancel)
+		}
+		return errors.New("handler failed")
+	})
+
+	if attempts != 3 {
+		t.Errorf("Expected 3 attempts, got %d", attempts)
+	}
+
+	stats := queue.FailureStats()
+	if stats.Redeliveries != 2 || stats.DeadLettered != 1 {
+		t.Errorf("Expected 2 redeliveries and 1 dead-lettered message, got %+v", stats)
+	}
+
+	dlq, err := NewKafkaQueue(brokers, deadLetterTopic, testGroup+"-dlq-reader")
+	if err != nil {
+		t.Fatalf("Failed to create dead-letter queue: %v", err)
+	}
+	defer dlq.Close()
+
+	dlqCtx, dlqCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer dlqCancel()
+
+	var received *common.Message
+	_ = dlq.Consume(dlqCtx, func(m *common.Message) error { //nolint:errcheck // Returns nil on cancel
+		if m.ID == msg.ID {
+			received = m
+			dlqCancel()
+		}
+		return nil
+	})
+
+	if received == nil {
+		t.Fatal("Timeout waiting for dead-lettered message")
+	}
+
+	if received.Headers[common.HeaderDeadLetterAttempts] != "3" {
+		t.Errorf("Expected %s=3, got %q", common.HeaderDeadLetterAttempts, received.Headers[common.HeaderDeadLetterAttempts])
+	}
+
+	if received.Headers[common.HeaderDeadLetterSource] != topicName {
+		t.Errorf("Expected %s=%s, got %q", common.HeaderDeadLetterSource, topicName, received.Headers[common.HeaderDeadLetterSource])
 	}
 }
 
 func TestKafkaProduceInvalidMessage(t *testing.T) {
-	skipIfNoKafka(t)
+	brokers := kafkatest.Brokers(t)
 
-	queue, err := NewKafkaQueue(testBrokers, testTopic, testGroup)
+	queue, err := NewKafkaQueue(brokers, testTopic, testGroup)
 	if err != nil {
 		t.Fatalf("Failed to create Kafka queue: %v", err)
 	}
@@ -235,13 +460,13 @@ func TestKafkaProduceInvalidMessage(t *testing.T) {
 	}
 
 	// This should not panic
-	_ = queue.Produce(msg) //nolint:errcheck // Intentionally testing edge case
+	_ = queue.Produce(context.Background(), msg) //nolint:errcheck // Intentionally testing edge case
 }
 
 func TestKafkaFlush(t *testing.T) {
-	skipIfNoKafka(t)
+	brokers := kafkatest.Brokers(t)
 
-	queue, err := NewKafkaQueue(testBrokers, testTopic, testGroup)
+	queue, err := NewKafkaQueue(brokers, testTopic, testGroup)
 	if err != nil {
 		t.Fatalf("Failed to create Kafka queue: %v", err)
 	}
@@ -254,7 +479,7 @@ func TestKafkaFlush(t *testing.T) {
 			Payload:   []byte("test"),
 			Timestamp: time.Now(),
 		}
-		_ = queue.ProduceAsync(msg) //nolint:errcheck // Fire and forget for flush test
+		_ = queue.ProduceAsync(context.Background(), msg) //nolint:errcheck // Fire and forget for flush test
 	}
 
 	// Flush with timeout
@@ -267,9 +492,9 @@ func TestKafkaFlush(t *testing.T) {
 }
 
 func TestKafkaClose(t *testing.T) {
-	skipIfNoKafka(t)
+	brokers := kafkatest.Brokers(t)
 
-	queue, err := NewKafkaQueue(testBrokers, testTopic, testGroup)
+	queue, err := NewKafkaQueue(brokers, testTopic, testGroup)
 	if err != nil {
 		t.Fatalf("Failed to create Kafka queue: %v", err)
 	}
@@ -288,13 +513,13 @@ func TestKafkaClose(t *testing.T) {
 }
 
 func TestKafkaHighThroughput(t *testing.T) {
-	skipIfNoKafka(t)
+	brokers := kafkatest.Brokers(t)
 
 	if testing.Short() {
 		t.Skip("Skipping high-throughput test in short mode")
 	}
 
-	queue, err := NewKafkaQueue(testBrokers, testTopic+"-throughput", testGroup)
+	queue, err := NewKafkaQueue(brokers, testTopic+"-throughput", testGroup)
 	if err != nil {
 		t.Fatalf("Failed to create Kafka queue: %v", err)
 	}
@@ -310,7 +535,7 @@ func TestKafkaHighThroughput(t *testing.T) {
 			Payload:   make([]byte, 1024), // 1KB payload
 			Timestamp: time.Now(),
 		}
-		if err := queue.ProduceAsync(msg); err != nil {
+		if err := queue.ProduceAsync(context.Background(), msg); err != nil {
 			t.Errorf("Failed to produce message %d: %v", i, err)
 		}
 	}
@@ -325,3 +550,180 @@ func TestKafkaHighThroughput(t *testing.T) {
 		t.Logf("Warning: Low throughput %.2f msg/sec", throughput)
 	}
 }
+
+func TestKafkaPartitions(t *testing.T) {
+	brokers := kafkatest.Brokers(t)
+
+	topicName := testTopic + "-partitions"
+
+	queue, err := NewKafkaQueue(brokers, topicName, testGroup+"-partitions")
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+	defer queue.Close()
+
+	msg := &common.Message{ID: "msg-1", Payload: []byte("payload"), Timestamp: time.Now()}
+	if err := queue.Produce(context.Background(), msg); err != nil {
+		t.Fatalf("Failed to produce message: %v", err)
+	}
+
+	partitions, err := queue.Partitions()
+	if err != nil {
+		t.Fatalf("Partitions failed: %v", err)
+	}
+	if len(partitions) == 0 {
+		t.Fatal("Expected at least one partition")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var received *common.Message
+	err = queue.Consume(ctx, func(m *common.Message) error {
+		if m.ID == msg.ID {
+			received = m
+			cancel()
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Consume failed: %v", err)
+	}
+	if received == nil {
+		t.Fatal("Timeout waiting for the message")
+	}
+
+	if !received.Trace.Partitioned {
+		t.Error("Expected the consumed message to carry its partition")
+	}
+
+	found := false
+	for _, p := range partitions {
+		found = found || p == received.Trace.Partition
+	}
+	if !found {
+		t.Errorf("Expected partition %d to be one of %v", received.Trace.Partition, partitions)
+	}
+}
+
+func TestKafkaUndecodableMessage(t *testing.T) {
+	brokers := kafkatest.Brokers(t)
+
+	topicName := testTopic + "-undecodable"
+
+	queue, err := NewKafkaQueue(brokers, topicName, testGroup+"-undecodable")
+	if err != nil {
+		t.Fatalf("Failed to create Kafka queue: %v", err)
+	}
+	defer queue.Close()
+	queue.SetDeadLetterTopic(topicName + "-dlq")
+
+	// Not the JSON the codec expects
+	err = queue.send(context.Background(), &kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &topicName, Partition: kafka.PartitionAny},
+		Value:          []byte("not json"),
+	})
+	if err != nil {
+		t.Fatalf("Failed to produce message: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// The handler only sees the message produced after the bad one
+	if err := queue.Produce(ctx, &common.Message{ID: "after", Payload: []byte("ok"), Timestamp: time.Now()}); err != nil {
+		t.Fatalf("Failed to produce message: %v", err)
+	}
+	// The messages may land on different partitions and arrive in any
+	// order, so stop once both were dealt with
+	var mu sync.Mutex
+	var received []string
+	go func() {
+		for ctx.Err() == nil {
+			mu.Lock()
+			handled := len(received)
+			mu.Unlock()
+			if handled > 0 && queue.FailureStats().DeadLettered > 0 {
+				cancel()
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+	_ = queue.Consume(ctx, func(m *common.Message) error { //nolint:errcheck // Returns nil on cancel
+		mu.Lock()
+		defer mu.Unlock()
+		received = append(received, m.ID)
+		return nil
+	})
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(received) != 1 || received[0] != "after" {
+		t.Errorf("Expected only the decodable message handled, got %v", received)
+	}
+
+	if stats := queue.FailureStats(); stats.Undecodable != 1 || stats.DeadLettered != 1 {
+		t.Errorf("Expected 1 undecodable message dead-lettered, got %+v", stats)
+	}
+}
+
+func TestKafkaSplitClients(t *testing.T) {
+	brokers := kafkatest.Brokers(t)
+
+	topicName := testTopic + "-split"
+	deadLetterTopic := topicName + "-dlq"
+
+	producer, err := NewKafkaProducer(brokers, topicName, DefaultKafkaOptions())
+	if err != nil {
+		t.Fatalf("Failed to create producer queue: %v", err)
+	}
+	defer producer.Close()
+
+	consumerOpts := DefaultKafkaOptions()
+	consumerOpts.StatsInterval = 100 * time.Millisecond
+	consumer, err := NewKafkaConsumer(brokers, topicName, testGroup+"-split", consumerOpts)
+	if err != nil {
+		t.Fatalf("Failed to create consumer queue: %v", err)
+	}
+	defer consumer.Close()
+	consumer.SetDeadLetterTopic(deadLetterTopic)
+
+	msg := &common.Message{ID: "split-msg", Payload: []byte("payload"), Timestamp: time.Now()}
+	if err := producer.Produce(context.Background(), msg); err != nil {
+		t.Fatalf("Failed to produce message: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Failing the only attempt makes the consumer create its dead-letter
+	// producer
+	received := false
+	_ = consumer.Consume(ctx, func(m *common.Message) error { //nolint:errcheck // Returns nil on cancel
+		if m.ID != msg.ID {
+			return nil
+		}
+		received = true
+		time.AfterFunc(time.Second, cancel)
+		return errors.New("handler failed")
+	})
+
+	if !received {
+		t.Fatal("Timeout waiting for the message")
+	}
+
+	if stats := consumer.FailureStats(); stats.DeadLettered != 1 {
+		t.Errorf("Expected 1 dead-lettered message, got %+v", stats)
+	}
+
+	if consumer.ClientConfig(common.RoleProducer) == nil {
+		t.Error("Expected the dead-letter producer to report its config")
+	}
+
+	// Only the measured clients report statistics
+	for _, sample := range consumer.ClientStats() {
+		if sample.Role != common.RoleConsumer {
+			t.Fatalf("Expected no statistics from the dead-letter producer, got %+v", sample)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+// Package kafkatest provides Kafka clusters for tests. By default they run on
+// librdkafka's in-process mock cluster, so Kafka tests need neither Docker
+// nor a network; KAFKA_TEST=true points them at a real broker instead.
+package kafkatest
+
+import (
+	"os"
+	"testing"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+// DefaultBrokers is the real broker used when KAFKA_TEST=true and
+// KAFKA_BROKERS is not set, matching docker-compose.yml
+const DefaultBrokers = "localhost:9092"
+
+// Cluster is an in-process mock Kafka cluster that lives until the end of the
+// test that created it
+type Cluster struct {
+	mock *kafka.MockCluster
+}
+
+// NewCluster starts a mock cluster with the given number of brokers. Topics
+// are created on first use with four partitions, or up front with
+// CreateTopic.
+func NewCluster(t testing.TB, brokers int) *Cluster {
+	t.Helper()
+
+	mock, err := kafka.NewMockCluster(brokers)
+	if err != nil {
+		t.Fatalf("Failed to start mock Kafka cluster: %v", err)
+	}
+	t.Cleanup(mock.Close)
+
+	return &Cluster{mock: mock}
+}
+
+// BootstrapServers returns the addresses clients connect to
+func (c *Cluster) BootstrapServers() string {
+	return c.mock.BootstrapServers()
+}
+
+// CreateTopic creates a topic with the given partition count and replication
+// factor, which must not exceed the number of brokers
+func (c *Cluster) CreateTopic(t testing.TB, topic string, partitions, replication int) {
+	t.Helper()
+
+	if err := c.mock.CreateTopic(topic, partitions, replication); err != nil {
+		t.Fatalf("Failed to create topic %s: %v", topic, err)
+	}
+}
+
+// Real reports whether tests run against a real broker
+func Real() bool {
+	return os.Getenv("KAFKA_TEST") == "true"
+}
+
+// Brokers returns the bootstrap servers for a test: the real broker when
+// Real, otherwise a fresh single-broker mock cluster
+func Brokers(t testing.TB) string {
+	t.Helper()
+
+	if Real() {
+		if brokers := os.Getenv("KAFKA_BROKERS"); brokers != "" {
+			return brokers
+		}
+		return DefaultBrokers
+	}
+	return NewCluster(t, 1).BootstrapServers()
+}
+
+// SkipIfMock skips tests that need broker features the mock cluster lacks,
+// such as the topic admin API
+func SkipIfMock(t testing.TB) {
+	t.Helper()
+
+	if !Real() {
+		t.Skip("Skipping test that needs a real Kafka broker. Set KAFKA_TEST=true to run.")
+	}
+}
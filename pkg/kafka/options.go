@@ -0,0 +1,223 @@
+package kafka
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+// KafkaOptions holds the librdkafka settings a KafkaQueue is created with.
+// The typed fields cover the settings that matter most for throughput and
+// delivery guarantees; Producer and Consumer pass any other librdkafka
+// property through and take precedence over the typed fields.
+type KafkaOptions struct {
+	// Producer
+	Acks              string // "0", "1" or "all"
+	Compression       string // none, gzip, snappy, lz4 or zstd
+	LingerMs          int
+	BatchBytes        int // batch.size
+	QueueMaxMessages  int // queue.buffering.max.messages
+	QueueMaxKBytes    int // queue.buffering.max.kbytes
+	MaxInFlight       int // max.in.flight.requests.per.connection
+	EnableIdempotence bool
+	// TransactionalID makes the producer transactional, committing every
+	// TransactionSize messages. It implies idempotence.
+	TransactionalID string
+	TransactionSize int
+	// Consumer
+	AutoOffsetReset        string // earliest or latest
+	EnableAutoCommit       bool
+	FetchMinBytes          int
+	FetchWaitMaxMs         int
+	MaxPartitionFetchBytes int
+	IsolationLevel         string // read_committed or read_uncommitted; empty keeps the librdkafka default
+	// GroupInstanceID makes the consumer a static group member, which can
+	// leave and rejoin within the session timeout without a rebalance
+	GroupInstanceID string
+	// CommitMode selects how offsets are committed (see CommitModes);
+	// EnableAutoCommit only applies to CommitAuto
+	CommitMode      string
+	CommitBatchSize int           // messages per commit in CommitBatch mode
+	CommitInterval  time.Duration // time between commits in CommitAsync mode
+
+	// Security applies to both clients
+	Security KafkaSecurity
+
+	// StatsInterval is how often both clients report librdkafka statistics;
+	// 0 disables them
+	StatsInterval time.Duration
+
+	// Extra librdkafka properties, e.g. from -kafka-producer-config or a
+	// config file
+	Producer map[string]string
+	Consumer map[string]string
+}
+
+// DefaultKafkaOptions returns the high-throughput settings the benchmark
+// uses unless told otherwise
+func DefaultKafkaOptions() KafkaOptions {
+	return KafkaOptions{
+		Acks:                   "1", // Wait for leader acknowledgment
+		Compression:            "lz4",
+		LingerMs:               10,
+		BatchBytes:             1000000,
+		QueueMaxMessages:       100000,
+		QueueMaxKBytes:         1048576, // 1GB
+		MaxInFlight:            5,
+		AutoOffsetReset:        "earliest",
+		EnableAutoCommit:       true,
+		CommitMode:             CommitAuto,
+		CommitBatchSize:        100,
+		CommitInterval:         time.Second,
+		FetchMinBytes:          1024,
+		FetchWaitMaxMs:         100,
+		MaxPartitionFetchBytes: 10485760, // 10MB
+		StatsInterval:          time.Second,
+	}
+}
+
+// producerConfig returns the librdkafka configuration of the producer
+func (o KafkaOptions) producerConfig(brokers string) kafka.ConfigMap {
+	config := kafka.ConfigMap{
+		"bootstrap.servers":                     brokers,
+		"acks":                                  o.Acks,
+		"compression.type":                      o.Compression,
+		"linger.ms":                             o.LingerMs,
+		"batch.size":                            o.BatchBytes,
+		"queue.buffering.max.messages":          o.QueueMaxMessages,
+		"queue.buffering.max.kbytes":            o.QueueMaxKBytes,
+		"max.in.flight.requests.per.connection": o.MaxInFlight,
+		"enable.idempotence":                    o.EnableIdempotence,
+	}
+	if o.TransactionalID != "" {
+		config["transactional.id"] = o.TransactionalID
+		config["enable.idempotence"] = true
+	}
+	o.Security.apply(config)
+	if o.StatsInterval > 0 {
+		config["statistics.interval.ms"] = int(o.StatsInterval.Milliseconds())
+	}
+	for key, value := range o.Producer {
+		config[key] = value
+	}
+	// The idempotent producer refuses to start with anything but acks=all,
+	// whether idempotence comes from the typed option or as a passthrough
+	// string
+	if idempotent(config["enable.idempotence"]) {
+		config["enable.idempotence"] = true
+		config["acks"] = "all"
+	}
+	return config
+}
+
+// idempotent reports whether an enable.idempotence value turns it on
+func idempotent(value kafka.ConfigValue) bool {
+	switch v := value.(type) {
+	case bool:
+		return v
+	case string:
+		enabled, err := strconv.ParseBool(strings.TrimSpace(v))
+		return err == nil && enabled
+	default:
+		return false
+	}
+}
+
+// consumerConfig returns the librdkafka configuration of the consumer
+func (o KafkaOptions) consumerConfig(brokers, consumerGroup string) kafka.ConfigMap {
+	config := kafka.ConfigMap{
+		"bootstrap.servers":         brokers,
+		"group.id":                  consumerGroup,
+		"auto.offset.reset":         o.AutoOffsetReset,
+		"fetch.min.bytes":           o.FetchMinBytes,
+		"fetch.wait.max.ms":         o.FetchWaitMaxMs,
+		"max.partition.fetch.bytes": o.MaxPartitionFetchBytes,
+	}
+	if o.IsolationLevel != "" {
+		config["isolation.level"] = o.IsolationLevel
+	}
+	if o.GroupInstanceID != "" {
+		config["group.instance.id"] = o.GroupInstanceID
+	}
+	o.Security.apply(config)
+	if o.StatsInterval > 0 {
+		config["statistics.interval.ms"] = int(o.StatsInterval.Milliseconds())
+	}
+	for key, value := range commitConfig(o.CommitMode, o.EnableAutoCommit) {
+		config[key] = value
+	}
+	for key, value := range o.Consumer {
+		config[key] = value
+	}
+	return config
+}
+
+// LoadConfigFile reads librdkafka properties from a file with one key=value
+// per line. Blank lines and lines starting with # are skipped. Properties
+// before any section header apply to both clients; those after a [producer]
+// or [consumer] header apply to that client only.
+func LoadConfigFile(path string) (producer, consumer map[string]string, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open Kafka config file: %w", err)
+	}
+	defer f.Close()
+
+	producer = make(map[string]string)
+	consumer = make(map[string]string)
+	targets := []map[string]string{producer, consumer}
+
+	scanner := bufio.NewScanner(f)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+			continue
+		case line == "[producer]":
+			targets = []map[string]string{producer}
+			continue
+		case line == "[consumer]":
+			targets = []map[string]string{consumer}
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, nil, fmt.Errorf("%s:%d: expected key=value or a [producer]/[consumer] section, got %q", path, lineNo, line)
+		}
+		for _, target := range targets {
+			target[key] = strings.TrimSpace(value)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, fmt.Errorf("failed to read Kafka config file: %w", err)
+	}
+
+	return producer, consumer, nil
+}
+
+// secretKeyMarkers identify properties whose values are left out of reports
+var secretKeyMarkers = []string{"password", "secret", "key.pem", "token"}
+
+// reportedConfig converts config to strings for the benchmark report, with
+// credentials masked
+func reportedConfig(config kafka.ConfigMap) map[string]string {
+	reported := make(map[string]string, len(config))
+	for key, value := range config {
+		s := fmt.Sprint(value)
+		for _, marker := range secretKeyMarkers {
+			if strings.Contains(key, marker) {
+				s = "[redacted]"
+				break
+			}
+		}
+		reported[key] = s
+	}
+	return reported
+}
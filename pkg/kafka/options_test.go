@@ -0,0 +1,171 @@
+package kafka
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDefaultKafkaOptions(t *testing.T) {
+	producer := DefaultKafkaOptions().producerConfig(testBrokers)
+
+	if producer["acks"] != "1" || producer["compression.type"] != "lz4" || producer["linger.ms"] != 10 {
+		t.Errorf("Expected acks=1, compression.type=lz4, linger.ms=10, got %v", producer)
+	}
+
+	consumer := DefaultKafkaOptions().consumerConfig(testBrokers, testGroup)
+	if consumer["group.id"] != testGroup || consumer["enable.auto.commit"] != true {
+		t.Errorf("Expected group.id=%s and enable.auto.commit=true, got %v", testGroup, consumer)
+	}
+}
+
+func TestKafkaOptionsPassthroughOverrides(t *testing.T) {
+	opts := DefaultKafkaOptions()
+	opts.Producer = map[string]string{"acks": "all", "message.max.bytes": "2000000"}
+
+	producer := opts.producerConfig(testBrokers)
+	if producer["acks"] != "all" {
+		t.Errorf("Expected passthrough acks=all to win, got %v", producer["acks"])
+	}
+
+	if producer["message.max.bytes"] != "2000000" {
+		t.Errorf("Expected message.max.bytes=2000000, got %v", producer["message.max.bytes"])
+	}
+}
+
+func TestKafkaOptionsIdempotence(t *testing.T) {
+	tests := []struct {
+		name       string
+		modify     func(*KafkaOptions)
+		idempotent bool
+	}{
+		{name: "default", modify: func(*KafkaOptions) {}},
+		{name: "typed option", modify: func(o *KafkaOptions) { o.EnableIdempotence = true }, idempotent: true},
+		{
+			name:       "passthrough",
+			modify:     func(o *KafkaOptions) { o.Producer = map[string]string{"enable.idempotence": "true"} },
+			idempotent: true,
+		},
+		{
+			name: "passthrough off",
+			modify: func(o *KafkaOptions) {
+				o.EnableIdempotence = true
+				o.Producer = map[string]string{"enable.idempotence": "false"}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := DefaultKafkaOptions()
+			tt.modify(&opts)
+			producer := opts.producerConfig(testBrokers)
+
+			idempotent := producer["enable.idempotence"] == true
+			if idempotent != tt.idempotent {
+				t.Errorf("Expected idempotence %v, got %v", tt.idempotent, producer["enable.idempotence"])
+			}
+			if tt.idempotent && producer["acks"] != "all" {
+				t.Errorf("Expected acks=all for the idempotent producer, got %v", producer["acks"])
+			}
+			if !tt.idempotent && producer["acks"] != "1" {
+				t.Errorf("Expected the default acks=1, got %v", producer["acks"])
+			}
+		})
+	}
+}
+
+func TestLoadConfigFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "client.properties")
+	content := `# shared
+security.protocol=PLAINTEXT
+
+[producer]
+acks = all
+
+[consumer]
+fetch.min.bytes=1
+`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+
+	producer, consumer, err := LoadConfigFile(path)
+	if err != nil {
+		t.Fatalf("LoadConfigFile failed: %v", err)
+	}
+
+	if producer["security.protocol"] != "PLAINTEXT" || consumer["security.protocol"] != "PLAINTEXT" {
+		t.Errorf("Expected shared property in both clients, got producer %v consumer %v", producer, consumer)
+	}
+
+	if producer["acks"] != "all" || consumer["acks"] != "" {
+		t.Errorf("Expected acks=all for the producer only, got producer %v consumer %v", producer, consumer)
+	}
+
+	if consumer["fetch.min.bytes"] != "1" || producer["fetch.min.bytes"] != "" {
+		t.Errorf("Expected fetch.min.bytes=1 for the consumer only, got producer %v consumer %v", producer, consumer)
+	}
+}
+
+func TestLoadConfigFileInvalidLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "client.properties")
+	if err := os.WriteFile(path, []byte("acks\n"), 0o600); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+
+	if _, _, err := LoadConfigFile(path); err == nil {
+		t.Error("Expected error for line without value, got nil")
+	}
+}
+
+func TestReportedConfigRedactsSecrets(t *testing.T) {
+	opts := DefaultKafkaOptions()
+	opts.Producer = map[string]string{"sasl.password": "hunter2"}
+
+	reported := reportedConfig(opts.producerConfig(testBrokers))
+
+	if reported["sasl.password"] != "[redacted]" {
+		t.Errorf("Expected sasl.password to be redacted, got %q", reported["sasl.password"])
+	}
+
+	if reported["linger.ms"] != "10" {
+		t.Errorf("Expected linger.ms '10', got %q", reported["linger.ms"])
+	}
+}
+
+func TestBackendKafkaOptionsPrecedence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "client.properties")
+	if err := os.WriteFile(path, []byte("linger.ms=50\nacks=0\n"), 0o600); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+
+	b := &backend{}
+	fs := flag.NewFlagSet("kafka", flag.ContinueOnError)
+	b.RegisterFlags(fs)
+	err := fs.Parse([]string{
+		"-kafka-acks", "all",
+		"-kafka-config-file", path,
+		"-kafka-producer-config", "linger.ms=5",
+	})
+	if err != nil {
+		t.Fatalf("Failed to parse flags: %v", err)
+	}
+
+	opts, err := b.kafkaOptions()
+	if err != nil {
+		t.Fatalf("kafkaOptions failed: %v", err)
+	}
+
+	producer := opts.producerConfig(testBrokers)
+
+	// The file overrides the typed flag, and the passthrough list the file
+	if producer["acks"] != "0" {
+		t.Errorf("Expected acks '0' from the config file, got %v", producer["acks"])
+	}
+
+	if producer["linger.ms"] != "5" {
+		t.Errorf("Expected linger.ms '5' from -kafka-producer-config, got %v", producer["linger.ms"])
+	}
+}
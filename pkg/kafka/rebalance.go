@@ -0,0 +1,89 @@
+package kafka
+
+import (
+	"sync"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/praneethys/kafka-bullmq-benchmark/pkg/common"
+)
+
+// rebalanceLog records the partition assignments and revocations of a
+// consumer
+type rebalanceLog struct {
+	mu     sync.Mutex
+	events []common.RebalanceEvent
+	// pausedSince is when the consumer last had nothing assigned: when it
+	// subscribed or when its partitions were revoked. Zero while it holds
+	// partitions.
+	pausedSince time.Time
+}
+
+func (r *rebalanceLog) subscribed() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.pausedSince = time.Now()
+}
+
+func (r *rebalanceLog) assigned(partitions []kafka.TopicPartition) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	event := common.RebalanceEvent{Time: now, Kind: "assign", Partitions: partitionIDs(partitions)}
+	if !r.pausedSince.IsZero() {
+		event.Paused = now.Sub(r.pausedSince)
+		r.pausedSince = time.Time{}
+	}
+	r.events = append(r.events, event)
+}
+
+func (r *rebalanceLog) revoked(partitions []kafka.TopicPartition, lost bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	r.events = append(r.events, common.RebalanceEvent{
+		Time:       now,
+		Kind:       "revoke",
+		Partitions: partitionIDs(partitions),
+		Lost:       lost,
+	})
+	if r.pausedSince.IsZero() {
+		r.pausedSince = now
+	}
+}
+
+func partitionIDs(partitions []kafka.TopicPartition) []int32 {
+	ids := make([]int32, len(partitions))
+	for i, tp := range partitions {
+		ids[i] = tp.Partition
+	}
+	return ids
+}
+
+// onRebalance is the rebalance callback of the consumer. It runs inside
+// Poll; librdkafka applies the new assignment once it returns.
+func (k *KafkaQueue) onRebalance(c *kafka.Consumer, e kafka.Event) error {
+	switch e := e.(type) {
+	case kafka.AssignedPartitions:
+		k.rebalances.assigned(e.Partitions)
+	case kafka.RevokedPartitions:
+		lost := c.AssignmentLost()
+		if !lost {
+			// Commit what was handled before another member takes the
+			// partitions over and reads it again
+			k.commits.revoked()
+		}
+		k.rebalances.revoked(e.Partitions, lost)
+	}
+	return nil
+}
+
+// RebalanceEvents returns the partition assignments and revocations of the
+// consumer so far
+func (k *KafkaQueue) RebalanceEvents() []common.RebalanceEvent {
+	k.rebalances.mu.Lock()
+	defer k.rebalances.mu.Unlock()
+	return append([]common.RebalanceEvent(nil), k.rebalances.events...)
+}
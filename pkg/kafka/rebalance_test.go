@@ -0,0 +1,271 @@
+package kafka
+
+import (
+	"context"
+	"flag"
+	"testing"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/praneethys/kafka-bullmq-benchmark/pkg/common"
+	"github.com/praneethys/kafka-bullmq-benchmark/pkg/kafka/kafkatest"
+)
+
+func TestRebalanceLog(t *testing.T) {
+	topic := testTopic
+	partitions := func(ids ...int32) []kafka.TopicPartition {
+		tps := make([]kafka.TopicPartition, len(ids))
+		for i, id := range ids {
+			tps[i] = kafka.TopicPartition{Topic: &topic, Partition: id}
+		}
+		return tps
+	}
+
+	var log rebalanceLog
+	log.subscribed()
+	time.Sleep(10 * time.Millisecond)
+	log.assigned(partitions(0, 1))
+	log.revoked(partitions(0, 1), false)
+	time.Sleep(20 * time.Millisecond)
+	log.assigned(partitions(1))
+	log.revoked(partitions(1), true)
+
+	events := log.events
+	if len(events) != 4 {
+		t.Fatalf("Expected 4 events, got %d", len(events))
+	}
+
+	if events[0].Kind != "assign" || len(events[0].Partitions) != 2 || events[0].Paused < 10*time.Millisecond {
+		t.Errorf("Expected the first assignment of 2 partitions after at least 10ms, got %+v", events[0])
+	}
+
+	if events[1].Kind != "revoke" || events[1].Paused != 0 || events[1].Lost {
+		t.Errorf("Expected a plain revocation, got %+v", events[1])
+	}
+
+	if events[2].Paused < 20*time.Millisecond {
+		t.Errorf("Expected the reassignment after at least 20ms, got %v", events[2].Paused)
+	}
+
+	if !events[3].Lost {
+		t.Error("Expected the last revocation to be lost")
+	}
+}
+
+func TestGroupInstanceIDConfig(t *testing.T) {
+	opts := DefaultKafkaOptions()
+	if _, ok := opts.consumerConfig(testBrokers, testGroup)["group.instance.id"]; ok {
+		t.Error("Expected no group.instance.id by default")
+	}
+
+	opts.GroupInstanceID = "consumer-1"
+	if id := opts.consumerConfig(testBrokers, testGroup)["group.instance.id"]; id != "consumer-1" {
+		t.Errorf("Expected group.instance.id 'consumer-1', got %v", id)
+	}
+}
+
+// rebalanceOptions shortens the session timeout, which bounds how long the
+// group waits for members to rejoin during a rebalance
+func rebalanceOptions() KafkaOptions {
+	opts := DefaultKafkaOptions()
+	opts.Consumer = map[string]string{
+		"session.timeout.ms":    "6000",
+		"heartbeat.interval.ms": "500",
+	}
+	return opts
+}
+
+// startConsumer creates a consumer and consumes in the background until the
+// end of the test
+func startConsumer(t *testing.T, brokers, topic, group string, opts KafkaOptions) *KafkaQueue {
+	t.Helper()
+
+	queue, err := NewKafkaConsumer(brokers, topic, group, opts)
+	if err != nil {
+		t.Fatalf("Failed to create consumer: %v", err)
+	}
+	t.Cleanup(func() { queue.Close() })
+
+	// Registered after Close so it runs first: the queue must not be
+	// closed while Consume polls it
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = queue.Consume(ctx, func(*common.Message) error { return nil }) //nolint:errcheck // Returns nil on cancel
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	return queue
+}
+
+// produceOne produces a message to topic, creating it if needed
+func produceOne(t *testing.T, brokers, topic string) {
+	t.Helper()
+
+	producer, err := NewKafkaProducer(brokers, topic, DefaultKafkaOptions())
+	if err != nil {
+		t.Fatalf("Failed to create producer queue: %v", err)
+	}
+	defer producer.Close()
+
+	msg := &common.Message{ID: "rebalance", Payload: []byte("payload"), Timestamp: time.Now()}
+	if err := producer.Produce(context.Background(), msg); err != nil {
+		t.Fatalf("Failed to produce message: %v", err)
+	}
+}
+
+// waitForAssignment waits until queue has been assigned partitions count
+// times and returns its events
+func waitForAssignment(t *testing.T, queue *KafkaQueue, count int) []common.RebalanceEvent {
+	t.Helper()
+
+	deadline := time.Now().Add(30 * time.Second)
+	for time.Now().Before(deadline) {
+		events := queue.RebalanceEvents()
+		assigned := 0
+		for _, e := range events {
+			if e.Kind == "assign" {
+				assigned++
+			}
+		}
+		if assigned >= count {
+			return events
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Fatalf("Timeout waiting for assignment %d", count)
+	return nil
+}
+
+func TestKafkaRebalanceEvents(t *testing.T) {
+	brokers := kafkatest.Brokers(t)
+
+	topicName := testTopic + "-rebalance"
+	group := testGroup + "-rebalance"
+
+	// Creating the topic first assigns the first member all of its
+	// partitions
+	produceOne(t, brokers, topicName)
+
+	first := startConsumer(t, brokers, topicName, group, rebalanceOptions())
+	initial := waitForAssignment(t, first, 1)
+
+	// A second member joining takes some of the first one's partitions
+	second := startConsumer(t, brokers, topicName, group, rebalanceOptions())
+	waitForAssignment(t, second, 1)
+	events := waitForAssignment(t, first, 2)
+
+	if initial[0].Paused <= 0 {
+		t.Errorf("Expected the first assignment to report the join pause, got %v", initial[0].Paused)
+	}
+
+	revoked := false
+	for _, e := range events {
+		revoked = revoked || e.Kind == "revoke"
+	}
+	if !revoked {
+		t.Errorf("Expected the first consumer to have partitions revoked, got %+v", events)
+	}
+
+	last := events[len(events)-1]
+	if last.Kind != "assign" || last.Paused <= 0 || len(last.Partitions) >= len(initial[0].Partitions) {
+		t.Errorf("Expected the first consumer to end up with fewer than %d partitions, got %+v",
+			len(initial[0].Partitions), last)
+	}
+}
+
+func TestKafkaStaticMembership(t *testing.T) {
+	brokers := kafkatest.Brokers(t)
+
+	opts := rebalanceOptions()
+	opts.GroupInstanceID = "static-consumer-1"
+
+	topicName := testTopic + "-static"
+	produceOne(t, brokers, topicName)
+
+	queue := startConsumer(t, brokers, topicName, testGroup+"-static", opts)
+	if id := queue.ClientConfig(common.RoleConsumer)["group.instance.id"]; id != "static-consumer-1" {
+		t.Errorf("Expected group.instance.id 'static-consumer-1', got %q", id)
+	}
+
+	waitForAssignment(t, queue, 1)
+}
+
+func TestBackendsInARowStaticMembership(t *testing.T) {
+	brokers := kafkatest.Brokers(t)
+
+	topicName := testTopic + "-static-backends"
+	produceOne(t, brokers, topicName)
+
+	// A session long enough that waiting for the previous run's static
+	// member would show
+	b := &backend{}
+	fs := flag.NewFlagSet("kafka", flag.ContinueOnError)
+	b.RegisterFlags(fs)
+	err := fs.Parse([]string{
+		"-kafka-brokers", brokers,
+		"-kafka-topic", topicName,
+		"-kafka-group-instance-id", "consumer-1",
+		"-kafka-consumer-config", "session.timeout.ms=30000,heartbeat.interval.ms=500",
+	})
+	if err != nil {
+		t.Fatalf("Failed to parse flags: %v", err)
+	}
+
+	for _, backend := range []common.Backend{b, &txnBackend{backend: b}} {
+		queue, err := backend.NewQueue(common.BackendOptions{Role: common.RoleConsumer})
+		if err != nil {
+			t.Fatalf("Failed to create %s queue: %v", backend.Name(), err)
+		}
+		kafkaQueue := queue.(*KafkaQueue)
+		config := kafkaQueue.ClientConfig(common.RoleConsumer)
+		if config["group.instance.id"] != "consumer-1" || config["group.id"] != "benchmark-consumer-group-"+backend.Name() {
+			t.Errorf("%s: expected static member consumer-1 of its own group, got %v", backend.Name(), config)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			_ = queue.Consume(ctx, func(*common.Message) error { return nil }) //nolint:errcheck // Returns nil on cancel
+		}()
+
+		start := time.Now()
+		var assigned int
+		for _, e := range waitForAssignment(t, kafkaQueue, 1) {
+			if e.Kind == "assign" {
+				assigned += len(e.Partitions)
+			}
+		}
+		elapsed := time.Since(start)
+		partitions, err := kafkaQueue.Partitions()
+
+		cancel()
+		<-done
+		queue.Close()
+
+		if err != nil {
+			t.Fatalf("Failed to list partitions: %v", err)
+		}
+		if assigned != len(partitions) {
+			t.Errorf("%s: expected all %d partitions assigned, got %d", backend.Name(), len(partitions), assigned)
+		}
+		if elapsed > 10*time.Second {
+			t.Errorf("%s: expected the assignment well within the session timeout, took %v", backend.Name(), elapsed)
+		}
+	}
+
+	// Replay consumers join a fresh group as dynamic members
+	queue, err := b.NewQueue(common.BackendOptions{Role: common.RoleConsumer, Replay: true})
+	if err != nil {
+		t.Fatalf("Failed to create replay queue: %v", err)
+	}
+	defer queue.Close()
+	if id := queue.(*KafkaQueue).ClientConfig(common.RoleConsumer)["group.instance.id"]; id != "" {
+		t.Errorf("Expected no group.instance.id for replay consumers, got %q", id)
+	}
+}
@@ -0,0 +1,91 @@
+package kafka
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+// SecurityProtocols and SASLMechanisms list the accepted values of
+// KafkaSecurity.Protocol and KafkaSecurity.SASLMechanism
+var (
+	SecurityProtocols = []string{"plaintext", "ssl", "sasl_plaintext", "sasl_ssl"}
+	SASLMechanisms    = []string{"PLAIN", "SCRAM-SHA-256", "SCRAM-SHA-512"}
+)
+
+// KafkaSecurity holds the connection security settings shared by every
+// client of a run: the producer, the consumer and the admin client. The zero
+// value connects in plaintext without authentication.
+type KafkaSecurity struct {
+	Protocol      string // see SecurityProtocols; empty keeps the librdkafka default
+	SASLMechanism string // see SASLMechanisms; empty means PLAIN
+	SASLUsername  string
+	SASLPassword  string
+	// PEM files for TLS. CALocation verifies the brokers; CertLocation and
+	// KeyLocation authenticate the client with mutual TLS.
+	CALocation   string
+	CertLocation string
+	KeyLocation  string
+	KeyPassword  string
+}
+
+// sasl reports whether the protocol authenticates with SASL
+func (s KafkaSecurity) sasl() bool {
+	return strings.HasPrefix(strings.ToLower(s.Protocol), "sasl_")
+}
+
+// validate checks the settings before any client is created, since
+// librdkafka reports most mistakes only as connection failures
+func (s KafkaSecurity) validate() error {
+	if s.Protocol != "" && !slices.Contains(SecurityProtocols, strings.ToLower(s.Protocol)) {
+		return fmt.Errorf("unknown security protocol %q (available: %s)", s.Protocol, strings.Join(SecurityProtocols, ", "))
+	}
+	if s.SASLMechanism != "" && !slices.Contains(SASLMechanisms, strings.ToUpper(s.SASLMechanism)) {
+		return fmt.Errorf("unknown SASL mechanism %q (available: %s)", s.SASLMechanism, strings.Join(SASLMechanisms, ", "))
+	}
+	if s.sasl() && s.SASLUsername == "" {
+		return fmt.Errorf("security protocol %s needs a SASL username", s.Protocol)
+	}
+	if !s.sasl() && (s.SASLMechanism != "" || s.SASLUsername != "") {
+		return fmt.Errorf("SASL settings need a sasl_plaintext or sasl_ssl security protocol")
+	}
+	if (s.CertLocation == "") != (s.KeyLocation == "") {
+		return fmt.Errorf("a client certificate and key must be given together")
+	}
+	return nil
+}
+
+// apply adds the settings to a librdkafka configuration
+func (s KafkaSecurity) apply(config kafka.ConfigMap) {
+	if s.Protocol != "" {
+		config["security.protocol"] = strings.ToLower(s.Protocol)
+	}
+	if s.sasl() {
+		mechanism := strings.ToUpper(s.SASLMechanism)
+		if mechanism == "" {
+			mechanism = "PLAIN"
+		}
+		config["sasl.mechanism"] = mechanism
+		config["sasl.username"] = s.SASLUsername
+		config["sasl.password"] = s.SASLPassword
+	}
+	if s.CALocation != "" {
+		config["ssl.ca.location"] = s.CALocation
+	}
+	if s.CertLocation != "" {
+		config["ssl.certificate.location"] = s.CertLocation
+		config["ssl.key.location"] = s.KeyLocation
+	}
+	if s.KeyPassword != "" {
+		config["ssl.key.password"] = s.KeyPassword
+	}
+}
+
+// adminConfig returns the librdkafka configuration of an admin client
+func (s KafkaSecurity) adminConfig(brokers string) kafka.ConfigMap {
+	config := kafka.ConfigMap{"bootstrap.servers": brokers}
+	s.apply(config)
+	return config
+}
@@ -0,0 +1,110 @@
+package kafka
+
+import (
+	"flag"
+	"testing"
+)
+
+func TestKafkaSecurityConfig(t *testing.T) {
+	opts := DefaultKafkaOptions()
+	opts.Security = KafkaSecurity{
+		Protocol:      "SASL_SSL",
+		SASLMechanism: "scram-sha-512",
+		SASLUsername:  "bench",
+		SASLPassword:  "s3cret",
+		CALocation:    "/etc/kafka/ca.pem",
+	}
+
+	for name, config := range map[string]map[string]string{
+		"producer": reportedConfig(opts.producerConfig(testBrokers)),
+		"consumer": reportedConfig(opts.consumerConfig(testBrokers, testGroup)),
+		"admin":    reportedConfig(opts.Security.adminConfig(testBrokers)),
+	} {
+		if config["security.protocol"] != "sasl_ssl" || config["sasl.mechanism"] != "SCRAM-SHA-512" {
+			t.Errorf("Expected %s to use sasl_ssl with SCRAM-SHA-512, got %v", name, config)
+		}
+		if config["sasl.username"] != "bench" || config["ssl.ca.location"] != "/etc/kafka/ca.pem" {
+			t.Errorf("Expected %s username and CA location, got %v", name, config)
+		}
+		if config["sasl.password"] != "[redacted]" {
+			t.Errorf("Expected the %s password to be redacted, got %q", name, config["sasl.password"])
+		}
+	}
+}
+
+func TestKafkaSecurityDefault(t *testing.T) {
+	config := DefaultKafkaOptions().producerConfig(testBrokers)
+	for _, key := range []string{"security.protocol", "sasl.mechanism", "ssl.ca.location"} {
+		if _, ok := config[key]; ok {
+			t.Errorf("Expected no %s by default", key)
+		}
+	}
+}
+
+func TestKafkaSecurityValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		security KafkaSecurity
+		wantErr  bool
+	}{
+		{name: "plaintext", security: KafkaSecurity{}},
+		{name: "tls", security: KafkaSecurity{Protocol: "ssl", CALocation: "ca.pem"}},
+		{name: "mutual tls", security: KafkaSecurity{Protocol: "ssl", CertLocation: "c.pem", KeyLocation: "k.pem"}},
+		{name: "sasl plain", security: KafkaSecurity{Protocol: "sasl_plaintext", SASLUsername: "u"}},
+		{name: "unknown protocol", security: KafkaSecurity{Protocol: "tls"}, wantErr: true},
+		{name: "unknown mechanism", security: KafkaSecurity{Protocol: "sasl_ssl", SASLMechanism: "GSSAPI", SASLUsername: "u"}, wantErr: true},
+		{name: "sasl without username", security: KafkaSecurity{Protocol: "sasl_ssl"}, wantErr: true},
+		{name: "username without sasl", security: KafkaSecurity{Protocol: "ssl", SASLUsername: "u"}, wantErr: true},
+		{name: "cert without key", security: KafkaSecurity{Protocol: "ssl", CertLocation: "c.pem"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.security.validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewKafkaProducerInvalidSecurity(t *testing.T) {
+	opts := DefaultKafkaOptions()
+	opts.Security.Protocol = "sasl_ssl"
+
+	if _, err := NewKafkaProducer(testBrokers, testTopic, opts); err == nil {
+		t.Error("Expected an error for SASL without a username, got nil")
+	}
+}
+
+func TestBackendSASLPasswordFromEnv(t *testing.T) {
+	t.Setenv(saslPasswordEnv, "from-env")
+
+	b := &backend{}
+	fs := flag.NewFlagSet("kafka", flag.ContinueOnError)
+	b.RegisterFlags(fs)
+	err := fs.Parse([]string{
+		"-kafka-security-protocol", "sasl_plaintext",
+		"-kafka-sasl-username", "bench",
+	})
+	if err != nil {
+		t.Fatalf("Failed to parse flags: %v", err)
+	}
+
+	opts, err := b.kafkaOptions()
+	if err != nil {
+		t.Fatalf("kafkaOptions failed: %v", err)
+	}
+
+	if opts.Security.SASLPassword != "from-env" {
+		t.Errorf("Expected the password from $%s, got %q", saslPasswordEnv, opts.Security.SASLPassword)
+	}
+
+	// The flag wins over the environment
+	if err := fs.Set("kafka-sasl-password", "from-flag"); err != nil {
+		t.Fatalf("Failed to set flag: %v", err)
+	}
+	if b.security().SASLPassword != "from-flag" {
+		t.Errorf("Expected the password from the flag, got %q", b.security().SASLPassword)
+	}
+}
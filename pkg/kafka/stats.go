@@ -0,0 +1,109 @@
+package kafka
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/praneethys/kafka-bullmq-benchmark/pkg/common"
+)
+
+// rdkafkaStats holds the parts of librdkafka's statistics JSON the benchmark
+// records. See STATISTICS.md in librdkafka for the full format.
+type rdkafkaStats struct {
+	Name      string `json:"name"`    // client instance, e.g. "rdkafka#producer-1"
+	MsgCnt    int64  `json:"msg_cnt"` // messages in the producer queues
+	Tx        int64  `json:"tx"`
+	TxRetries int64  `json:"txretries"`
+	Brokers   map[string]struct {
+		NodeID int32         `json:"nodeid"` // -1 for bootstrap and internal brokers
+		RTT    rdkafkaWindow `json:"rtt"`    // microseconds
+	} `json:"brokers"`
+	Topics map[string]struct {
+		BatchCnt   rdkafkaWindow `json:"batchcnt"` // messages per produce batch
+		Partitions map[string]struct {
+			FetchqCnt int64 `json:"fetchq_cnt"` // messages fetched but not yet consumed
+		} `json:"partitions"`
+	} `json:"topics"`
+}
+
+// rdkafkaWindow is a librdkafka rolling-window histogram, reset every
+// statistics interval
+type rdkafkaWindow struct {
+	Avg int64 `json:"avg"`
+	P99 int64 `json:"p99"`
+	Cnt int64 `json:"cnt"`
+}
+
+// parseStats converts a librdkafka statistics report into a sample. Broker
+// round trip times and batch sizes are averaged over the brokers and topics
+// that saw any traffic in the interval.
+func parseStats(data string, role common.Role, received time.Time) (common.ClientStatsSample, error) {
+	var stats rdkafkaStats
+	if err := json.Unmarshal([]byte(data), &stats); err != nil {
+		return common.ClientStatsSample{}, fmt.Errorf("failed to parse statistics: %w", err)
+	}
+
+	sample := common.ClientStatsSample{
+		Time:       received,
+		Role:       role,
+		Client:     stats.Name,
+		QueueDepth: stats.MsgCnt,
+		Requests:   stats.Tx,
+		Retries:    stats.TxRetries,
+	}
+
+	var rttSum, rttCount int64
+	for _, broker := range stats.Brokers {
+		if broker.NodeID < 0 || broker.RTT.Cnt == 0 {
+			continue
+		}
+		rttSum += broker.RTT.Avg * broker.RTT.Cnt
+		rttCount += broker.RTT.Cnt
+		sample.BrokerRTTP99 = max(sample.BrokerRTTP99, time.Duration(broker.RTT.P99)*time.Microsecond)
+	}
+	if rttCount > 0 {
+		sample.BrokerRTT = time.Duration(rttSum/rttCount) * time.Microsecond
+	}
+
+	var batchSum, batchCount int64
+	for _, topic := range stats.Topics {
+		batchSum += topic.BatchCnt.Avg * topic.BatchCnt.Cnt
+		batchCount += topic.BatchCnt.Cnt
+		for _, partition := range topic.Partitions {
+			sample.QueueDepth += partition.FetchqCnt
+		}
+	}
+	if batchCount > 0 {
+		sample.BatchSize = float64(batchSum) / float64(batchCount)
+	}
+
+	return sample, nil
+}
+
+// statsLog records the statistics reports of a queue's clients
+type statsLog struct {
+	mu      sync.Mutex
+	samples []common.ClientStatsSample
+}
+
+// record parses and stores a statistics report; malformed ones are dropped
+func (s *statsLog) record(data string, role common.Role) {
+	sample, err := parseStats(data, role, time.Now())
+	if err != nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.samples = append(s.samples, sample)
+}
+
+// ClientStats returns the librdkafka statistics reported by the queue's
+// clients so far, oldest first
+func (k *KafkaQueue) ClientStats() []common.ClientStatsSample {
+	k.stats.mu.Lock()
+	defer k.stats.mu.Unlock()
+	return append([]common.ClientStatsSample(nil), k.stats.samples...)
+}
@@ -0,0 +1,141 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/praneethys/kafka-bullmq-benchmark/pkg/common"
+	"github.com/praneethys/kafka-bullmq-benchmark/pkg/kafka/kafkatest"
+)
+
+// testStats is a trimmed librdkafka producer statistics report with a
+// bootstrap broker, which must be ignored, and two real ones
+const testStats = `{
+	"name": "rdkafka#producer-1",
+	"type": "producer",
+	"msg_cnt": 42,
+	"tx": 120,
+	"txretries": 3,
+	"brokers": {
+		"localhost:9092/bootstrap": {"nodeid": -1, "rtt": {"avg": 99000, "p99": 99000, "cnt": 5}},
+		"broker-1:9092/1": {"nodeid": 1, "rtt": {"avg": 1000, "p99": 4000, "cnt": 30}},
+		"broker-2:9092/2": {"nodeid": 2, "rtt": {"avg": 3000, "p99": 9000, "cnt": 10}}
+	},
+	"topics": {
+		"test-topic": {
+			"batchcnt": {"avg": 50, "p99": 80, "cnt": 4},
+			"partitions": {
+				"0": {"fetchq_cnt": 0},
+				"-1": {"fetchq_cnt": 0}
+			}
+		}
+	}
+}`
+
+func TestParseStats(t *testing.T) {
+	received := time.Now()
+	sample, err := parseStats(testStats, common.RoleProducer, received)
+	if err != nil {
+		t.Fatalf("parseStats failed: %v", err)
+	}
+
+	if !sample.Time.Equal(received) || sample.Role != common.RoleProducer || sample.Client != "rdkafka#producer-1" {
+		t.Errorf("Expected the receive time, producer role and client name, got %+v", sample)
+	}
+
+	// (1000*30 + 3000*10) / 40 microseconds
+	if sample.BrokerRTT != 1500*time.Microsecond {
+		t.Errorf("Expected 1.5ms broker RTT, got %v", sample.BrokerRTT)
+	}
+
+	if sample.BrokerRTTP99 != 9*time.Millisecond {
+		t.Errorf("Expected 9ms p99 broker RTT, got %v", sample.BrokerRTTP99)
+	}
+
+	if sample.QueueDepth != 42 || sample.BatchSize != 50 || sample.Requests != 120 || sample.Retries != 3 {
+		t.Errorf("Expected depth 42, batch 50, 120 requests and 3 retries, got %+v", sample)
+	}
+}
+
+func TestParseStatsConsumerQueue(t *testing.T) {
+	stats := `{"type": "consumer", "msg_cnt": 0, "topics": {"t": {"partitions": {"0": {"fetchq_cnt": 7}, "1": {"fetchq_cnt": 5}}}}}`
+
+	sample, err := parseStats(stats, common.RoleConsumer, time.Now())
+	if err != nil {
+		t.Fatalf("parseStats failed: %v", err)
+	}
+
+	if sample.QueueDepth != 12 {
+		t.Errorf("Expected 12 fetched messages waiting, got %d", sample.QueueDepth)
+	}
+
+	if sample.BrokerRTT != 0 || sample.BatchSize != 0 {
+		t.Errorf("Expected no RTT or batch size without traffic, got %+v", sample)
+	}
+}
+
+func TestParseStatsInvalid(t *testing.T) {
+	if _, err := parseStats("not json", common.RoleProducer, time.Now()); err == nil {
+		t.Error("Expected an error for malformed statistics")
+	}
+}
+
+func TestStatsIntervalConfig(t *testing.T) {
+	opts := DefaultKafkaOptions()
+	opts.StatsInterval = 250 * time.Millisecond
+	if v := opts.producerConfig(testBrokers)["statistics.interval.ms"]; v != 250 {
+		t.Errorf("Expected producer statistics.interval.ms 250, got %v", v)
+	}
+	if v := opts.consumerConfig(testBrokers, testGroup)["statistics.interval.ms"]; v != 250 {
+		t.Errorf("Expected consumer statistics.interval.ms 250, got %v", v)
+	}
+
+	opts.StatsInterval = 0
+	if _, ok := opts.producerConfig(testBrokers)["statistics.interval.ms"]; ok {
+		t.Error("Expected no statistics.interval.ms when disabled")
+	}
+}
+
+func TestKafkaClientStats(t *testing.T) {
+	brokers := kafkatest.Brokers(t)
+
+	opts := DefaultKafkaOptions()
+	opts.StatsInterval = 100 * time.Millisecond
+	queue, err := NewKafkaQueueWithOptions(brokers, testTopic+"-stats", testGroup+"-stats", opts)
+	if err != nil {
+		t.Fatalf("Failed to create Kafka queue: %v", err)
+	}
+	defer queue.Close()
+
+	for i := 0; i < 10; i++ {
+		msg := &common.Message{ID: fmt.Sprintf("stats-%d", i), Payload: []byte("stats"), Timestamp: time.Now()}
+		if err := queue.Produce(context.Background(), msg); err != nil {
+			t.Fatalf("Failed to produce message: %v", err)
+		}
+	}
+
+	// Consumer statistics arrive through Poll
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := queue.Consume(ctx, func(*common.Message) error { return nil }); err != nil {
+		t.Fatalf("Consume failed: %v", err)
+	}
+
+	var producer, consumer int
+	for _, sample := range queue.ClientStats() {
+		if sample.Role == common.RoleProducer {
+			producer++
+			if sample.Requests == 0 {
+				t.Errorf("Expected producer requests to be counted, got %+v", sample)
+			}
+		} else {
+			consumer++
+		}
+	}
+
+	if producer == 0 || consumer == 0 {
+		t.Errorf("Expected samples from both clients, got %d producer and %d consumer", producer, consumer)
+	}
+}
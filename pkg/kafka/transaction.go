@@ -0,0 +1,253 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/praneethys/kafka-bullmq-benchmark/pkg/common"
+)
+
+const (
+	// transactionLinger caps how long a transaction stays open when the
+	// producers send fewer messages than its size, e.g. at the end of a run
+	transactionLinger = 100 * time.Millisecond
+	// transactionTimeout bounds InitTransactions, CommitTransaction and
+	// AbortTransaction
+	transactionTimeout = 30 * time.Second
+)
+
+// transaction is the producer transaction messages are currently added to.
+// done is closed once it has been committed or aborted, with err set in the
+// latter case.
+type transaction struct {
+	messages int
+	started  time.Time
+	done     chan struct{}
+	err      error
+
+	// ProduceAsync messages delivered while the transaction is open, whose
+	// reports wait for its outcome
+	mu       sync.Mutex
+	finished bool
+	held     []*asyncDelivery
+}
+
+// hold keeps the report of a delivered message until the transaction
+// finishes. It returns false if the transaction has finished already.
+func (t *transaction) hold(d *asyncDelivery) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.finished {
+		return false
+	}
+	t.held = append(t.held, d)
+	return true
+}
+
+// finish marks txn committed, or aborted if txn.err is set, and reports the
+// messages held for it with that outcome
+func (k *KafkaQueue) finish(txn *transaction) {
+	txn.mu.Lock()
+	txn.finished = true
+	held := txn.held
+	txn.held = nil
+	txn.mu.Unlock()
+
+	close(txn.done)
+	for _, d := range held {
+		k.report(d, txn.err)
+	}
+}
+
+// initTransactions prepares the producer for transactions and starts the
+// goroutine that commits lingering ones
+func (k *KafkaQueue) initTransactions(size int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), transactionTimeout)
+	defer cancel()
+
+	if err := k.producer.InitTransactions(ctx); err != nil {
+		return fmt.Errorf("failed to init transactions: %w", err)
+	}
+
+	k.txnSize = size
+	k.txnStop = make(chan struct{})
+	k.txnWg.Add(1)
+	go k.commitLingering()
+	return nil
+}
+
+// transactional reports whether produced messages go through transactions
+func (k *KafkaQueue) transactional() bool {
+	return k.txnSize > 0
+}
+
+// enqueue hands kafkaMsg to librdkafka. On a transactional queue the message
+// joins the open transaction, which is committed once it holds txnSize
+// messages; the transaction is returned so the caller can wait for it, and
+// ProduceAsync reports are held until it finishes.
+func (k *KafkaQueue) enqueue(kafkaMsg *kafka.Message, deliveryChan chan kafka.Event) (*transaction, error) {
+	if !k.transactional() {
+		return nil, k.producer.Produce(kafkaMsg, deliveryChan)
+	}
+
+	k.txnMu.Lock()
+	defer k.txnMu.Unlock()
+
+	if k.txn == nil {
+		if err := k.producer.BeginTransaction(); err != nil {
+			return nil, fmt.Errorf("failed to begin transaction: %w", err)
+		}
+		k.txn = &transaction{started: time.Now(), done: make(chan struct{})}
+	}
+
+	// Set before the report can arrive, so it waits for the transaction
+	if d, ok := kafkaMsg.Opaque.(*asyncDelivery); ok {
+		d.txn = k.txn
+	}
+
+	if err := k.producer.Produce(kafkaMsg, deliveryChan); err != nil {
+		return nil, err
+	}
+
+	txn := k.txn
+	txn.messages++
+	if txn.messages >= k.txnSize {
+		k.commitLocked()
+	}
+	return txn, nil
+}
+
+// waitTransaction waits until txn has been committed and returns its error.
+// A nil txn, as returned by enqueue on a non-transactional queue, is done.
+func waitTransaction(ctx context.Context, txn *transaction) error {
+	if txn == nil {
+		return nil
+	}
+
+	select {
+	case <-txn.done:
+		return txn.err
+	case <-ctx.Done():
+		return fmt.Errorf("transaction wait aborted: %w", ctx.Err())
+	}
+}
+
+// commitLocked commits the open transaction, aborting it if the commit
+// fails. It must be called with k.txnMu held.
+func (k *KafkaQueue) commitLocked() {
+	txn := k.txn
+	if txn == nil {
+		return
+	}
+	k.txn = nil
+	defer k.finish(txn)
+
+	ctx, cancel := context.WithTimeout(context.Background(), transactionTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := k.producer.CommitTransaction(ctx)
+	for err != nil && isRetriable(err) && ctx.Err() == nil {
+		err = k.producer.CommitTransaction(ctx)
+	}
+
+	if err == nil {
+		k.txnCommitTimes = append(k.txnCommitTimes, time.Since(start))
+		k.txnCommitted++
+		return
+	}
+
+	if kafkaErr, ok := err.(kafka.Error); ok && kafkaErr.TxnRequiresAbort() {
+		_ = k.producer.AbortTransaction(ctx) //nolint:errcheck // The commit error is what gets reported
+	}
+	txn.err = fmt.Errorf("failed to commit transaction: %w", err)
+	k.txnAborted++
+}
+
+// abortLocked aborts the open transaction. It must be called with k.txnMu
+// held.
+func (k *KafkaQueue) abortLocked(cause error) {
+	txn := k.txn
+	if txn == nil {
+		return
+	}
+	k.txn = nil
+
+	ctx, cancel := context.WithTimeout(context.Background(), transactionTimeout)
+	defer cancel()
+	_ = k.producer.AbortTransaction(ctx) //nolint:errcheck // The transaction is lost either way
+
+	txn.err = cause
+	k.txnAborted++
+	k.finish(txn)
+}
+
+// commitLingering commits transactions that have been open for longer than
+// transactionLinger, so a partly filled one does not wait forever
+func (k *KafkaQueue) commitLingering() {
+	defer k.txnWg.Done()
+
+	ticker := time.NewTicker(transactionLinger / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-k.txnStop:
+			return
+		case <-ticker.C:
+			k.txnMu.Lock()
+			if k.txn != nil && time.Since(k.txn.started) >= transactionLinger {
+				k.commitLocked()
+			}
+			k.txnMu.Unlock()
+		}
+	}
+}
+
+// commitOpen commits the open transaction, if any
+func (k *KafkaQueue) commitOpen() {
+	if !k.transactional() {
+		return
+	}
+
+	k.txnMu.Lock()
+	defer k.txnMu.Unlock()
+	k.commitLocked()
+}
+
+// closeTransactions stops the linger goroutine and aborts a transaction that
+// is still open
+func (k *KafkaQueue) closeTransactions() {
+	if !k.transactional() {
+		return
+	}
+
+	close(k.txnStop)
+	k.txnWg.Wait()
+
+	k.txnMu.Lock()
+	defer k.txnMu.Unlock()
+	k.abortLocked(fmt.Errorf("queue closed with the transaction open"))
+}
+
+// TransactionStats reports the transactions committed and aborted so far
+func (k *KafkaQueue) TransactionStats() common.TransactionStats {
+	k.txnMu.Lock()
+	defer k.txnMu.Unlock()
+
+	return common.TransactionStats{
+		Committed:   k.txnCommitted,
+		Aborted:     k.txnAborted,
+		CommitTimes: append([]time.Duration(nil), k.txnCommitTimes...),
+	}
+}
+
+// isRetriable reports whether err is a Kafka error that may succeed on retry
+func isRetriable(err error) bool {
+	kafkaErr, ok := err.(kafka.Error)
+	return ok && kafkaErr.IsRetriable()
+}
@@ -0,0 +1,214 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/praneethys/kafka-bullmq-benchmark/pkg/common"
+	"github.com/praneethys/kafka-bullmq-benchmark/pkg/kafka/kafkatest"
+)
+
+func TestTransactionalProducerConfig(t *testing.T) {
+	opts := DefaultKafkaOptions()
+	opts.TransactionalID = "bench-txn"
+
+	producer := opts.producerConfig(testBrokers)
+
+	if producer["transactional.id"] != "bench-txn" {
+		t.Errorf("Expected transactional.id 'bench-txn', got %v", producer["transactional.id"])
+	}
+
+	// Transactions need the idempotent producer, which needs acks=all
+	if producer["enable.idempotence"] != true || producer["acks"] != "all" {
+		t.Errorf("Expected enable.idempotence=true and acks=all, got %v and %v",
+			producer["enable.idempotence"], producer["acks"])
+	}
+
+	if _, ok := DefaultKafkaOptions().producerConfig(testBrokers)["transactional.id"]; ok {
+		t.Error("Expected no transactional.id by default")
+	}
+}
+
+func TestReadCommittedConsumerConfig(t *testing.T) {
+	opts := DefaultKafkaOptions()
+	if _, ok := opts.consumerConfig(testBrokers, testGroup)["isolation.level"]; ok {
+		t.Error("Expected no isolation.level by default")
+	}
+
+	opts.IsolationLevel = "read_committed"
+	if level := opts.consumerConfig(testBrokers, testGroup)["isolation.level"]; level != "read_committed" {
+		t.Errorf("Expected isolation.level 'read_committed', got %v", level)
+	}
+}
+
+func TestKafkaTransactions(t *testing.T) {
+	brokers := kafkatest.Brokers(t)
+
+	topicName := testTopic + "-txn"
+
+	opts := DefaultKafkaOptions()
+	opts.TransactionalID = "test-txn-producer"
+	opts.TransactionSize = 5
+
+	producer, err := NewKafkaQueueWithOptions(brokers, topicName, testGroup+"-txn-producer", opts)
+	if err != nil {
+		t.Fatalf("Failed to create transactional queue: %v", err)
+	}
+	defer producer.Close()
+
+	if name := producer.GetName(); name != "Apache Kafka (transactional)" {
+		t.Errorf("Expected name 'Apache Kafka (transactional)', got '%s'", name)
+	}
+
+	// Ten messages in one batch fill exactly two transactions
+	msgs := make([]*common.Message, 10)
+	for i := range msgs {
+		msgs[i] = &common.Message{ID: fmt.Sprintf("txn-%d", i), Payload: []byte("txn"), Timestamp: time.Now()}
+	}
+	if err := producer.ProduceBatch(context.Background(), msgs); err != nil {
+		t.Fatalf("Failed to produce batch: %v", err)
+	}
+
+	stats := producer.TransactionStats()
+	if stats.Committed != 2 || stats.Aborted != 0 {
+		t.Errorf("Expected 2 committed and 0 aborted transactions, got %+v", stats)
+	}
+
+	if len(stats.CommitTimes) != 2 {
+		t.Errorf("Expected 2 commit times, got %d", len(stats.CommitTimes))
+	}
+
+	consumerOpts := DefaultKafkaOptions()
+	consumerOpts.IsolationLevel = "read_committed"
+	consumer, err := NewKafkaQueueWithOptions(brokers, topicName, testGroup+"-txn-consumer", consumerOpts)
+	if err != nil {
+		t.Fatalf("Failed to create read_committed queue: %v", err)
+	}
+	defer consumer.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	want := make(map[string]bool)
+	for _, msg := range msgs {
+		want[msg.ID] = true
+	}
+	_ = consumer.Consume(ctx, func(m *common.Message) error { //nolint:errcheck // Returns nil on cancel
+		delete(want, m.ID)
+		if len(want) == 0 {
+			cancel()
+		}
+		return nil
+	})
+
+	if len(want) != 0 {
+		t.Errorf("Expected every committed message to be consumed, missing %d", len(want))
+	}
+}
+
+func TestKafkaTransactionLinger(t *testing.T) {
+	brokers := kafkatest.Brokers(t)
+
+	opts := DefaultKafkaOptions()
+	opts.TransactionalID = "test-txn-linger"
+	opts.TransactionSize = 1000
+
+	queue, err := NewKafkaQueueWithOptions(brokers, testTopic+"-txn", testGroup+"-txn-linger", opts)
+	if err != nil {
+		t.Fatalf("Failed to create transactional queue: %v", err)
+	}
+	defer queue.Close()
+
+	// A transaction far from full is still committed after the linger
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	msg := &common.Message{ID: "linger", Payload: []byte("txn"), Timestamp: time.Now()}
+	if err := queue.Produce(ctx, msg); err != nil {
+		t.Fatalf("Failed to produce message: %v", err)
+	}
+
+	if stats := queue.TransactionStats(); stats.Committed != 1 {
+		t.Errorf("Expected 1 committed transaction, got %d", stats.Committed)
+	}
+}
+
+func TestTransactionHoldsDeliveryReports(t *testing.T) {
+	queue := &KafkaQueue{}
+	var reported []error
+	queue.SetDeliveryHandler(func(_ *common.Message, _ time.Duration, err error) {
+		reported = append(reported, err)
+	})
+
+	txn := &transaction{done: make(chan struct{})}
+	queue.pendingReports.Add(2)
+	if !txn.hold(&asyncDelivery{msg: &common.Message{ID: "held"}, txn: txn}) {
+		t.Fatal("Expected an open transaction to hold the report")
+	}
+	if len(reported) != 0 {
+		t.Fatalf("Expected no report before the transaction finished, got %v", reported)
+	}
+
+	txn.err = errors.New("aborted")
+	queue.finish(txn)
+	if len(reported) != 1 || reported[0] != txn.err {
+		t.Errorf("Expected the held message reported with the abort error, got %v", reported)
+	}
+
+	if txn.hold(&asyncDelivery{msg: &common.Message{ID: "late"}, txn: txn}) {
+		t.Error("Expected a finished transaction not to hold reports")
+	}
+	if n := queue.pendingReports.Load(); n != 1 {
+		t.Errorf("Expected 1 report outstanding, got %d", n)
+	}
+}
+
+func TestKafkaTransactionalProduceAsync(t *testing.T) {
+	brokers := kafkatest.Brokers(t)
+
+	opts := DefaultKafkaOptions()
+	opts.TransactionalID = "test-txn-async"
+	opts.TransactionSize = 5
+
+	queue, err := NewKafkaProducer(brokers, testTopic+"-txn", opts)
+	if err != nil {
+		t.Fatalf("Failed to create transactional queue: %v", err)
+	}
+	defer queue.Close()
+
+	var mu sync.Mutex
+	committed := make(map[string]bool)
+	queue.SetDeliveryHandler(func(msg *common.Message, _ time.Duration, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		committed[msg.ID] = err == nil
+	})
+
+	msgs := make([]*common.Message, 10)
+	for i := range msgs {
+		msgs[i] = &common.Message{ID: fmt.Sprintf("txn-async-%d", i), Payload: []byte("txn"), Timestamp: time.Now()}
+		if err := queue.ProduceAsync(context.Background(), msgs[i]); err != nil {
+			t.Fatalf("Failed to produce async message: %v", err)
+		}
+	}
+
+	if remaining := queue.Flush(10000); remaining > 0 {
+		t.Fatalf("Expected 0 remaining messages, got %d", remaining)
+	}
+
+	if stats := queue.TransactionStats(); stats.Committed != 2 {
+		t.Errorf("Expected 2 committed transactions, got %+v", stats)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, msg := range msgs {
+		if !committed[msg.ID] {
+			t.Errorf("Expected %s reported as committed", msg.ID)
+		}
+	}
+}
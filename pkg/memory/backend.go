@@ -0,0 +1,50 @@
+package memory
+
+import (
+	"flag"
+
+	"github.com/google/uuid"
+	"github.com/praneethys/kafka-bullmq-benchmark/pkg/common"
+)
+
+func init() {
+	common.RegisterBackend(&backend{})
+}
+
+// backend exposes the in-memory queue to the benchmark CLI through the common registry
+type backend struct {
+	topic    string
+	capacity int
+}
+
+func (b *backend) Name() string {
+	return "memory"
+}
+
+func (b *backend) RegisterFlags(fs *flag.FlagSet) {
+	fs.StringVar(&b.topic, "memory-topic", "benchmark-topic", "In-memory topic name")
+	fs.IntVar(&b.capacity, "memory-capacity", 100000, "Maximum unread messages per in-memory consumer group")
+}
+
+// NewQueue creates a queue for the role in opts. Replay consumers join a
+// group of their own, which starts at the oldest retained message and is
+// left when the queue is closed.
+func (b *backend) NewQueue(opts common.BackendOptions) (common.MessageQueue, error) {
+	group := ""
+	switch {
+	case opts.Role != common.RoleConsumer:
+	case opts.Replay:
+		group = "benchmark-replay-" + uuid.New().String()
+	default:
+		group = "benchmark-group"
+	}
+
+	queue, err := NewMemoryQueue(b.topic, group, b.capacity)
+	if err != nil {
+		return nil, err
+	}
+	queue.leaveOnClose = opts.Replay
+
+	queue.SetRetryPolicy(opts.Retry)
+	return queue, nil
+}
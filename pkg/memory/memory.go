@@ -0,0 +1,267 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"sync"
+
+	"github.com/praneethys/kafka-bullmq-benchmark/pkg/common"
+)
+
+// topic is a bounded in-process log shared by every queue created with the
+// same topic name. Each consumer group keeps its own read offset, so groups
+// see every message while consumers inside a group split them, like Kafka
+// consumer groups or Redis Streams groups.
+type topic struct {
+	mu      sync.Mutex
+	buf     []*common.Message // ring buffer indexed by offset % len(buf)
+	head    int64             // offset of the next message to append
+	groups  map[string]int64  // next offset to deliver, per consumer group
+	waiters int               // goroutines blocked in wait
+	changed chan struct{}     // closed on the next state change when waiters > 0
+}
+
+var (
+	topicsMu sync.Mutex
+	topics   = make(map[string]*topic)
+)
+
+// getTopic returns the named topic, creating it with the given capacity if it
+// does not exist yet
+func getTopic(name string, capacity int) *topic {
+	topicsMu.Lock()
+	defer topicsMu.Unlock()
+
+	t, ok := topics[name]
+	if !ok {
+		t = &topic{
+			buf:     make([]*common.Message, capacity),
+			groups:  make(map[string]int64),
+			changed: make(chan struct{}),
+		}
+		topics[name] = t
+	}
+	return t
+}
+
+// oldest returns the offset of the oldest message still retained
+func (t *topic) oldest() int64 {
+	if oldest := t.head - int64(len(t.buf)); oldest > 0 {
+		return oldest
+	}
+	return 0
+}
+
+// joinGroup registers a consumer group that starts at the oldest retained
+// message, mirroring auto.offset.reset=earliest
+func (t *topic) joinGroup(group string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, ok := t.groups[group]; !ok {
+		t.groups[group] = t.oldest()
+	}
+}
+
+// leaveGroup unregisters a consumer group, so its unread messages no longer
+// hold back producers
+func (t *topic) leaveGroup(group string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.groups, group)
+	t.broadcast()
+}
+
+// full reports whether appending would overwrite a message that some group
+// has not read yet. Without groups the oldest message is simply dropped.
+func (t *topic) full() bool {
+	for _, next := range t.groups {
+		if t.head-next >= int64(len(t.buf)) {
+			return true
+		}
+	}
+	return false
+}
+
+// wait blocks until the topic changes or ctx is done. It must be called with
+// t.mu held and returns with it held again.
+func (t *topic) wait(ctx context.Context) error {
+	t.waiters++
+	changed := t.changed
+	t.mu.Unlock()
+
+	select {
+	case <-changed:
+	case <-ctx.Done():
+	}
+
+	t.mu.Lock()
+	t.waiters--
+	return ctx.Err()
+}
+
+// broadcast wakes every goroutine blocked in wait. It must be called with t.mu held.
+func (t *topic) broadcast() {
+	if t.waiters > 0 {
+		close(t.changed)
+		t.changed = make(chan struct{})
+	}
+}
+
+// append adds msgs to the log, blocking while the slowest group is a full
+// buffer behind
+func (t *topic) append(ctx context.Context, msgs []*common.Message) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, msg := range msgs {
+		for t.full() {
+			if err := t.wait(ctx); err != nil {
+				return err
+			}
+		}
+
+		// Store a copy so consumers never share a struct or headers map
+		// with the producer
+		stored := *msg
+		stored.Headers = maps.Clone(msg.Headers)
+		t.buf[t.head%int64(len(t.buf))] = &stored
+		t.head++
+	}
+
+	t.broadcast()
+	return nil
+}
+
+// next hands the group's next unread message to the caller, blocking until
+// one is available or ctx is done
+func (t *topic) next(ctx context.Context, group string) (*common.Message, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for ctx.Err() == nil {
+		offset := t.groups[group]
+		if offset < t.head {
+			msg := t.buf[offset%int64(len(t.buf))]
+			t.groups[group] = offset + 1
+			t.broadcast()
+			return msg, nil
+		}
+
+		if err := t.wait(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	return nil, ctx.Err()
+}
+
+// MemoryQueue implements the MessageQueue interface on top of an in-process
+// log. It involves no broker, network or serialization, which makes it a
+// baseline for the overhead of the benchmark harness itself.
+type MemoryQueue struct {
+	topic         *topic
+	topicName     string
+	consumerGroup string
+	leaveOnClose  bool // the group is this queue's alone
+	retry         common.RetryPolicy
+	failures      common.FailureCounters
+}
+
+// NewMemoryQueue creates a queue on the named in-process topic. The capacity
+// only applies when the topic is created by this call. An empty consumerGroup
+// creates a produce-only queue.
+func NewMemoryQueue(topicName, consumerGroup string, capacity int) (*MemoryQueue, error) {
+	if capacity <= 0 {
+		return nil, fmt.Errorf("invalid capacity %d: must be positive", capacity)
+	}
+
+	t := getTopic(topicName, capacity)
+	if consumerGroup != "" {
+		t.joinGroup(consumerGroup)
+	}
+
+	return &MemoryQueue{
+		topic:         t,
+		topicName:     topicName,
+		consumerGroup: consumerGroup,
+	}, nil
+}
+
+// SetRetryPolicy controls how often Consume retries a failing handler
+func (m *MemoryQueue) SetRetryPolicy(policy common.RetryPolicy) {
+	m.retry = policy
+}
+
+// FailureStats reports the retries and dropped messages seen by Consume
+func (m *MemoryQueue) FailureStats() common.FailureStats {
+	return m.failures.Stats()
+}
+
+// Produce appends a message to the topic, waiting while it is full
+func (m *MemoryQueue) Produce(ctx context.Context, msg *common.Message) error {
+	if err := m.topic.append(ctx, []*common.Message{msg}); err != nil {
+		return fmt.Errorf("failed to append message: %w", err)
+	}
+	return nil
+}
+
+// ProduceBatch appends all messages to the topic under a single lock
+func (m *MemoryQueue) ProduceBatch(ctx context.Context, msgs []*common.Message) error {
+	if err := m.topic.append(ctx, msgs); err != nil {
+		return fmt.Errorf("failed to append batch: %w", err)
+	}
+	return nil
+}
+
+// Consume delivers the consumer group's messages to handler until ctx is
+// cancelled. A failing handler is retried according to the retry policy; there
+// is no dead-letter topic, so a message that fails every attempt is dropped.
+func (m *MemoryQueue) Consume(ctx context.Context, handler func(*common.Message) error) error {
+	if m.consumerGroup == "" {
+		return fmt.Errorf("queue on topic %s has no consumer group", m.topicName)
+	}
+
+	for {
+		msg, err := m.topic.next(ctx, m.consumerGroup)
+		if err != nil {
+			// Only cancellation ends the wait for a message
+			return nil
+		}
+
+		attempts, err := m.retry.Handle(ctx, msg, handler)
+		m.failures.AddAttempts(attempts)
+		if err != nil && ctx.Err() == nil {
+			m.failures.AddDiscarded()
+		}
+	}
+}
+
+// Close leaves a consumer group created for this queue alone. The topic
+// outlives its queues, like a broker-side topic.
+func (m *MemoryQueue) Close() error {
+	if m.leaveOnClose {
+		m.topic.leaveGroup(m.consumerGroup)
+	}
+	return nil
+}
+
+// GetName returns the name of this queue implementation
+func (m *MemoryQueue) GetName() string {
+	return "In-Memory"
+}
+
+// Retention returns the most messages the topic keeps while no group reads
+// them. Older ones are overwritten.
+func (m *MemoryQueue) Retention() int {
+	return len(m.topic.buf)
+}
+
+// Len returns the number of messages retained by the topic
+func (m *MemoryQueue) Len() int {
+	m.topic.mu.Lock()
+	defer m.topic.mu.Unlock()
+	return int(m.topic.head - m.topic.oldest())
+}
@@ -0,0 +1,370 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/praneethys/kafka-bullmq-benchmark/pkg/common"
+)
+
+const testGroup = "test-group"
+
+// newTestTopic returns a topic name no other test (or test run) has used,
+// since topics live for the whole process
+func newTestTopic(t *testing.T) string {
+	return fmt.Sprintf("%s-%d", t.Name(), time.Now().UnixNano())
+}
+
+func newTestMessages(n int) []*common.Message {
+	msgs := make([]*common.Message, n)
+	for i := range msgs {
+		msgs[i] = &common.Message{
+			ID:        fmt.Sprintf("msg-%d", i),
+			Payload:   []byte("payload"),
+			Timestamp: time.Now(),
+		}
+	}
+	return msgs
+}
+
+// consumeN runs Consume until n messages have been handled and returns their
+// IDs in delivery order
+func consumeN(t *testing.T, queue *MemoryQueue, n int) []string {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var ids []string
+	err := queue.Consume(ctx, func(msg *common.Message) error {
+		ids = append(ids, msg.ID)
+		if len(ids) == n {
+			cancel()
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Consume failed: %v", err)
+	}
+
+	if len(ids) != n {
+		t.Fatalf("Expected %d messages, got %d", n, len(ids))
+	}
+	return ids
+}
+
+func TestNewMemoryQueue(t *testing.T) {
+	topicName := newTestTopic(t)
+	queue, err := NewMemoryQueue(topicName, testGroup, 10)
+	if err != nil {
+		t.Fatalf("Failed to create memory queue: %v", err)
+	}
+	defer queue.Close()
+
+	if queue.topicName != topicName {
+		t.Errorf("Expected topic '%s', got '%s'", topicName, queue.topicName)
+	}
+
+	if queue.consumerGroup != testGroup {
+		t.Errorf("Expected consumer group '%s', got '%s'", testGroup, queue.consumerGroup)
+	}
+
+	if len(queue.topic.buf) != 10 {
+		t.Errorf("Expected capacity 10, got %d", len(queue.topic.buf))
+	}
+}
+
+func TestNewMemoryQueueInvalidCapacity(t *testing.T) {
+	topicName := newTestTopic(t)
+	if _, err := NewMemoryQueue(topicName, testGroup, 0); err == nil {
+		t.Error("Expected error for zero capacity, got nil")
+	}
+}
+
+func TestMemoryGetName(t *testing.T) {
+	topicName := newTestTopic(t)
+	queue, err := NewMemoryQueue(topicName, "", 10)
+	if err != nil {
+		t.Fatalf("Failed to create memory queue: %v", err)
+	}
+
+	if name := queue.GetName(); name != "In-Memory" {
+		t.Errorf("Expected name 'In-Memory', got '%s'", name)
+	}
+}
+
+func TestMemoryProduceAndConsume(t *testing.T) {
+	topicName := newTestTopic(t)
+	producer, err := NewMemoryQueue(topicName, "", 10)
+	if err != nil {
+		t.Fatalf("Failed to create producer queue: %v", err)
+	}
+
+	consumer, err := NewMemoryQueue(topicName, testGroup, 10)
+	if err != nil {
+		t.Fatalf("Failed to create consumer queue: %v", err)
+	}
+
+	msgs := newTestMessages(5)
+	for _, msg := range msgs {
+		if err := producer.Produce(context.Background(), msg); err != nil {
+			t.Fatalf("Failed to produce message %s: %v", msg.ID, err)
+		}
+	}
+
+	ids := consumeN(t, consumer, len(msgs))
+	for i, msg := range msgs {
+		if ids[i] != msg.ID {
+			t.Errorf("Expected message %d to be %s, got %s", i, msg.ID, ids[i])
+		}
+	}
+}
+
+func TestMemoryProduceBatch(t *testing.T) {
+	topicName := newTestTopic(t)
+	producer, err := NewMemoryQueue(topicName, "", 10)
+	if err != nil {
+		t.Fatalf("Failed to create producer queue: %v", err)
+	}
+
+	if err := producer.ProduceBatch(context.Background(), newTestMessages(4)); err != nil {
+		t.Fatalf("Failed to produce batch: %v", err)
+	}
+
+	if producer.Len() != 4 {
+		t.Errorf("Expected 4 retained messages, got %d", producer.Len())
+	}
+}
+
+func TestMemoryHeaders(t *testing.T) {
+	topicName := newTestTopic(t)
+	producer, _ := NewMemoryQueue(topicName, "", 10)
+	consumer, _ := NewMemoryQueue(topicName, testGroup, 10)
+
+	msg := newTestMessages(1)[0]
+	msg.Headers = map[string]string{"trace-id": "abc"}
+	if err := producer.Produce(context.Background(), msg); err != nil {
+		t.Fatalf("Failed to produce message: %v", err)
+	}
+
+	// Changes made by the producer after sending must not leak to consumers
+	msg.Headers["trace-id"] = "changed"
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var received map[string]string
+	_ = consumer.Consume(ctx, func(m *common.Message) error { //nolint:errcheck // Returns nil on cancel
+		received = m.Headers
+		cancel()
+		return nil
+	})
+
+	if received["trace-id"] != "abc" {
+		t.Errorf("Expected header trace-id=abc, got %q", received["trace-id"])
+	}
+}
+
+func TestMemoryConsumerGroups(t *testing.T) {
+	topicName := newTestTopic(t)
+	producer, _ := NewMemoryQueue(topicName, "", 100)
+	groupA, _ := NewMemoryQueue(topicName, "group-a", 100)
+	groupB, _ := NewMemoryQueue(topicName, "group-b", 100)
+
+	msgs := newTestMessages(50)
+	if err := producer.ProduceBatch(context.Background(), msgs); err != nil {
+		t.Fatalf("Failed to produce batch: %v", err)
+	}
+
+	// Every group sees every message
+	if ids := consumeN(t, groupB, len(msgs)); len(ids) != len(msgs) {
+		t.Errorf("Expected group-b to receive %d messages, got %d", len(msgs), len(ids))
+	}
+
+	// Consumers within a group split the messages without duplicates
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var mu sync.Mutex
+	seen := make(map[string]int)
+	var wg sync.WaitGroup
+	for c := 0; c < 4; c++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = groupA.Consume(ctx, func(msg *common.Message) error { //nolint:errcheck // Returns nil on cancel
+				mu.Lock()
+				defer mu.Unlock()
+				seen[msg.ID]++
+				if len(seen) == len(msgs) {
+					cancel()
+				}
+				return nil
+			})
+		}()
+	}
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Error("Timeout waiting for group-a consumers")
+		cancel()
+	}
+	wg.Wait()
+
+	for _, msg := range msgs {
+		if seen[msg.ID] != 1 {
+			t.Errorf("Expected message %s once in group-a, got %d", msg.ID, seen[msg.ID])
+		}
+	}
+}
+
+func TestMemoryBackpressure(t *testing.T) {
+	topicName := newTestTopic(t)
+	producer, _ := NewMemoryQueue(topicName, "", 2)
+	consumer, _ := NewMemoryQueue(topicName, testGroup, 2)
+
+	if err := producer.ProduceBatch(context.Background(), newTestMessages(2)); err != nil {
+		t.Fatalf("Failed to fill topic: %v", err)
+	}
+
+	// The group has not read anything, so a third message must wait
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	err := producer.Produce(ctx, newTestMessages(1)[0])
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected deadline exceeded on full topic, got %v", err)
+	}
+
+	// Reading one message frees a slot
+	consumeN(t, consumer, 1)
+	if err := producer.Produce(context.Background(), newTestMessages(1)[0]); err != nil {
+		t.Errorf("Expected produce to succeed after consuming, got %v", err)
+	}
+}
+
+func TestMemoryRetentionWithoutGroups(t *testing.T) {
+	topicName := newTestTopic(t)
+	producer, _ := NewMemoryQueue(topicName, "", 3)
+
+	// Nobody is reading, so the oldest messages are dropped instead of blocking
+	msgs := newTestMessages(5)
+	if err := producer.ProduceBatch(context.Background(), msgs); err != nil {
+		t.Fatalf("Failed to produce batch: %v", err)
+	}
+
+	// A new group starts at the oldest retained message
+	consumer, _ := NewMemoryQueue(topicName, testGroup, 3)
+	ids := consumeN(t, consumer, 3)
+	for i, id := range ids {
+		if want := msgs[i+2].ID; id != want {
+			t.Errorf("Expected message %d to be %s, got %s", i, want, id)
+		}
+	}
+}
+
+func TestMemoryReplayGroupLeavesOnClose(t *testing.T) {
+	topicName := newTestTopic(t)
+	backend := &backend{topic: topicName, capacity: 2}
+
+	producer, _ := NewMemoryQueue(topicName, "", 2)
+	if err := producer.ProduceBatch(context.Background(), newTestMessages(2)); err != nil {
+		t.Fatalf("Failed to fill topic: %v", err)
+	}
+
+	queue, err := backend.NewQueue(common.BackendOptions{Role: common.RoleConsumer, Replay: true})
+	if err != nil {
+		t.Fatalf("Failed to create replay queue: %v", err)
+	}
+
+	// The replay group starts at the beginning of the history
+	consumeN(t, queue.(*MemoryQueue), 1)
+
+	// Once closed, its unread message no longer holds back producers
+	if err := queue.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := producer.ProduceBatch(ctx, newTestMessages(2)); err != nil {
+		t.Errorf("Expected produce to succeed after the replay group left, got %v", err)
+	}
+}
+
+func TestMemoryConsumeWithoutGroup(t *testing.T) {
+	topicName := newTestTopic(t)
+	producer, _ := NewMemoryQueue(topicName, "", 3)
+
+	err := producer.Consume(context.Background(), func(*common.Message) error { return nil })
+	if err == nil {
+		t.Error("Expected error when consuming without a consumer group")
+	}
+}
+
+func TestMemoryConsumeStopsOnCancel(t *testing.T) {
+	topicName := newTestTopic(t)
+	consumer, _ := NewMemoryQueue(topicName, testGroup, 3)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- consumer.Consume(ctx, func(*common.Message) error { return nil })
+	}()
+
+	cancel()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Expected nil error on cancel, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Error("Consume did not return after cancel")
+	}
+}
+
+func TestMemoryRetryPolicy(t *testing.T) {
+	topicName := newTestTopic(t)
+	producer, _ := NewMemoryQueue(topicName, "", 10)
+	consumer, _ := NewMemoryQueue(topicName, testGroup, 10)
+	consumer.SetRetryPolicy(common.RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond})
+
+	msgs := newTestMessages(2)
+	if err := producer.ProduceBatch(context.Background(), msgs); err != nil {
+		t.Fatalf("Failed to produce batch: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// msg-0 succeeds on its second attempt, msg-1 never does
+	calls := make(map[string]int)
+	_ = consumer.Consume(ctx, func(msg *common.Message) error { //nolint:errcheck // Returns nil on cancel
+		calls[msg.ID]++
+		if msg.ID == "msg-0" && calls[msg.ID] == 2 {
+			return nil
+		}
+		if msg.ID == "msg-1" && calls[msg.ID] == 3 {
+			cancel()
+		}
+		return errors.New("fail")
+	})
+
+	if calls["msg-0"] != 2 || calls["msg-1"] != 3 {
+		t.Errorf("Expected 2 and 3 calls, got %v", calls)
+	}
+
+	stats := consumer.FailureStats()
+	if stats.Redeliveries != 3 {
+		t.Errorf("Expected 3 redeliveries, got %d", stats.Redeliveries)
+	}
+
+	// Cancelled right after the last attempt, so msg-1 is not counted as discarded
+	if stats.Discarded != 0 {
+		t.Errorf("Expected 0 discarded, got %d", stats.Discarded)
+	}
+}
@@ -1,18 +1,162 @@
 package metrics
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"math/rand"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/praneethys/kafka-bullmq-benchmark/pkg/common"
 )
 
+// produceTimeout bounds a single produce call so a stalled broker cannot keep
+// a producer goroutine alive past the end of its phase
+const produceTimeout = 30 * time.Second
+
+// asyncProducer is implemented by queues that can enqueue without waiting for
+// the broker acknowledgment (e.g. Kafka)
+type asyncProducer interface {
+	ProduceAsync(ctx context.Context, msg *common.Message) error
+}
+
+// deliveryReporter is implemented by async producers that report each
+// message's broker acknowledgment after ProduceAsync has returned
+type deliveryReporter interface {
+	SetDeliveryHandler(handler func(msg *common.Message, latency time.Duration, err error))
+}
+
+// flusher is implemented by queues that buffer produced messages client-side
+type flusher interface {
+	Flush(timeoutMs int) int
+}
+
+// retentionLimiter is implemented by queues that only keep a bounded number
+// of messages nobody has read yet, e.g. an in-memory ring buffer
+type retentionLimiter interface {
+	Retention() int
+}
+
+// codecQueue is implemented by queues that serialize messages with a
+// pluggable codec
+type codecQueue interface {
+	Codec() common.Codec
+}
+
+// codecName returns the name of the codec used by queue, or "" if the queue
+// does not serialize messages
+func codecName(queue common.MessageQueue) string {
+	if cq, ok := queue.(codecQueue); ok && cq.Codec() != nil {
+		return cq.Codec().Name()
+	}
+	return ""
+}
+
+// clientConfig returns the client settings queue reports for role, or nil if
+// it reports none
+func clientConfig(queue common.MessageQueue, role common.Role) map[string]string {
+	if cr, ok := queue.(common.ClientConfigReporter); ok {
+		return cr.ClientConfig(role)
+	}
+	return nil
+}
+
+// applyTransactionStats copies the transaction counters of queue into result
+func applyTransactionStats(queue common.MessageQueue, result *common.BenchmarkResult) {
+	if tr, ok := queue.(common.TransactionReporter); ok {
+		stats := tr.TransactionStats()
+		result.TransactionCount = int(stats.Committed)
+		result.TransactionAbortCount = int(stats.Aborted)
+		result.TransactionCommitTime = summarize(stats.CommitTimes)
+	}
+}
+
+// applyCommitStats copies the offset commit counters of queue into result
+func applyCommitStats(queue common.MessageQueue, result *common.BenchmarkResult) {
+	if cr, ok := queue.(common.CommitReporter); ok {
+		stats := cr.CommitStats()
+		result.OffsetCommitCount = int(stats.Commits)
+		result.OffsetCommitErrorCount = int(stats.Errors)
+		result.OffsetCommitLatency = summarize(stats.Latencies)
+	}
+}
+
+// applyReclaimStats copies the pending entries reclaimed by the consumers of
+// queue into result
+func applyReclaimStats(queue common.MessageQueue, result *common.BenchmarkResult) {
+	if rr, ok := queue.(common.ReclaimReporter); ok {
+		stats := rr.ReclaimStats()
+		result.ReclaimedCount = int(stats.Reclaimed)
+		result.ReclaimExhaustedCount = int(stats.Exhausted)
+		result.ReclaimLatency = summarize(stats.Latencies)
+	}
+}
+
+// applyGroupMembers copies the consumer group members listed by queue into
+// result
+func applyGroupMembers(queue common.MessageQueue, result *common.BenchmarkResult) {
+	gr, ok := queue.(common.GroupMemberReporter)
+	if !ok {
+		return
+	}
+	members, err := gr.GroupMembers()
+	if err != nil {
+		fmt.Printf("Could not list consumer group members: %v\n", err)
+		return
+	}
+	result.GroupMembers = members
+}
+
+// applyRebalanceStats copies the consumer group rebalances of queue into
+// result. Each assignment completes a rebalance.
+func applyRebalanceStats(queue common.MessageQueue, result *common.BenchmarkResult) {
+	rr, ok := queue.(common.RebalanceReporter)
+	if !ok {
+		return
+	}
+
+	result.RebalanceEvents = rr.RebalanceEvents()
+	for _, event := range result.RebalanceEvents {
+		if event.Kind == "assign" {
+			result.RebalanceCount++
+			result.RebalanceTime += event.Paused
+		}
+	}
+}
+
+// errInjectedFailure is returned by handlers for the share of calls selected
+// by FailureRate
+var errInjectedFailure = errors.New("injected handler failure")
+
+// applyFailureStats copies the retry counters of queue into result
+func applyFailureStats(queue common.MessageQueue, result *common.BenchmarkResult) {
+	if fr, ok := queue.(common.FailureReporter); ok {
+		stats := fr.FailureStats()
+		result.RedeliveryCount = int(stats.Redeliveries)
+		result.DeadLetterCount = int(stats.DeadLettered)
+		result.DiscardedCount = int(stats.Discarded)
+		result.UndecodableCount = int(stats.Undecodable)
+	}
+}
+
+// givenUpCount returns how many messages queue has dead-lettered or
+// discarded after their handler failed every attempt
+func givenUpCount(queue common.MessageQueue) int {
+	if fr, ok := queue.(common.FailureReporter); ok {
+		stats := fr.FailureStats()
+		return int(stats.DeadLettered + stats.Discarded)
+	}
+	return 0
+}
+
 // Benchmark runs performance tests on message queues
 type Benchmark struct {
 	config    *common.BenchmarkConfig
 	collector *Collector
+	runID     string // stamped on every produced message, new for each run
 }
 
 // NewBenchmark creates a new benchmark instance
@@ -23,214 +167,590 @@ func NewBenchmark(config *common.BenchmarkConfig) *Benchmark {
 	}
 }
 
-// RunProducerBenchmark runs a producer-only benchmark
-func (b *Benchmark) RunProducerBenchmark(queue common.MessageQueue) (*common.BenchmarkResult, error) {
-	b.collector.Reset()
+// injectFailure reports whether the current handler call should fail
+func (b *Benchmark) injectFailure(injected *atomic.Int64) bool {
+	if b.config.FailureRate > 0 && rand.Float64() < b.config.FailureRate {
+		injected.Add(1)
+		return true
+	}
+	return false
+}
 
-	payload := make([]byte, b.config.MessageSize)
-	for i := range payload {
-		payload[i] = byte(i % 256)
+// newHeaders builds the configured headers for one message, or nil if none
+// are configured
+func (b *Benchmark) newHeaders() map[string]string {
+	if len(b.config.Headers) == 0 {
+		return nil
+	}
+
+	headers := make(map[string]string, len(b.config.Headers))
+	for key, value := range b.config.Headers {
+		if value == common.HeaderValueUUID {
+			value = uuid.New().String()
+		}
+		headers[key] = value
+	}
+	return headers
+}
+
+// produce sends a single message with a per-call deadline, preferring the
+// async path when the queue supports it
+func (b *Benchmark) produce(ctx context.Context, queue common.MessageQueue, msg *common.Message) error {
+	callCtx, cancel := context.WithTimeout(ctx, produceTimeout)
+	defer cancel()
+
+	if aq, ok := queue.(asyncProducer); ok {
+		return aq.ProduceAsync(callCtx, msg)
+	}
+	return queue.Produce(callCtx, msg)
+}
+
+// produceBatch sends msgs with a single per-call deadline and returns how many
+// of them failed
+func (b *Benchmark) produceBatch(ctx context.Context, queue common.BatchProducer, msgs []*common.Message) int {
+	callCtx, cancel := context.WithTimeout(ctx, produceTimeout)
+	defer cancel()
+
+	err := queue.ProduceBatch(callCtx, msgs)
+	if err == nil {
+		return 0
+	}
+
+	var batchErr *common.BatchError
+	if errors.As(err, &batchErr) {
+		return batchErr.Failed
+	}
+	return len(msgs)
+}
+
+// runProducers sends MessageCount messages spread over ProducerCount
+// goroutines and calls onSent after every produce call with the messages it
+// carried, how long it took and how many of them failed. Messages go out in
+// batches of BatchSize when it is greater than one and the queue supports
+// batching. Encode times are recorded here for queues that use a codec. It
+// returns once every producer goroutine has finished.
+func (b *Benchmark) runProducers(ctx context.Context, queue common.MessageQueue, payload []byte, onSent func(msgs []*common.Message, latency time.Duration, failed int)) {
+	encodes := codecName(queue) != ""
+
+	batchSize := 1
+	batcher, canBatch := queue.(common.BatchProducer)
+	if canBatch && b.config.BatchSize > 1 {
+		batchSize = b.config.BatchSize
+	}
+
+	// Async produce returns before the broker acknowledges, so its call
+	// time is not an ack latency. Where the queue reports acknowledgments,
+	// messages are accounted for when their report arrives instead.
+	_, isAsync := queue.(asyncProducer)
+	waitsForAck := batchSize > 1 || !isAsync
+	reporter, reportsAcks := queue.(deliveryReporter)
+	reportsAcks = reportsAcks && !waitsForAck
+	if reportsAcks {
+		reporter.SetDeliveryHandler(func(msg *common.Message, latency time.Duration, err error) {
+			failed := 0
+			if err != nil {
+				failed = 1
+			} else {
+				b.collector.RecordAckLatency(latency)
+			}
+			onSent([]*common.Message{msg}, latency, failed)
+		})
+		defer reporter.SetDeliveryHandler(nil)
 	}
 
 	var wg sync.WaitGroup
 	messagesPerProducer := b.config.MessageCount / b.config.ProducerCount
 
-	startTime := time.Now()
-
 	for p := 0; p < b.config.ProducerCount; p++ {
 		wg.Add(1)
 		go func(producerID int) {
 			defer wg.Done()
 
-			for i := 0; i < messagesPerProducer; i++ {
-				msg := &common.Message{
-					ID:        uuid.New().String(),
-					Payload:   payload,
-					Timestamp: time.Now(),
+			var seq uint64
+			keySeqs := make([]uint64, b.config.KeyCount)
+
+			for sent := 0; sent < messagesPerProducer && ctx.Err() == nil; {
+				n := batchSize
+				if remaining := messagesPerProducer - sent; remaining < n {
+					n = remaining
 				}
 
-				msgStart := time.Now()
+				msgs := make([]*common.Message, n)
+				for i := range msgs {
+					seq++
+					msgs[i] = &common.Message{
+						ID:         uuid.New().String(),
+						Payload:    payload,
+						Timestamp:  time.Now(),
+						RunID:      b.runID,
+						ProducerID: producerID,
+						Sequence:   seq,
+						Headers:    b.newHeaders(),
+					}
 
-				// Use async produce for Kafka if available
-				if kq, ok := queue.(interface{ ProduceAsync(*common.Message) error }); ok {
-					if err := kq.ProduceAsync(msg); err != nil {
-						b.collector.RecordError()
-						continue
+					// Keys are assigned round-robin so every key sees a
+					// steady share of the producer's traffic
+					if len(keySeqs) > 0 {
+						k := int((seq - 1) % uint64(len(keySeqs)))
+						keySeqs[k]++
+						msgs[i].Key = fmt.Sprintf("key-%d", k)
+						msgs[i].KeySequence = keySeqs[k]
 					}
-				} else {
-					if err := queue.Produce(msg); err != nil {
-						b.collector.RecordError()
-						continue
+				}
+
+				start := time.Now()
+				failed := 0
+				if batchSize > 1 {
+					failed = b.produceBatch(ctx, batcher, msgs)
+				} else if err := b.produce(ctx, queue, msgs[0]); err != nil {
+					failed = 1
+				}
+
+				latency := time.Since(start)
+				if encodes {
+					for _, msg := range msgs {
+						b.collector.RecordEncodeTime(msg.Trace.EncodeTime)
+					}
+				}
+				if waitsForAck {
+					for i := failed; i < len(msgs); i++ {
+						b.collector.RecordAckLatency(latency)
 					}
 				}
 
-				latency := time.Since(msgStart)
-				b.collector.RecordLatency(latency)
-				b.collector.AddBytesProcessed(int64(len(payload)))
+				// Enqueued messages are reported by the delivery handler
+				if !reportsAcks || failed > 0 {
+					onSent(msgs, latency, failed)
+				}
+				sent += n
 			}
 		}(p)
 	}
 
 	wg.Wait()
 
-	// Flush Kafka producer if available
-	if kq, ok := queue.(interface{ Flush(int) int }); ok {
+	// Flush Kafka producer if available; this also waits for the delivery
+	// reports still outstanding
+	if kq, ok := queue.(flusher); ok {
 		kq.Flush(30000) // 30 second timeout
 	}
+}
 
-	b.collector.Stop()
+// recordReceived records the end-to-end latency of a consumed message and
+// the parts of it the queue was able to trace, and returns the latency
+func (b *Benchmark) recordReceived(msg *common.Message, decodes bool) time.Duration {
+	now := time.Now()
+	latency := now.Sub(msg.Timestamp)
+	b.collector.RecordLatency(latency)
+	b.collector.AddBytesProcessed(int64(len(msg.Payload)))
 
-	duration := time.Since(startTime)
-	fmt.Printf("Producer benchmark completed in %v\n", duration)
+	if decodes {
+		b.collector.RecordDecodeTime(msg.Trace.DecodeTime)
+	}
 
-	return b.collector.GetResults(queue.GetName(), b.config.MessageCount), nil
+	if trace := msg.Trace; !trace.ReceiveTime.IsZero() {
+		b.collector.RecordProcessingTime(now.Sub(trace.ReceiveTime))
+		if !trace.BrokerTime.IsZero() {
+			b.collector.RecordDwellTime(trace.ReceiveTime.Sub(trace.BrokerTime))
+		}
+	}
+	return latency
 }
 
-// RunConsumerBenchmark runs a consumer-only benchmark
-func (b *Benchmark) RunConsumerBenchmark(queue common.MessageQueue, expectedMessages int) (*common.BenchmarkResult, error) {
-	b.collector.Reset()
+// startConsumers launches ConsumerCount goroutines running queue.Consume with
+// handler, which is told the ID of the goroutine each message reached. The
+// returned function cancels them and blocks until every one of them has
+// returned.
+func (b *Benchmark) startConsumers(ctx context.Context, queue common.MessageQueue, handler func(consumerID int, msg *common.Message) error) (stop func()) {
+	consumeCtx, cancel := context.WithCancel(ctx)
 
 	var wg sync.WaitGroup
-	stopChan := make(chan bool)
-	receivedCount := 0
-	var countMu sync.Mutex
-
-	// Start consumers
 	for c := 0; c < b.config.ConsumerCount; c++ {
 		wg.Add(1)
 		go func(consumerID int) {
 			defer wg.Done()
 
-			handler := func(msg *common.Message) error {
-				latency := time.Since(msg.Timestamp)
-				b.collector.RecordLatency(latency)
-				b.collector.AddBytesProcessed(int64(len(msg.Payload)))
-
-				countMu.Lock()
-				receivedCount++
-				if receivedCount >= expectedMessages {
-					select {
-					case stopChan <- true:
-					default:
-					}
-				}
-				countMu.Unlock()
+			err := queue.Consume(consumeCtx, func(msg *common.Message) error {
+				return handler(consumerID, msg)
+			})
+			if err != nil {
+				fmt.Printf("Consumer %d stopped with error: %v\n", consumerID, err)
+			}
+		}(c)
+	}
+
+	return func() {
+		cancel()
+		wg.Wait()
+	}
+}
+
+// RunProducerBenchmark runs a producer-only benchmark
+func (b *Benchmark) RunProducerBenchmark(ctx context.Context, queue common.MessageQueue) (*common.BenchmarkResult, error) {
+	b.collector.Reset()
+	b.runID = uuid.New().String()
+
+	payload := make([]byte, b.config.MessageSize)
+	for i := range payload {
+		payload[i] = byte(i % 256)
+	}
 
-				return nil
+	startTime := time.Now()
+
+	b.runProducers(ctx, queue, payload, func(msgs []*common.Message, latency time.Duration, failed int) {
+		// Every message in a batch waits for the whole batch to be acknowledged
+		for i := range msgs {
+			if i < failed {
+				b.collector.RecordError()
+				continue
 			}
+			b.collector.RecordLatency(latency)
+			b.collector.AddBytesProcessed(int64(len(payload)))
+		}
+	})
 
-			_ = queue.Consume(handler) //nolint:errcheck // Consumer runs until context cancel
-		}(c)
+	b.collector.Stop()
+
+	duration := time.Since(startTime)
+	fmt.Printf("Producer benchmark completed in %v\n", duration)
+
+	result := b.collector.GetResults(queue.GetName(), b.config.MessageCount)
+	result.Mode = common.ModeProducer
+	result.Codec = codecName(queue)
+	result.ProducerConfig = clientConfig(queue, common.RoleProducer)
+	applyTransactionStats(queue, result)
+	applyClientStats(result, queue)
+	return result, nil
+}
+
+// RunConsumerBenchmark runs a consumer-only benchmark
+func (b *Benchmark) RunConsumerBenchmark(ctx context.Context, queue common.MessageQueue, expectedMessages int) (*common.BenchmarkResult, error) {
+	b.collector.Reset()
+
+	stopChan := make(chan bool, 1)
+	receivedCount := 0
+	var countMu sync.Mutex
+	decodes := codecName(queue) != ""
+	ordering := newOrderingTracker()
+	partitions := newPartitionTracker(b.config.ConsumerCount)
+	var injected atomic.Int64
+
+	handler := func(consumerID int, msg *common.Message) error {
+		if b.injectFailure(&injected) {
+			return errInjectedFailure
+		}
+
+		latency := b.recordReceived(msg, decodes)
+		ordering.observe(msg)
+		partitions.observe(consumerID, msg, latency)
+
+		countMu.Lock()
+		receivedCount++
+		if receivedCount >= expectedMessages {
+			select {
+			case stopChan <- true:
+			default:
+			}
+		}
+		countMu.Unlock()
+
+		return nil
 	}
 
-	// Wait for all messages or timeout
+	stopConsumers := b.startConsumers(ctx, queue, handler)
+
+	// Wait for all messages, timeout or cancellation
 	select {
 	case <-stopChan:
 		fmt.Printf("All %d messages consumed\n", expectedMessages)
 	case <-time.After(time.Duration(b.config.DurationSeconds) * time.Second):
+		countMu.Lock()
 		fmt.Printf("Timeout reached, consumed %d messages\n", receivedCount)
+		countMu.Unlock()
+	case <-ctx.Done():
+		fmt.Println("Consumer benchmark interrupted")
 	}
 
 	b.collector.Stop()
-
-	return b.collector.GetResults(queue.GetName(), receivedCount), nil
+	stopConsumers()
+
+	countMu.Lock()
+	defer countMu.Unlock()
+
+	result := b.collector.GetResults(queue.GetName(), receivedCount)
+	result.Mode = common.ModeConsumer
+	result.Codec = codecName(queue)
+	result.ConsumerConfig = clientConfig(queue, common.RoleConsumer)
+	result.InjectedFailureCount = int(injected.Load())
+	ordering.apply(result)
+	partitions.apply(result, knownPartitions(queue))
+	applyFailureStats(queue, result)
+	applyCommitStats(queue, result)
+	applyReclaimStats(queue, result)
+	applyGroupMembers(queue, result)
+	applyRebalanceStats(queue, result)
+	applyClientStats(result, queue)
+	return result, nil
 }
 
 // RunFullBenchmark runs both producer and consumer benchmarks
-func (b *Benchmark) RunFullBenchmark(producerQueue, consumerQueue common.MessageQueue) (*common.BenchmarkResult, error) {
+func (b *Benchmark) RunFullBenchmark(ctx context.Context, producerQueue, consumerQueue common.MessageQueue) (*common.BenchmarkResult, error) {
 	fmt.Printf("Starting full benchmark for %s\n", producerQueue.GetName())
 	fmt.Printf("Configuration: %d messages, %d bytes each, %d producers, %d consumers\n",
 		b.config.MessageCount, b.config.MessageSize, b.config.ProducerCount, b.config.ConsumerCount)
 
 	b.collector.Reset()
+	b.runID = uuid.New().String()
 
 	payload := make([]byte, b.config.MessageSize)
 	for i := range payload {
 		payload[i] = byte(i % 256)
 	}
 
-	var producerWg sync.WaitGroup
-	var consumerWg sync.WaitGroup
+	decodes := codecName(consumerQueue) != ""
+	ordering := newOrderingTracker()
+	delivery := newDeliveryTracker(b.runID, b.config.ProducerCount, b.config.MessageCount/b.config.ProducerCount)
+	partitions := newPartitionTracker(b.config.ConsumerCount)
+	var injected atomic.Int64
 
-	messagesPerProducer := b.config.MessageCount / b.config.ProducerCount
-	receivedCount := 0
-	var countMu sync.Mutex
-	stopChan := make(chan bool, 1)
+	handler := func(consumerID int, msg *common.Message) error {
+		if b.injectFailure(&injected) {
+			return errInjectedFailure
+		}
 
-	// Start consumers first
-	for c := 0; c < b.config.ConsumerCount; c++ {
-		consumerWg.Add(1)
-		go func(consumerID int) {
-			defer consumerWg.Done()
-
-			handler := func(msg *common.Message) error {
-				latency := time.Since(msg.Timestamp)
-				b.collector.RecordLatency(latency)
-				b.collector.AddBytesProcessed(int64(len(msg.Payload)))
-
-				countMu.Lock()
-				receivedCount++
-				if receivedCount >= b.config.MessageCount {
-					select {
-					case stopChan <- true:
-					default:
-					}
-				}
-				countMu.Unlock()
+		// Leftovers from earlier runs would skew latency and ordering
+		if !delivery.observe(msg) {
+			return nil
+		}
 
-				return nil
-			}
+		latency := b.recordReceived(msg, decodes)
+		ordering.observe(msg)
+		partitions.observe(consumerID, msg, latency)
 
-			_ = consumerQueue.Consume(handler) //nolint:errcheck // Consumer runs until context cancel
-		}(c)
+		return nil
 	}
 
+	// Start consumers first
+	stopConsumers := b.startConsumers(ctx, consumerQueue, handler)
+
 	// Give consumers time to start
-	time.Sleep(2 * time.Second)
+	select {
+	case <-time.After(2 * time.Second):
+	case <-ctx.Done():
+	}
 
 	// Start producers
-	for p := 0; p < b.config.ProducerCount; p++ {
-		producerWg.Add(1)
-		go func(producerID int) {
-			defer producerWg.Done()
+	var sentMu sync.Mutex
+	sent := 0
+	b.runProducers(ctx, producerQueue, payload, func(msgs []*common.Message, _ time.Duration, failed int) {
+		for i := 0; i < failed; i++ {
+			b.collector.RecordError()
+		}
+
+		sentMu.Lock()
+		sent += len(msgs) - failed
+		sentMu.Unlock()
+	})
 
-			for i := 0; i < messagesPerProducer; i++ {
-				msg := &common.Message{
-					ID:        uuid.New().String(),
-					Payload:   payload,
-					Timestamp: time.Now(),
-				}
+	fmt.Println("All producers finished")
 
-				if kq, ok := producerQueue.(interface{ ProduceAsync(*common.Message) error }); ok {
-					if err := kq.ProduceAsync(msg); err != nil {
-						b.collector.RecordError()
-					}
-				} else {
-					if err := producerQueue.Produce(msg); err != nil {
-						b.collector.RecordError()
-					}
-				}
+	// Only messages that were produced without error are waited for
+	delivery.expect(sent)
+	producersDone := time.Now()
+
+	// Messages the consumers give up on never complete the delivery tracker,
+	// and idleness has no event of its own, so both are polled
+	idleTimeout := time.Duration(b.config.IdleTimeoutSeconds) * time.Second
+	poll := time.NewTicker(100 * time.Millisecond)
+	defer poll.Stop()
+
+	// Wait for all messages to be consumed, the consumers to go idle,
+	// timeout or cancellation
+	timeout := time.After(time.Duration(b.config.DurationSeconds) * time.Second)
+wait:
+	for {
+		select {
+		case <-delivery.done:
+			fmt.Printf("All %d messages consumed\n", sent)
+			break wait
+		case <-poll.C:
+			if givenUp := givenUpCount(consumerQueue); givenUp > 0 && delivery.settled(givenUp) {
+				fmt.Printf("All %d messages consumed or given up on\n", sent)
+				break wait
 			}
-		}(p)
+			if idleTimeout > 0 && time.Since(delivery.idleSince(producersDone)) >= idleTimeout {
+				delivered, expected := delivery.counts()
+				fmt.Printf("No messages for %v, consumed %d/%d messages\n", idleTimeout, delivered, expected)
+				break wait
+			}
+		case <-timeout:
+			delivered, expected := delivery.counts()
+			fmt.Printf("Timeout reached, consumed %d/%d messages\n", delivered, expected)
+			break wait
+		case <-ctx.Done():
+			fmt.Println("Benchmark interrupted")
+			break wait
+		}
 	}
 
-	producerWg.Wait()
-	fmt.Println("All producers finished")
+	b.collector.Stop()
+	stopConsumers()
+
+	result := b.collector.GetResults(producerQueue.GetName(), b.config.MessageCount)
+	result.Mode = common.ModeFull
+	result.Codec = codecName(producerQueue)
+	result.ProducerConfig = clientConfig(producerQueue, common.RoleProducer)
+	result.ConsumerConfig = clientConfig(consumerQueue, common.RoleConsumer)
+	result.InjectedFailureCount = int(injected.Load())
+	ordering.apply(result)
+	delivery.apply(result)
+	partitions.apply(result, knownPartitions(consumerQueue))
+	applyFailureStats(consumerQueue, result)
+	applyCommitStats(consumerQueue, result)
+	applyReclaimStats(consumerQueue, result)
+	applyGroupMembers(consumerQueue, result)
+	applyRebalanceStats(consumerQueue, result)
+	applyTransactionStats(producerQueue, result)
+	applyClientStats(result, producerQueue, consumerQueue)
+
+	// Messages the consumers gave up on are accounted for, not lost
+	result.LostCount = max(0, result.LostCount-result.DeadLetterCount-result.DiscardedCount)
+	return result, nil
+}
 
-	// Flush Kafka producer if available
-	if kq, ok := producerQueue.(interface{ Flush(int) int }); ok {
-		kq.Flush(30000)
+// RunReplayBenchmark measures catch-up reads. It produces MessageCount
+// messages to producerQueue first, then creates a fresh consumer with
+// newConsumer and times how long it takes to read the history from the
+// beginning. newConsumer must return a queue in a consumer group of its own
+// that starts at the oldest message; it is closed before returning.
+//
+// Throughput is over the read phase only. Latency is the time from the start
+// of the read phase until each message arrived, so P50 is when half of the
+// history had been read. Messages of earlier runs still retained by the
+// broker are read and counted too, and reported as unexpected.
+func (b *Benchmark) RunReplayBenchmark(ctx context.Context, producerQueue common.MessageQueue, newConsumer func() (common.MessageQueue, error)) (*common.BenchmarkResult, error) {
+	fmt.Printf("Starting replay benchmark for %s\n", producerQueue.GetName())
+	fmt.Printf("Configuration: %d messages, %d bytes each, %d producers, %d consumers\n",
+		b.config.MessageCount, b.config.MessageSize, b.config.ProducerCount, b.config.ConsumerCount)
+
+	// The history is written before anyone reads it, so it must fit
+	if rl, ok := producerQueue.(retentionLimiter); ok && b.config.MessageCount > rl.Retention() {
+		return nil, fmt.Errorf("replay of %d messages exceeds the %d messages %s retains before any are read",
+			b.config.MessageCount, rl.Retention(), producerQueue.GetName())
 	}
 
-	// Wait for all messages to be consumed or timeout
-	select {
-	case <-stopChan:
-		fmt.Printf("All %d messages consumed\n", b.config.MessageCount)
-	case <-time.After(time.Duration(b.config.DurationSeconds) * time.Second):
-		fmt.Printf("Timeout reached, consumed %d/%d messages\n", receivedCount, b.config.MessageCount)
+	b.collector.Reset()
+	b.runID = uuid.New().String()
+
+	payload := make([]byte, b.config.MessageSize)
+	for i := range payload {
+		payload[i] = byte(i % 256)
 	}
 
-	b.collector.Stop()
+	// Populate the history
+	populateStart := time.Now()
+	var sentMu sync.Mutex
+	sent, failed := 0, 0
+	b.runProducers(ctx, producerQueue, payload, func(msgs []*common.Message, _ time.Duration, n int) {
+		sentMu.Lock()
+		sent += len(msgs) - n
+		failed += n
+		sentMu.Unlock()
+	})
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("replay interrupted while populating: %w", err)
+	}
+	fmt.Printf("Populated %d messages in %v\n", sent, time.Since(populateStart))
 
-	return b.collector.GetResults(producerQueue.GetName(), b.config.MessageCount), nil
+	// Only the read phase is measured
+	b.collector.Reset()
+	for i := 0; i < failed; i++ {
+		b.collector.RecordError()
+	}
+
+	consumerQueue, err := newConsumer()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create replay consumer: %w", err)
+	}
+	defer func() {
+		_ = consumerQueue.Close() //nolint:errcheck // Best effort cleanup, the results are complete
+	}()
+
+	decodes := codecName(consumerQueue) != ""
+	ordering := newOrderingTracker()
+	delivery := newDeliveryTracker(b.runID, b.config.ProducerCount, b.config.MessageCount/b.config.ProducerCount)
+	delivery.expect(sent)
+	partitions := newPartitionTracker(b.config.ConsumerCount)
+
+	start := time.Now()
+	var firstMessage atomic.Int64 // nanoseconds after start, 0 until the first message
+
+	handler := func(consumerID int, msg *common.Message) error {
+		now := time.Now()
+		elapsed := now.Sub(start)
+		firstMessage.CompareAndSwap(0, int64(max(elapsed, 1)))
+
+		b.collector.RecordLatency(elapsed)
+		b.collector.AddBytesProcessed(int64(len(msg.Payload)))
+		if decodes {
+			b.collector.RecordDecodeTime(msg.Trace.DecodeTime)
+		}
+		if !msg.Trace.ReceiveTime.IsZero() {
+			b.collector.RecordProcessingTime(now.Sub(msg.Trace.ReceiveTime))
+		}
+
+		if delivery.observe(msg) {
+			ordering.observe(msg)
+			partitions.observe(consumerID, msg, elapsed)
+		}
+		return nil
+	}
+
+	stopConsumers := b.startConsumers(ctx, consumerQueue, handler)
+
+	idleTimeout := time.Duration(b.config.IdleTimeoutSeconds) * time.Second
+	poll := time.NewTicker(100 * time.Millisecond)
+	defer poll.Stop()
+
+	timeout := time.After(time.Duration(b.config.DurationSeconds) * time.Second)
+wait:
+	for {
+		select {
+		case <-delivery.done:
+			fmt.Printf("All %d messages replayed\n", sent)
+			break wait
+		case <-poll.C:
+			if idleTimeout > 0 && time.Since(delivery.idleSince(start)) >= idleTimeout {
+				delivered, expected := delivery.counts()
+				fmt.Printf("No messages for %v, replayed %d/%d messages\n", idleTimeout, delivered, expected)
+				break wait
+			}
+		case <-timeout:
+			delivered, expected := delivery.counts()
+			fmt.Printf("Timeout reached, replayed %d/%d messages\n", delivered, expected)
+			break wait
+		case <-ctx.Done():
+			fmt.Println("Replay interrupted")
+			break wait
+		}
+	}
+
+	b.collector.Stop()
+	stopConsumers()
+
+	result := b.collector.GetResults(producerQueue.GetName(), b.config.MessageCount)
+	result.Mode = common.ModeReplay
+	result.FirstMessageTime = time.Duration(firstMessage.Load())
+	result.Codec = codecName(producerQueue)
+	result.ProducerConfig = clientConfig(producerQueue, common.RoleProducer)
+	result.ConsumerConfig = clientConfig(consumerQueue, common.RoleConsumer)
+	ordering.apply(result)
+	delivery.apply(result)
+	partitions.apply(result, knownPartitions(consumerQueue))
+	applyCommitStats(consumerQueue, result)
+	applyReclaimStats(consumerQueue, result)
+	applyGroupMembers(consumerQueue, result)
+	applyRebalanceStats(consumerQueue, result)
+	applyClientStats(result, producerQueue, consumerQueue)
+	return result, nil
 }
@@ -1,10 +1,16 @@
 package metrics
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/praneethys/kafka-bullmq-benchmark/pkg/common"
+	"github.com/praneethys/kafka-bullmq-benchmark/pkg/memory"
 )
 
 func TestNewBenchmark(t *testing.T) {
@@ -34,21 +40,25 @@ func TestNewBenchmark(t *testing.T) {
 // MockQueue implements the MessageQueue interface for testing
 type MockQueue struct {
 	name         string
-	produceCount int
-	consumeCount int
+	produceCount int32
+	consumeCount int32
 	produceError error
 	consumeError error
+	active       int32 // Consume calls that have not returned yet
 }
 
-func (m *MockQueue) Produce(msg *common.Message) error {
-	m.produceCount++
+func (m *MockQueue) Produce(ctx context.Context, msg *common.Message) error {
+	atomic.AddInt32(&m.produceCount, 1)
 	if m.produceError != nil {
 		return m.produceError
 	}
 	return nil
 }
 
-func (m *MockQueue) Consume(handler func(*common.Message) error) error {
+func (m *MockQueue) Consume(ctx context.Context, handler func(*common.Message) error) error {
+	atomic.AddInt32(&m.active, 1)
+	defer atomic.AddInt32(&m.active, -1)
+
 	if m.consumeError != nil {
 		return m.consumeError
 	}
@@ -63,11 +73,12 @@ func (m *MockQueue) Consume(handler func(*common.Message) error) error {
 		if err := handler(msg); err != nil {
 			return err
 		}
-		m.consumeCount++
+		atomic.AddInt32(&m.consumeCount, 1)
 	}
 
-	// Block forever (will be interrupted by test timeout or context)
-	select {}
+	// Block until the runner stops this consumer
+	<-ctx.Done()
+	return nil
 }
 
 func (m *MockQueue) Close() error {
@@ -78,6 +89,211 @@ func (m *MockQueue) GetName() string {
 	return m.name
 }
 
+// MockBatchQueue is a MockQueue that also supports batch produce
+type MockBatchQueue struct {
+	MockQueue
+	mu          sync.Mutex
+	batchSizes  []int
+	failPerCall int
+}
+
+func (m *MockBatchQueue) ProduceBatch(ctx context.Context, msgs []*common.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.batchSizes = append(m.batchSizes, len(msgs))
+
+	if m.failPerCall > 0 {
+		return &common.BatchError{Failed: m.failPerCall, Total: len(msgs), Err: errors.New("mock failure")}
+	}
+	return nil
+}
+
+// MockCodecQueue is a MockQueue that reports a codec and fixed encode and
+// decode times
+type MockCodecQueue struct {
+	MockQueue
+}
+
+func (m *MockCodecQueue) Codec() common.Codec {
+	return common.BinaryCodec{}
+}
+
+func (m *MockCodecQueue) Produce(ctx context.Context, msg *common.Message) error {
+	msg.Trace.EncodeTime = 2 * time.Microsecond
+	return m.MockQueue.Produce(ctx, msg)
+}
+
+// MockConfigQueue is a MockQueue that reports client settings
+type MockConfigQueue struct {
+	MockQueue
+}
+
+func (m *MockConfigQueue) ClientConfig(role common.Role) map[string]string {
+	return map[string]string{"role": role.String()}
+}
+
+// MockTransactionalQueue is a MockQueue that reports transaction stats
+type MockTransactionalQueue struct {
+	MockQueue
+}
+
+func (m *MockTransactionalQueue) TransactionStats() common.TransactionStats {
+	return common.TransactionStats{
+		Committed:   2,
+		Aborted:     1,
+		CommitTimes: []time.Duration{time.Millisecond, 3 * time.Millisecond},
+	}
+}
+
+// MockCommitQueue is a MockTracedQueue that reports offset commits
+type MockCommitQueue struct {
+	MockTracedQueue
+}
+
+func (m *MockCommitQueue) CommitStats() common.CommitStats {
+	return common.CommitStats{Commits: 4, Errors: 1, Latencies: []time.Duration{2 * time.Millisecond}}
+}
+
+// MockAsyncQueue is a MockQueue with fire-and-forget produce whose delivery
+// reports arrive on another goroutine, failing every failEvery-th message
+type MockAsyncQueue struct {
+	MockQueue
+	failEvery int
+	mu        sync.Mutex
+	handler   func(msg *common.Message, latency time.Duration, err error)
+	pending   sync.WaitGroup
+	enqueued  int
+}
+
+func (m *MockAsyncQueue) SetDeliveryHandler(handler func(msg *common.Message, latency time.Duration, err error)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.handler = handler
+}
+
+func (m *MockAsyncQueue) ProduceAsync(ctx context.Context, msg *common.Message) error {
+	m.mu.Lock()
+	m.enqueued++
+	var err error
+	if m.failEvery > 0 && m.enqueued%m.failEvery == 0 {
+		err = errors.New("delivery failed")
+	}
+	m.mu.Unlock()
+
+	m.pending.Add(1)
+	go func() {
+		defer m.pending.Done()
+		time.Sleep(5 * time.Millisecond)
+
+		m.mu.Lock()
+		handler := m.handler
+		m.mu.Unlock()
+		if handler != nil {
+			handler(msg, 5*time.Millisecond, err)
+		}
+	}()
+	return nil
+}
+
+func (m *MockAsyncQueue) Flush(timeoutMs int) int {
+	m.pending.Wait()
+	return 0
+}
+
+// MockTracedQueue is a MockQueue whose consumed messages carry broker and
+// receive times
+type MockTracedQueue struct {
+	MockQueue
+}
+
+func (m *MockTracedQueue) Consume(ctx context.Context, handler func(*common.Message) error) error {
+	now := time.Now()
+	for i := 0; i < 10; i++ {
+		msg := &common.Message{
+			ID:        "test",
+			Payload:   []byte("test"),
+			Timestamp: now.Add(-30 * time.Millisecond),
+			Trace: common.Trace{
+				BrokerTime:  now.Add(-20 * time.Millisecond),
+				ReceiveTime: now,
+			},
+		}
+		if err := handler(msg); err != nil {
+			return err
+		}
+	}
+
+	<-ctx.Done()
+	return nil
+}
+
+// MockPartitionedQueue is a MockQueue whose consumed messages alternate
+// between partitions 0 and 1 of a topic that also has an empty partition 2
+type MockPartitionedQueue struct {
+	MockQueue
+}
+
+func (m *MockPartitionedQueue) Consume(ctx context.Context, handler func(*common.Message) error) error {
+	for i := 0; i < 10; i++ {
+		msg := &common.Message{
+			ID:        "test",
+			Payload:   []byte("test"),
+			Timestamp: time.Now().Add(-time.Millisecond),
+			Trace:     common.Trace{Partition: int32(i % 2), Partitioned: true},
+		}
+		if err := handler(msg); err != nil {
+			return err
+		}
+	}
+
+	<-ctx.Done()
+	return nil
+}
+
+func (m *MockPartitionedQueue) Partitions() ([]int32, error) {
+	return []int32{0, 1, 2}, nil
+}
+
+// MockRebalanceQueue is a MockQueue whose consumer was assigned partitions
+// twice, pausing 100ms and then 50ms
+type MockRebalanceQueue struct {
+	MockQueue
+}
+
+func (m *MockRebalanceQueue) RebalanceEvents() []common.RebalanceEvent {
+	return []common.RebalanceEvent{
+		{Kind: "assign", Partitions: []int32{0, 1}, Paused: 100 * time.Millisecond},
+		{Kind: "revoke", Partitions: []int32{0, 1}},
+		{Kind: "assign", Partitions: []int32{1}, Paused: 50 * time.Millisecond},
+	}
+}
+
+// MockReclaimQueue is a MockQueue whose consumer reclaimed two pending
+// entries and gave up on a third
+type MockReclaimQueue struct {
+	MockQueue
+}
+
+func (m *MockReclaimQueue) ReclaimStats() common.ReclaimStats {
+	return common.ReclaimStats{
+		Reclaimed: 2,
+		Exhausted: 1,
+		Latencies: []time.Duration{time.Second, 2 * time.Second, 3 * time.Second},
+	}
+}
+
+// MockGroupQueue is a MockQueue whose consumer group has two members
+type MockGroupQueue struct {
+	MockQueue
+}
+
+func (m *MockGroupQueue) GroupMembers() ([]common.GroupMember, error) {
+	return []common.GroupMember{
+		{Name: "consumer-1", Pending: 1, Idle: time.Second},
+		{Name: "consumer-2", Idle: 2 * time.Second},
+	}, nil
+}
+
 func TestBenchmarkConfigValidation(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -151,3 +367,790 @@ func TestCollectorIntegration(t *testing.T) {
 		t.Error("Expected positive throughput")
 	}
 }
+
+func TestRunConsumerBenchmarkStopsConsumers(t *testing.T) {
+	config := &common.BenchmarkConfig{
+		MessageCount:    30,
+		MessageSize:     4,
+		ProducerCount:   1,
+		ConsumerCount:   3,
+		DurationSeconds: 5,
+	}
+
+	queue := &MockQueue{name: "Mock Queue"}
+	benchmark := NewBenchmark(config)
+
+	result, err := benchmark.RunConsumerBenchmark(context.Background(), queue, config.MessageCount)
+	if err != nil {
+		t.Fatalf("RunConsumerBenchmark failed: %v", err)
+	}
+
+	if active := atomic.LoadInt32(&queue.active); active != 0 {
+		t.Errorf("Expected all consumers to have returned, %d still running", active)
+	}
+
+	if result.MessageCount != config.MessageCount {
+		t.Errorf("Expected MessageCount %d, got %d", config.MessageCount, result.MessageCount)
+	}
+
+	if result.Duration >= time.Duration(config.DurationSeconds)*time.Second {
+		t.Errorf("Expected run to end before the timeout, took %v", result.Duration)
+	}
+}
+
+func TestRunConsumerBenchmarkCancelled(t *testing.T) {
+	config := &common.BenchmarkConfig{
+		MessageCount:    1000,
+		MessageSize:     4,
+		ProducerCount:   1,
+		ConsumerCount:   2,
+		DurationSeconds: 60,
+	}
+
+	queue := &MockQueue{name: "Mock Queue"}
+	benchmark := NewBenchmark(config)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	result, err := benchmark.RunConsumerBenchmark(ctx, queue, config.MessageCount)
+	if err != nil {
+		t.Fatalf("RunConsumerBenchmark failed: %v", err)
+	}
+
+	if active := atomic.LoadInt32(&queue.active); active != 0 {
+		t.Errorf("Expected all consumers to have returned, %d still running", active)
+	}
+
+	// Each mock consumer delivers 10 messages before blocking
+	if result.MessageCount != 20 {
+		t.Errorf("Expected 20 consumed messages, got %d", result.MessageCount)
+	}
+}
+
+func TestRunProducerBenchmarkBatches(t *testing.T) {
+	config := &common.BenchmarkConfig{
+		MessageCount:    10,
+		MessageSize:     8,
+		ProducerCount:   1,
+		ConsumerCount:   1,
+		BatchSize:       4,
+		DurationSeconds: 5,
+	}
+
+	queue := &MockBatchQueue{MockQueue: MockQueue{name: "Mock Batch Queue"}}
+	benchmark := NewBenchmark(config)
+
+	result, err := benchmark.RunProducerBenchmark(context.Background(), queue)
+	if err != nil {
+		t.Fatalf("RunProducerBenchmark failed: %v", err)
+	}
+
+	expected := []int{4, 4, 2}
+	if len(queue.batchSizes) != len(expected) {
+		t.Fatalf("Expected batches %v, got %v", expected, queue.batchSizes)
+	}
+	for i, size := range expected {
+		if queue.batchSizes[i] != size {
+			t.Errorf("Expected batch %d to hold %d messages, got %d", i, size, queue.batchSizes[i])
+		}
+	}
+
+	if queue.produceCount != 0 {
+		t.Errorf("Expected no single-message produce calls, got %d", queue.produceCount)
+	}
+
+	if result.SuccessCount != 10 {
+		t.Errorf("Expected 10 successes, got %d", result.SuccessCount)
+	}
+
+	if result.BytesProcessed != 80 {
+		t.Errorf("Expected 80 bytes processed, got %d", result.BytesProcessed)
+	}
+}
+
+func TestRunProducerBenchmarkBatchPartialFailure(t *testing.T) {
+	config := &common.BenchmarkConfig{
+		MessageCount:    9,
+		MessageSize:     8,
+		ProducerCount:   1,
+		ConsumerCount:   1,
+		BatchSize:       3,
+		DurationSeconds: 5,
+	}
+
+	queue := &MockBatchQueue{MockQueue: MockQueue{name: "Mock Batch Queue"}, failPerCall: 1}
+	benchmark := NewBenchmark(config)
+
+	result, err := benchmark.RunProducerBenchmark(context.Background(), queue)
+	if err != nil {
+		t.Fatalf("RunProducerBenchmark failed: %v", err)
+	}
+
+	if result.ErrorCount != 3 {
+		t.Errorf("Expected 3 errors, got %d", result.ErrorCount)
+	}
+
+	if result.SuccessCount != 6 {
+		t.Errorf("Expected 6 successes, got %d", result.SuccessCount)
+	}
+}
+
+func TestRunProducerBenchmarkWithoutBatchSupport(t *testing.T) {
+	config := &common.BenchmarkConfig{
+		MessageCount:    6,
+		MessageSize:     8,
+		ProducerCount:   2,
+		ConsumerCount:   1,
+		BatchSize:       4,
+		DurationSeconds: 5,
+	}
+
+	queue := &MockQueue{name: "Mock Queue"}
+	benchmark := NewBenchmark(config)
+
+	result, err := benchmark.RunProducerBenchmark(context.Background(), queue)
+	if err != nil {
+		t.Fatalf("RunProducerBenchmark failed: %v", err)
+	}
+
+	if produced := atomic.LoadInt32(&queue.produceCount); produced != 6 {
+		t.Errorf("Expected 6 single-message produce calls, got %d", produced)
+	}
+
+	if result.SuccessCount != 6 {
+		t.Errorf("Expected 6 successes, got %d", result.SuccessCount)
+	}
+}
+
+func TestRunProducerBenchmarkCodecTimes(t *testing.T) {
+	config := &common.BenchmarkConfig{
+		MessageCount:    5,
+		MessageSize:     8,
+		ProducerCount:   1,
+		ConsumerCount:   1,
+		DurationSeconds: 5,
+	}
+
+	queue := &MockCodecQueue{MockQueue: MockQueue{name: "Mock Codec Queue"}}
+	result, err := NewBenchmark(config).RunProducerBenchmark(context.Background(), queue)
+	if err != nil {
+		t.Fatalf("RunProducerBenchmark failed: %v", err)
+	}
+
+	if result.Codec != "binary" {
+		t.Errorf("Expected codec 'binary', got '%s'", result.Codec)
+	}
+
+	if result.EncodeTime.Count != 5 || result.EncodeTime.Avg != 2*time.Microsecond {
+		t.Errorf("Expected 5 encode samples of 2us, got %d with avg %v", result.EncodeTime.Count, result.EncodeTime.Avg)
+	}
+
+	// Queues without a codec report no serialization cost at all
+	plain, err := NewBenchmark(config).RunProducerBenchmark(context.Background(), &MockQueue{name: "Mock Queue"})
+	if err != nil {
+		t.Fatalf("RunProducerBenchmark failed: %v", err)
+	}
+
+	if plain.Codec != "" || plain.EncodeTime.Count != 0 {
+		t.Errorf("Expected no codec stats, got codec '%s' with %d samples", plain.Codec, plain.EncodeTime.Count)
+	}
+}
+
+func TestRunProducerBenchmarkAckLatency(t *testing.T) {
+	config := &common.BenchmarkConfig{
+		MessageCount:    6,
+		MessageSize:     8,
+		ProducerCount:   1,
+		ConsumerCount:   1,
+		DurationSeconds: 5,
+	}
+
+	// MockQueue produces synchronously, so every call waits for the ack
+	result, err := NewBenchmark(config).RunProducerBenchmark(context.Background(), &MockQueue{name: "Mock Queue"})
+	if err != nil {
+		t.Fatalf("RunProducerBenchmark failed: %v", err)
+	}
+
+	if result.AckLatency.Count != 6 {
+		t.Errorf("Expected 6 ack samples, got %d", result.AckLatency.Count)
+	}
+}
+
+func TestRunProducerBenchmarkClientConfig(t *testing.T) {
+	config := &common.BenchmarkConfig{
+		MessageCount:    10,
+		MessageSize:     8,
+		ProducerCount:   1,
+		ConsumerCount:   1,
+		DurationSeconds: 5,
+	}
+
+	producer := &MockConfigQueue{MockQueue: MockQueue{name: "Mock Config Queue"}}
+	result, err := NewBenchmark(config).RunProducerBenchmark(context.Background(), producer)
+	if err != nil {
+		t.Fatalf("RunProducerBenchmark failed: %v", err)
+	}
+
+	if result.ProducerConfig["role"] != "producer" || result.ConsumerConfig != nil {
+		t.Errorf("Expected producer config only, got producer %v consumer %v", result.ProducerConfig, result.ConsumerConfig)
+	}
+
+	// Queues without client settings leave the result empty
+	result, err = NewBenchmark(config).RunProducerBenchmark(context.Background(), &MockQueue{name: "Mock Queue"})
+	if err != nil {
+		t.Fatalf("RunProducerBenchmark failed: %v", err)
+	}
+
+	if result.ProducerConfig != nil {
+		t.Errorf("Expected no producer config, got %v", result.ProducerConfig)
+	}
+}
+
+func TestRunProducerBenchmarkDeliveryReports(t *testing.T) {
+	config := &common.BenchmarkConfig{
+		MessageCount:    20,
+		MessageSize:     8,
+		ProducerCount:   2,
+		ConsumerCount:   1,
+		DurationSeconds: 5,
+	}
+
+	queue := &MockAsyncQueue{MockQueue: MockQueue{name: "Mock Async Queue"}, failEvery: 4}
+	result, err := NewBenchmark(config).RunProducerBenchmark(context.Background(), queue)
+	if err != nil {
+		t.Fatalf("RunProducerBenchmark failed: %v", err)
+	}
+
+	// Success and failure come from the delivery reports, not from
+	// ProduceAsync, which never fails here
+	if result.SuccessCount != 15 || result.ErrorCount != 5 {
+		t.Errorf("Expected 15 successes and 5 errors, got %d and %d", result.SuccessCount, result.ErrorCount)
+	}
+
+	if result.AckLatency.Count != 15 || result.AckLatency.Avg != 5*time.Millisecond {
+		t.Errorf("Expected 15 ack samples of 5ms, got %d averaging %v", result.AckLatency.Count, result.AckLatency.Avg)
+	}
+
+	if result.MinLatency != 5*time.Millisecond {
+		t.Errorf("Expected latency to be the ack latency of 5ms, got %v", result.MinLatency)
+	}
+
+	if queue.handler != nil {
+		t.Error("Expected the delivery handler to be cleared after the run")
+	}
+}
+
+func TestRunProducerBenchmarkTransactions(t *testing.T) {
+	config := &common.BenchmarkConfig{
+		MessageCount:    10,
+		MessageSize:     8,
+		ProducerCount:   1,
+		ConsumerCount:   1,
+		DurationSeconds: 5,
+	}
+
+	queue := &MockTransactionalQueue{MockQueue: MockQueue{name: "Mock Transactional Queue"}}
+	result, err := NewBenchmark(config).RunProducerBenchmark(context.Background(), queue)
+	if err != nil {
+		t.Fatalf("RunProducerBenchmark failed: %v", err)
+	}
+
+	if result.TransactionCount != 2 || result.TransactionAbortCount != 1 {
+		t.Errorf("Expected 2 committed and 1 aborted transaction, got %d and %d",
+			result.TransactionCount, result.TransactionAbortCount)
+	}
+
+	if result.TransactionCommitTime.Avg != 2*time.Millisecond {
+		t.Errorf("Expected average commit time 2ms, got %v", result.TransactionCommitTime.Avg)
+	}
+}
+
+func TestRunConsumerBenchmarkOffsetCommits(t *testing.T) {
+	config := &common.BenchmarkConfig{
+		MessageCount:    10,
+		MessageSize:     4,
+		ProducerCount:   1,
+		ConsumerCount:   1,
+		DurationSeconds: 5,
+	}
+
+	queue := &MockCommitQueue{MockTracedQueue: MockTracedQueue{MockQueue: MockQueue{name: "Mock Commit Queue"}}}
+	result, err := NewBenchmark(config).RunConsumerBenchmark(context.Background(), queue, 10)
+	if err != nil {
+		t.Fatalf("RunConsumerBenchmark failed: %v", err)
+	}
+
+	if result.OffsetCommitCount != 4 || result.OffsetCommitErrorCount != 1 {
+		t.Errorf("Expected 4 commits and 1 error, got %d and %d", result.OffsetCommitCount, result.OffsetCommitErrorCount)
+	}
+
+	if result.OffsetCommitLatency.Count != 1 || result.OffsetCommitLatency.Max != 2*time.Millisecond {
+		t.Errorf("Expected one 2ms commit latency, got %+v", result.OffsetCommitLatency)
+	}
+}
+
+func TestRunConsumerBenchmarkLatencyBreakdown(t *testing.T) {
+	config := &common.BenchmarkConfig{
+		MessageCount:    10,
+		MessageSize:     4,
+		ProducerCount:   1,
+		ConsumerCount:   1,
+		DurationSeconds: 5,
+	}
+
+	queue := &MockTracedQueue{MockQueue: MockQueue{name: "Mock Traced Queue"}}
+	result, err := NewBenchmark(config).RunConsumerBenchmark(context.Background(), queue, 10)
+	if err != nil {
+		t.Fatalf("RunConsumerBenchmark failed: %v", err)
+	}
+
+	if result.DwellTime.Count != 10 || result.DwellTime.Avg != 20*time.Millisecond {
+		t.Errorf("Expected 10 dwell samples of 20ms, got %d averaging %v", result.DwellTime.Count, result.DwellTime.Avg)
+	}
+
+	if result.ProcessingTime.Count != 10 {
+		t.Errorf("Expected 10 processing samples, got %d", result.ProcessingTime.Count)
+	}
+
+	// End-to-end latency covers every stage
+	if result.MinLatency < 30*time.Millisecond {
+		t.Errorf("Expected end-to-end latency of at least 30ms, got %v", result.MinLatency)
+	}
+}
+
+func TestRunConsumerBenchmarkPartitions(t *testing.T) {
+	config := &common.BenchmarkConfig{
+		MessageCount:    20,
+		MessageSize:     4,
+		ProducerCount:   1,
+		ConsumerCount:   2,
+		DurationSeconds: 5,
+	}
+
+	queue := &MockPartitionedQueue{MockQueue: MockQueue{name: "Mock Partitioned Queue"}}
+	result, err := NewBenchmark(config).RunConsumerBenchmark(context.Background(), queue, 20)
+	if err != nil {
+		t.Fatalf("RunConsumerBenchmark failed: %v", err)
+	}
+
+	if len(result.PartitionStats) != 3 {
+		t.Fatalf("Expected 3 partitions, got %+v", result.PartitionStats)
+	}
+
+	for i, want := range []int{10, 10, 0} {
+		p := result.PartitionStats[i]
+		if p.Partition != int32(i) || p.Messages != want || p.Latency.Count != want {
+			t.Errorf("Expected partition %d with %d messages, got %+v", i, want, p)
+		}
+	}
+
+	if result.IdlePartitions != 1 || result.PartitionSkew != 1.5 {
+		t.Errorf("Expected 1 idle partition and skew 1.5, got %d and %.2f", result.IdlePartitions, result.PartitionSkew)
+	}
+
+	if len(result.ConsumerMessages) != 2 || result.ConsumerMessages[0] != 10 || result.ConsumerMessages[1] != 10 {
+		t.Errorf("Expected 10 messages per consumer, got %v", result.ConsumerMessages)
+	}
+
+	if result.IdleConsumers != 0 || result.ConsumerSkew != 1 {
+		t.Errorf("Expected balanced consumers, got %d idle and skew %.2f", result.IdleConsumers, result.ConsumerSkew)
+	}
+}
+
+func TestRunConsumerBenchmarkRebalances(t *testing.T) {
+	config := &common.BenchmarkConfig{
+		MessageCount:    10,
+		MessageSize:     4,
+		ProducerCount:   1,
+		ConsumerCount:   1,
+		DurationSeconds: 5,
+	}
+
+	queue := &MockRebalanceQueue{MockQueue: MockQueue{name: "Mock Rebalance Queue"}}
+	result, err := NewBenchmark(config).RunConsumerBenchmark(context.Background(), queue, 10)
+	if err != nil {
+		t.Fatalf("RunConsumerBenchmark failed: %v", err)
+	}
+
+	if result.RebalanceCount != 2 {
+		t.Errorf("Expected 2 rebalances, got %d", result.RebalanceCount)
+	}
+
+	if result.RebalanceTime != 150*time.Millisecond {
+		t.Errorf("Expected 150ms rebalance time, got %v", result.RebalanceTime)
+	}
+
+	if len(result.RebalanceEvents) != 3 {
+		t.Errorf("Expected 3 rebalance events, got %d", len(result.RebalanceEvents))
+	}
+}
+
+func TestRunConsumerBenchmarkReclaims(t *testing.T) {
+	config := &common.BenchmarkConfig{
+		MessageCount:    10,
+		MessageSize:     4,
+		ProducerCount:   1,
+		ConsumerCount:   1,
+		DurationSeconds: 5,
+	}
+
+	queue := &MockReclaimQueue{MockQueue: MockQueue{name: "Mock Reclaim Queue"}}
+	result, err := NewBenchmark(config).RunConsumerBenchmark(context.Background(), queue, 10)
+	if err != nil {
+		t.Fatalf("RunConsumerBenchmark failed: %v", err)
+	}
+
+	if result.ReclaimedCount != 2 || result.ReclaimExhaustedCount != 1 {
+		t.Errorf("Expected 2 reclaimed and 1 exhausted entry, got %d and %d",
+			result.ReclaimedCount, result.ReclaimExhaustedCount)
+	}
+
+	if result.ReclaimLatency.Count != 3 || result.ReclaimLatency.Max != 3*time.Second {
+		t.Errorf("Expected 3 pending times up to 3s, got %+v", result.ReclaimLatency)
+	}
+}
+
+func TestRunConsumerBenchmarkGroupMembers(t *testing.T) {
+	config := &common.BenchmarkConfig{
+		MessageCount:    10,
+		MessageSize:     4,
+		ProducerCount:   1,
+		ConsumerCount:   2,
+		DurationSeconds: 5,
+	}
+
+	queue := &MockGroupQueue{MockQueue: MockQueue{name: "Mock Group Queue"}}
+	result, err := NewBenchmark(config).RunConsumerBenchmark(context.Background(), queue, 10)
+	if err != nil {
+		t.Fatalf("RunConsumerBenchmark failed: %v", err)
+	}
+
+	if len(result.GroupMembers) != 2 || result.GroupMembers[0].Name != "consumer-1" || result.GroupMembers[0].Pending != 1 {
+		t.Errorf("Expected the 2 group members listed at the end of the run, got %+v", result.GroupMembers)
+	}
+}
+
+// newMemoryQueues returns a producer and a consumer queue on a fresh
+// in-memory topic
+func newMemoryQueues(t *testing.T, capacity int) (producer, consumer *memory.MemoryQueue) {
+	t.Helper()
+
+	topic := fmt.Sprintf("%s-%d", t.Name(), time.Now().UnixNano())
+	producer, err := memory.NewMemoryQueue(topic, "", capacity)
+	if err != nil {
+		t.Fatalf("Failed to create producer queue: %v", err)
+	}
+
+	consumer, err = memory.NewMemoryQueue(topic, "test-group", capacity)
+	if err != nil {
+		t.Fatalf("Failed to create consumer queue: %v", err)
+	}
+
+	return producer, consumer
+}
+
+func TestRunFullBenchmarkInMemory(t *testing.T) {
+	config := &common.BenchmarkConfig{
+		MessageCount:    2000,
+		MessageSize:     64,
+		ProducerCount:   4,
+		ConsumerCount:   4,
+		BatchSize:       50,
+		DurationSeconds: 10,
+	}
+
+	producer, consumer := newMemoryQueues(t, 256)
+	benchmark := NewBenchmark(config)
+
+	result, err := benchmark.RunFullBenchmark(context.Background(), producer, consumer)
+	if err != nil {
+		t.Fatalf("RunFullBenchmark failed: %v", err)
+	}
+
+	if result.SuccessCount != config.MessageCount {
+		t.Errorf("Expected %d successes, got %d", config.MessageCount, result.SuccessCount)
+	}
+
+	if result.ErrorCount != 0 {
+		t.Errorf("Expected no errors, got %d", result.ErrorCount)
+	}
+
+	if result.BytesProcessed != int64(config.MessageCount*config.MessageSize) {
+		t.Errorf("Expected %d bytes, got %d", config.MessageCount*config.MessageSize, result.BytesProcessed)
+	}
+}
+
+func TestRunFullBenchmarkOrdering(t *testing.T) {
+	config := &common.BenchmarkConfig{
+		MessageCount:    500,
+		MessageSize:     16,
+		ProducerCount:   2,
+		ConsumerCount:   1,
+		BatchSize:       10,
+		KeyCount:        4,
+		DurationSeconds: 10,
+	}
+
+	// A single consumer on the in-memory log sees each producer's messages
+	// in the order they were sent
+	producer, consumer := newMemoryQueues(t, 1024)
+	result, err := NewBenchmark(config).RunFullBenchmark(context.Background(), producer, consumer)
+	if err != nil {
+		t.Fatalf("RunFullBenchmark failed: %v", err)
+	}
+
+	if result.SequencedCount != config.MessageCount {
+		t.Errorf("Expected %d sequenced messages, got %d", config.MessageCount, result.SequencedCount)
+	}
+
+	if result.OutOfOrderCount != 0 || result.KeyOutOfOrderCount != 0 || result.SequenceGapCount != 0 {
+		t.Errorf("Expected no ordering violations, got %+v", result.OrderingViolations)
+	}
+}
+
+func TestRunFullBenchmarkUnexpectedMessages(t *testing.T) {
+	config := &common.BenchmarkConfig{
+		MessageCount:    100,
+		MessageSize:     16,
+		ProducerCount:   2,
+		ConsumerCount:   2,
+		DurationSeconds: 10,
+	}
+
+	producer, consumer := newMemoryQueues(t, 1024)
+
+	// Messages left on the topic by an earlier run
+	for i := 1; i <= 5; i++ {
+		leftover := &common.Message{ID: fmt.Sprintf("old-%d", i), RunID: "earlier-run", Sequence: uint64(i), Timestamp: time.Now()}
+		if err := producer.Produce(context.Background(), leftover); err != nil {
+			t.Fatalf("Failed to produce leftover message: %v", err)
+		}
+	}
+
+	result, err := NewBenchmark(config).RunFullBenchmark(context.Background(), producer, consumer)
+	if err != nil {
+		t.Fatalf("RunFullBenchmark failed: %v", err)
+	}
+
+	if result.UnexpectedCount != 5 {
+		t.Errorf("Expected 5 unexpected messages, got %d", result.UnexpectedCount)
+	}
+
+	if result.DeliveredCount != 100 || result.LostCount != 0 || result.DuplicateCount != 0 {
+		t.Errorf("Expected 100 delivered, 0 lost and 0 duplicates, got %d, %d and %d",
+			result.DeliveredCount, result.LostCount, result.DuplicateCount)
+	}
+
+	// Leftovers are kept out of the latency statistics
+	if result.SuccessCount != 100 {
+		t.Errorf("Expected 100 successes, got %d", result.SuccessCount)
+	}
+}
+
+// LossyQueue acknowledges every message but silently drops every dropEvery-th
+type LossyQueue struct {
+	common.MessageQueue
+	dropEvery int
+	produced  int32
+}
+
+func (l *LossyQueue) Produce(ctx context.Context, msg *common.Message) error {
+	if atomic.AddInt32(&l.produced, 1)%int32(l.dropEvery) == 0 {
+		return nil
+	}
+	return l.MessageQueue.Produce(ctx, msg)
+}
+
+func TestRunFullBenchmarkLostMessagesIdleTimeout(t *testing.T) {
+	config := &common.BenchmarkConfig{
+		MessageCount:       100,
+		MessageSize:        16,
+		ProducerCount:      1,
+		ConsumerCount:      1,
+		DurationSeconds:    30,
+		IdleTimeoutSeconds: 1,
+	}
+
+	producer, consumer := newMemoryQueues(t, 1024)
+	lossy := &LossyQueue{MessageQueue: producer, dropEvery: 10}
+
+	start := time.Now()
+	result, err := NewBenchmark(config).RunFullBenchmark(context.Background(), lossy, consumer)
+	if err != nil {
+		t.Fatalf("RunFullBenchmark failed: %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("Expected the idle timeout to end the run early, took %v", elapsed)
+	}
+
+	if result.LostCount != 10 {
+		t.Errorf("Expected 10 lost messages, got %d", result.LostCount)
+	}
+
+	if result.DeliveredCount != 90 {
+		t.Errorf("Expected 90 delivered messages, got %d", result.DeliveredCount)
+	}
+
+	// Each dropped message leaves a gap in the producer's sequence
+	if result.SequenceGapCount != 9 {
+		t.Errorf("Expected 9 sequence gaps, got %d", result.SequenceGapCount)
+	}
+}
+
+func TestRunFullBenchmarkFailureRate(t *testing.T) {
+	config := &common.BenchmarkConfig{
+		MessageCount:       200,
+		MessageSize:        16,
+		ProducerCount:      2,
+		ConsumerCount:      2,
+		DurationSeconds:    30,
+		IdleTimeoutSeconds: 10,
+		Retry:              common.RetryPolicy{MaxAttempts: 2},
+		FailureRate:        0.5,
+	}
+
+	producer, consumer := newMemoryQueues(t, 1024)
+	consumer.SetRetryPolicy(config.Retry)
+
+	start := time.Now()
+	result, err := NewBenchmark(config).RunFullBenchmark(context.Background(), producer, consumer)
+	if err != nil {
+		t.Fatalf("RunFullBenchmark failed: %v", err)
+	}
+
+	// The run settles as soon as every message is delivered or discarded
+	if elapsed := time.Since(start); elapsed > 8*time.Second {
+		t.Errorf("Expected the run to settle without waiting for the idle timeout, took %v", elapsed)
+	}
+
+	if result.InjectedFailureCount == 0 || result.RedeliveryCount == 0 || result.DiscardedCount == 0 {
+		t.Errorf("Expected injected failures, redeliveries and discards, got %d, %d and %d",
+			result.InjectedFailureCount, result.RedeliveryCount, result.DiscardedCount)
+	}
+
+	if result.DeliveredCount+result.DiscardedCount != config.MessageCount {
+		t.Errorf("Expected delivered + discarded = %d, got %d + %d",
+			config.MessageCount, result.DeliveredCount, result.DiscardedCount)
+	}
+
+	if result.LostCount != 0 {
+		t.Errorf("Expected 0 lost, got %d", result.LostCount)
+	}
+}
+
+func TestNewHeaders(t *testing.T) {
+	benchmark := NewBenchmark(&common.BenchmarkConfig{})
+	if headers := benchmark.newHeaders(); headers != nil {
+		t.Errorf("Expected nil headers without configuration, got %v", headers)
+	}
+
+	benchmark = NewBenchmark(&common.BenchmarkConfig{
+		Headers: map[string]string{
+			"tenant-id": "acme",
+			"trace-id":  common.HeaderValueUUID,
+		},
+	})
+
+	first := benchmark.newHeaders()
+	second := benchmark.newHeaders()
+
+	if first["tenant-id"] != "acme" || second["tenant-id"] != "acme" {
+		t.Errorf("Expected static header to be copied, got %q and %q", first["tenant-id"], second["tenant-id"])
+	}
+
+	if first["trace-id"] == common.HeaderValueUUID || first["trace-id"] == "" {
+		t.Errorf("Expected trace-id placeholder to be replaced, got %q", first["trace-id"])
+	}
+
+	if first["trace-id"] == second["trace-id"] {
+		t.Error("Expected a unique trace-id per message")
+	}
+}
+
+func TestRunReplayBenchmarkInMemory(t *testing.T) {
+	config := &common.BenchmarkConfig{
+		MessageCount:    1000,
+		MessageSize:     64,
+		ProducerCount:   2,
+		ConsumerCount:   2,
+		DurationSeconds: 10,
+	}
+
+	topic := fmt.Sprintf("%s-%d", t.Name(), time.Now().UnixNano())
+	producer, err := memory.NewMemoryQueue(topic, "", 4096)
+	if err != nil {
+		t.Fatalf("Failed to create producer queue: %v", err)
+	}
+
+	// History left by an earlier run is read along with this run's
+	if err := producer.Produce(context.Background(), &common.Message{ID: "earlier", Payload: []byte("old")}); err != nil {
+		t.Fatalf("Failed to produce earlier message: %v", err)
+	}
+
+	var consumer *memory.MemoryQueue
+	newConsumer := func() (common.MessageQueue, error) {
+		if producer.Len() != config.MessageCount+1 {
+			t.Errorf("Expected the history to be written before the consumer is created, got %d messages", producer.Len())
+		}
+		consumer, err = memory.NewMemoryQueue(topic, "replay-group", 4096)
+		return consumer, err
+	}
+
+	result, err := NewBenchmark(config).RunReplayBenchmark(context.Background(), producer, newConsumer)
+	if err != nil {
+		t.Fatalf("RunReplayBenchmark failed: %v", err)
+	}
+
+	if result.Mode != common.ModeReplay {
+		t.Errorf("Expected mode %q, got %q", common.ModeReplay, result.Mode)
+	}
+
+	if result.DeliveredCount != config.MessageCount || result.LostCount != 0 {
+		t.Errorf("Expected all %d messages replayed, got %d delivered and %d lost",
+			config.MessageCount, result.DeliveredCount, result.LostCount)
+	}
+
+	if result.UnexpectedCount != 1 || result.SuccessCount != config.MessageCount+1 {
+		t.Errorf("Expected the earlier message to be read and reported as unexpected, got %d unexpected of %d read",
+			result.UnexpectedCount, result.SuccessCount)
+	}
+
+	if result.FirstMessageTime <= 0 || result.FirstMessageTime > result.MaxLatency {
+		t.Errorf("Expected the first message within the replay, got %v (max %v)", result.FirstMessageTime, result.MaxLatency)
+	}
+
+	if result.Throughput <= 0 {
+		t.Error("Expected positive replay throughput")
+	}
+}
+
+func TestRunReplayBenchmarkExceedsRetention(t *testing.T) {
+	config := &common.BenchmarkConfig{
+		MessageCount:    1000,
+		MessageSize:     64,
+		ProducerCount:   1,
+		ConsumerCount:   1,
+		DurationSeconds: 10,
+	}
+
+	topic := fmt.Sprintf("%s-%d", t.Name(), time.Now().UnixNano())
+	producer, err := memory.NewMemoryQueue(topic, "", 100)
+	if err != nil {
+		t.Fatalf("Failed to create producer queue: %v", err)
+	}
+
+	newConsumer := func() (common.MessageQueue, error) {
+		t.Error("Expected no consumer for a history that does not fit")
+		return memory.NewMemoryQueue(topic, "replay-group", 100)
+	}
+
+	if _, err := NewBenchmark(config).RunReplayBenchmark(context.Background(), producer, newConsumer); err == nil {
+		t.Fatal("Expected an error for a replay larger than the retained messages")
+	}
+
+	if producer.Len() != 0 {
+		t.Errorf("Expected nothing produced, got %d messages", producer.Len())
+	}
+}
@@ -0,0 +1,78 @@
+package metrics
+
+import (
+	"sort"
+	"time"
+
+	"github.com/praneethys/kafka-bullmq-benchmark/pkg/common"
+)
+
+// applyClientStats copies the client library statistics of queues into
+// result and summarizes them by the client that reported them. A queue
+// passed twice is only read once.
+func applyClientStats(result *common.BenchmarkResult, queues ...common.MessageQueue) {
+	seen := make(map[common.MessageQueue]bool)
+	for _, queue := range queues {
+		sr, ok := queue.(common.ClientStatsReporter)
+		if !ok || seen[queue] {
+			continue
+		}
+		seen[queue] = true
+		result.ClientStats = append(result.ClientStats, sr.ClientStats()...)
+	}
+
+	sort.SliceStable(result.ClientStats, func(i, j int) bool {
+		return result.ClientStats[i].Time.Before(result.ClientStats[j].Time)
+	})
+	result.ProducerStats = summarizeClientStats(result.ClientStats, common.RoleProducer)
+	result.ConsumerStats = summarizeClientStats(result.ClientStats, common.RoleConsumer)
+}
+
+// summarizeClientStats condenses the samples reported by the client used for
+// role. Intervals without broker traffic leave the RTT and batch averages
+// alone. Request counts are cumulative per client instance, so they add up
+// the last sample of each instance.
+func summarizeClientStats(samples []common.ClientStatsSample, role common.Role) common.ClientStatsSummary {
+	var summary common.ClientStatsSummary
+	var rttSum time.Duration
+	var rttCount, batchCount int
+	var depthSum int64
+	var batchSum float64
+	last := make(map[string]common.ClientStatsSample)
+
+	for _, s := range samples {
+		if s.Role != role {
+			continue
+		}
+		summary.Samples++
+		if s.BrokerRTT > 0 {
+			rttSum += s.BrokerRTT
+			rttCount++
+		}
+		summary.MaxBrokerRTT = max(summary.MaxBrokerRTT, s.BrokerRTTP99)
+		depthSum += s.QueueDepth
+		summary.MaxQueueDepth = max(summary.MaxQueueDepth, s.QueueDepth)
+		if s.BatchSize > 0 {
+			batchSum += s.BatchSize
+			batchCount++
+		}
+		last[s.Client] = s
+	}
+
+	for _, s := range last {
+		summary.Requests += s.Requests
+		summary.Retries += s.Retries
+	}
+
+	if summary.Samples == 0 {
+		return summary
+	}
+	if rttCount > 0 {
+		summary.AvgBrokerRTT = rttSum / time.Duration(rttCount)
+	}
+	if batchCount > 0 {
+		summary.AvgBatchSize = batchSum / float64(batchCount)
+	}
+	summary.AvgQueueDepth = float64(depthSum) / float64(summary.Samples)
+	return summary
+}
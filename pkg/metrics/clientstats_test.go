@@ -0,0 +1,103 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/praneethys/kafka-bullmq-benchmark/pkg/common"
+)
+
+func TestSummarizeClientStats(t *testing.T) {
+	start := time.Now()
+	samples := []common.ClientStatsSample{
+		{Time: start, Role: common.RoleProducer, QueueDepth: 10, Requests: 5},
+		{
+			Time: start.Add(time.Second), Role: common.RoleProducer, BrokerRTT: 2 * time.Millisecond,
+			BrokerRTTP99: 8 * time.Millisecond, QueueDepth: 30, BatchSize: 40, Requests: 50, Retries: 1,
+		},
+		{
+			Time: start.Add(2 * time.Second), Role: common.RoleProducer, BrokerRTT: 4 * time.Millisecond,
+			BrokerRTTP99: 6 * time.Millisecond, QueueDepth: 20, BatchSize: 60, Requests: 90, Retries: 2,
+		},
+		{Time: start.Add(time.Second), Role: common.RoleConsumer, BrokerRTT: time.Millisecond, QueueDepth: 100},
+	}
+
+	producer := summarizeClientStats(samples, common.RoleProducer)
+
+	if producer.Samples != 3 {
+		t.Errorf("Expected 3 producer samples, got %d", producer.Samples)
+	}
+
+	// The idle first interval does not drag the averages down
+	if producer.AvgBrokerRTT != 3*time.Millisecond || producer.AvgBatchSize != 50 {
+		t.Errorf("Expected 3ms RTT and batches of 50, got %v and %.2f", producer.AvgBrokerRTT, producer.AvgBatchSize)
+	}
+
+	if producer.MaxBrokerRTT != 8*time.Millisecond {
+		t.Errorf("Expected 8ms max RTT, got %v", producer.MaxBrokerRTT)
+	}
+
+	if producer.AvgQueueDepth != 20 || producer.MaxQueueDepth != 30 {
+		t.Errorf("Expected queue depth avg 20 max 30, got %.2f and %d", producer.AvgQueueDepth, producer.MaxQueueDepth)
+	}
+
+	if producer.Requests != 90 || producer.Retries != 2 {
+		t.Errorf("Expected the last totals of 90 requests and 2 retries, got %d and %d", producer.Requests, producer.Retries)
+	}
+
+	// Totals of another producer instance add up with the first one's
+	samples = append(samples, common.ClientStatsSample{
+		Time: start.Add(time.Second), Role: common.RoleProducer, Client: "other", Requests: 7, Retries: 1,
+	})
+	if producer := summarizeClientStats(samples, common.RoleProducer); producer.Requests != 97 || producer.Retries != 3 {
+		t.Errorf("Expected 97 requests and 3 retries over both instances, got %d and %d", producer.Requests, producer.Retries)
+	}
+
+	consumer := summarizeClientStats(samples, common.RoleConsumer)
+	if consumer.Samples != 1 || consumer.MaxQueueDepth != 100 {
+		t.Errorf("Expected one consumer sample with depth 100, got %+v", consumer)
+	}
+}
+
+func TestSummarizeClientStatsEmpty(t *testing.T) {
+	summary := summarizeClientStats(nil, common.RoleProducer)
+	if summary != (common.ClientStatsSummary{}) {
+		t.Errorf("Expected an empty summary, got %+v", summary)
+	}
+}
+
+// mockStatsQueue is a MockQueue whose client reports the given samples
+type mockStatsQueue struct {
+	MockQueue
+	samples []common.ClientStatsSample
+}
+
+func (m *mockStatsQueue) ClientStats() []common.ClientStatsSample {
+	return m.samples
+}
+
+func TestApplyClientStats(t *testing.T) {
+	start := time.Now()
+	producer := &mockStatsQueue{samples: []common.ClientStatsSample{
+		{Time: start.Add(time.Second), Role: common.RoleProducer, Requests: 10},
+	}}
+	consumer := &mockStatsQueue{samples: []common.ClientStatsSample{
+		{Time: start, Role: common.RoleConsumer, Requests: 3},
+	}}
+
+	var result common.BenchmarkResult
+	applyClientStats(&result, producer, consumer, consumer, &MockQueue{})
+
+	if len(result.ClientStats) != 2 {
+		t.Fatalf("Expected each queue read once for 2 samples, got %d", len(result.ClientStats))
+	}
+
+	if result.ClientStats[0].Role != common.RoleConsumer {
+		t.Error("Expected samples in time order")
+	}
+
+	if result.ProducerStats.Requests != 10 || result.ConsumerStats.Requests != 3 {
+		t.Errorf("Expected 10 producer and 3 consumer requests, got %d and %d",
+			result.ProducerStats.Requests, result.ConsumerStats.Requests)
+	}
+}
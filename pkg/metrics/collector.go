@@ -12,6 +12,11 @@ import (
 type Collector struct {
 	mu             sync.Mutex
 	latencies      []time.Duration
+	encodeTimes    []time.Duration
+	decodeTimes    []time.Duration
+	ackLatencies   []time.Duration
+	dwellTimes     []time.Duration
+	processTimes   []time.Duration
 	errorCount     int
 	successCount   int
 	bytesProcessed int64
@@ -35,6 +40,44 @@ func (c *Collector) RecordLatency(latency time.Duration) {
 	c.successCount++
 }
 
+// RecordEncodeTime records the time spent serializing one message
+func (c *Collector) RecordEncodeTime(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.encodeTimes = append(c.encodeTimes, d)
+}
+
+// RecordDecodeTime records the time spent deserializing one message
+func (c *Collector) RecordDecodeTime(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.decodeTimes = append(c.decodeTimes, d)
+}
+
+// RecordAckLatency records the time from a produce call to the broker
+// acknowledgment for one message
+func (c *Collector) RecordAckLatency(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ackLatencies = append(c.ackLatencies, d)
+}
+
+// RecordDwellTime records how long one message sat in the broker between
+// being stored and being received by a consumer
+func (c *Collector) RecordDwellTime(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.dwellTimes = append(c.dwellTimes, d)
+}
+
+// RecordProcessingTime records the time from a consumer receiving one message
+// to its handler being called
+func (c *Collector) RecordProcessingTime(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.processTimes = append(c.processTimes, d)
+}
+
 // RecordError records an error
 func (c *Collector) RecordError() {
 	c.mu.Lock()
@@ -81,32 +124,60 @@ func (c *Collector) GetResults(queueType string, messageCount int) *common.Bench
 		result.MBPerSecond = float64(c.bytesProcessed) / (1024 * 1024) / duration.Seconds()
 	}
 
-	if len(c.latencies) > 0 {
-		sort.Slice(c.latencies, func(i, j int) bool {
-			return c.latencies[i] < c.latencies[j]
-		})
-
-		result.MinLatency = c.latencies[0]
-		result.MaxLatency = c.latencies[len(c.latencies)-1]
-		result.P50Latency = c.latencies[len(c.latencies)*50/100]
-		result.P95Latency = c.latencies[len(c.latencies)*95/100]
-		result.P99Latency = c.latencies[len(c.latencies)*99/100]
-
-		var sum time.Duration
-		for _, l := range c.latencies {
-			sum += l
-		}
-		result.AvgLatency = sum / time.Duration(len(c.latencies))
-	}
+	latency := summarize(c.latencies)
+	result.AvgLatency = latency.Avg
+	result.MinLatency = latency.Min
+	result.P50Latency = latency.P50
+	result.P95Latency = latency.P95
+	result.P99Latency = latency.P99
+	result.MaxLatency = latency.Max
+
+	result.AckLatency = summarize(c.ackLatencies)
+	result.DwellTime = summarize(c.dwellTimes)
+	result.ProcessingTime = summarize(c.processTimes)
+	result.EncodeTime = summarize(c.encodeTimes)
+	result.DecodeTime = summarize(c.decodeTimes)
 
 	return result
 }
 
+// summarize sorts durations in place and returns their distribution. An empty
+// slice gives zero stats.
+func summarize(durations []time.Duration) common.LatencyStats {
+	if len(durations) == 0 {
+		return common.LatencyStats{}
+	}
+
+	sort.Slice(durations, func(i, j int) bool {
+		return durations[i] < durations[j]
+	})
+
+	var sum time.Duration
+	for _, d := range durations {
+		sum += d
+	}
+
+	return common.LatencyStats{
+		Count: len(durations),
+		Avg:   sum / time.Duration(len(durations)),
+		Min:   durations[0],
+		P50:   durations[len(durations)*50/100],
+		P95:   durations[len(durations)*95/100],
+		P99:   durations[len(durations)*99/100],
+		Max:   durations[len(durations)-1],
+	}
+}
+
 // Reset resets all metrics
 func (c *Collector) Reset() {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	c.latencies = make([]time.Duration, 0, 1000000)
+	c.encodeTimes = nil
+	c.decodeTimes = nil
+	c.ackLatencies = nil
+	c.dwellTimes = nil
+	c.processTimes = nil
 	c.errorCount = 0
 	c.successCount = 0
 	c.bytesProcessed = 0
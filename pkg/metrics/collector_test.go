@@ -164,3 +164,66 @@ func TestLatencyPercentiles(t *testing.T) {
 		t.Errorf("Expected P99 around 99ms, got %v", result.P99Latency)
 	}
 }
+
+func TestCodecTimes(t *testing.T) {
+	collector := NewCollector()
+
+	for i := 1; i <= 100; i++ {
+		collector.RecordEncodeTime(time.Duration(i) * time.Microsecond)
+	}
+	collector.RecordDecodeTime(3 * time.Microsecond)
+
+	collector.Stop()
+	result := collector.GetResults("Test", 100)
+
+	if result.EncodeTime.Count != 100 {
+		t.Errorf("Expected 100 encode samples, got %d", result.EncodeTime.Count)
+	}
+
+	if result.EncodeTime.Min != time.Microsecond || result.EncodeTime.Max != 100*time.Microsecond {
+		t.Errorf("Expected encode range 1us-100us, got %v-%v", result.EncodeTime.Min, result.EncodeTime.Max)
+	}
+
+	if result.DecodeTime.Count != 1 || result.DecodeTime.Avg != 3*time.Microsecond {
+		t.Errorf("Expected one decode sample of 3us, got %d with avg %v", result.DecodeTime.Count, result.DecodeTime.Avg)
+	}
+
+	// Codec times are separate from end-to-end latency
+	if result.SuccessCount != 0 {
+		t.Errorf("Expected SuccessCount 0, got %d", result.SuccessCount)
+	}
+
+	collector.Reset()
+	if len(collector.encodeTimes) != 0 || len(collector.decodeTimes) != 0 {
+		t.Error("Expected codec times to be cleared by Reset")
+	}
+}
+
+func TestLatencyBreakdown(t *testing.T) {
+	collector := NewCollector()
+
+	collector.RecordAckLatency(2 * time.Millisecond)
+	collector.RecordAckLatency(4 * time.Millisecond)
+	collector.RecordDwellTime(10 * time.Millisecond)
+	collector.RecordProcessingTime(time.Millisecond)
+
+	collector.Stop()
+	result := collector.GetResults("Test", 2)
+
+	if result.AckLatency.Count != 2 || result.AckLatency.Avg != 3*time.Millisecond {
+		t.Errorf("Expected 2 ack samples averaging 3ms, got %d averaging %v", result.AckLatency.Count, result.AckLatency.Avg)
+	}
+
+	if result.DwellTime.Max != 10*time.Millisecond {
+		t.Errorf("Expected max dwell 10ms, got %v", result.DwellTime.Max)
+	}
+
+	if result.ProcessingTime.Count != 1 {
+		t.Errorf("Expected 1 processing sample, got %d", result.ProcessingTime.Count)
+	}
+
+	collector.Reset()
+	if len(collector.ackLatencies)+len(collector.dwellTimes)+len(collector.processTimes) != 0 {
+		t.Error("Expected breakdown samples to be cleared by Reset")
+	}
+}
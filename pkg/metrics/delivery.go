@@ -0,0 +1,141 @@
+package metrics
+
+import (
+	"sync"
+	"time"
+
+	"github.com/praneethys/kafka-bullmq-benchmark/pkg/common"
+)
+
+// bitset is a fixed-size set of small integers, one bit each
+type bitset []uint64
+
+func newBitset(n int) bitset {
+	return make(bitset, (n+63)/64)
+}
+
+// set adds i and reports whether it was already present
+func (b bitset) set(i int) (present bool) {
+	word, mask := i/64, uint64(1)<<(i%64)
+	present = b[word]&mask != 0
+	b[word] |= mask
+	return present
+}
+
+// deliveryTracker accounts for every message of one benchmark run. Messages
+// are identified by producer and sequence number, so a run of N messages
+// costs N bits instead of a set of IDs.
+type deliveryTracker struct {
+	mu          sync.Mutex
+	runID       string
+	perProducer int
+	consumed    []bitset // per producer, bit Sequence-1
+	expected    int
+	delivered   int
+	duplicates  int
+	unexpected  int
+	lastSeen    time.Time
+	done        chan struct{}
+	closed      bool
+}
+
+// newDeliveryTracker tracks a run in which each of producers sends
+// perProducer messages
+func newDeliveryTracker(runID string, producers, perProducer int) *deliveryTracker {
+	d := &deliveryTracker{
+		runID:       runID,
+		perProducer: perProducer,
+		consumed:    make([]bitset, producers),
+		expected:    producers * perProducer,
+		lastSeen:    time.Now(),
+		done:        make(chan struct{}),
+	}
+	for i := range d.consumed {
+		d.consumed[i] = newBitset(perProducer)
+	}
+	return d
+}
+
+// observe records a consumed message and reports whether it belongs to this
+// run. Duplicates belong to the run and are counted separately.
+func (d *deliveryTracker) observe(msg *common.Message) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.lastSeen = time.Now()
+
+	if msg.RunID != d.runID || msg.ProducerID < 0 || msg.ProducerID >= len(d.consumed) ||
+		msg.Sequence == 0 || msg.Sequence > uint64(d.perProducer) {
+		d.unexpected++
+		return false
+	}
+
+	if d.consumed[msg.ProducerID].set(int(msg.Sequence - 1)) {
+		d.duplicates++
+		return true
+	}
+
+	d.delivered++
+	d.checkDone()
+	return true
+}
+
+// expect sets how many messages the consumers should receive, once the
+// producers know how many they sent without error
+func (d *deliveryTracker) expect(n int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.expected = n
+	d.checkDone()
+}
+
+// checkDone closes done once every expected message has arrived. It must be
+// called with d.mu held.
+func (d *deliveryTracker) checkDone() {
+	if !d.closed && d.delivered >= d.expected {
+		close(d.done)
+		d.closed = true
+	}
+}
+
+// settled reports whether every expected message has either arrived or been
+// given up on by the consumers
+func (d *deliveryTracker) settled(givenUp int) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.delivered+givenUp >= d.expected
+}
+
+// idleSince returns when the last message arrived, or since if that is later
+func (d *deliveryTracker) idleSince(since time.Time) time.Time {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.lastSeen.After(since) {
+		return d.lastSeen
+	}
+	return since
+}
+
+// counts returns the number of distinct messages received and expected
+func (d *deliveryTracker) counts() (delivered, expected int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.delivered, d.expected
+}
+
+// apply copies the delivery counters into result
+func (d *deliveryTracker) apply(result *common.BenchmarkResult) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	result.DeliveredCount = d.delivered
+	result.DuplicateCount = d.duplicates
+	result.UnexpectedCount = d.unexpected
+	// Messages that failed to produce may still arrive, so delivered can
+	// exceed expected
+	if d.expected > d.delivered {
+		result.LostCount = d.expected - d.delivered
+	}
+}
@@ -0,0 +1,123 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/praneethys/kafka-bullmq-benchmark/pkg/common"
+)
+
+func TestBitset(t *testing.T) {
+	b := newBitset(130)
+
+	if len(b) != 3 {
+		t.Errorf("Expected 3 words for 130 bits, got %d", len(b))
+	}
+
+	for _, i := range []int{0, 63, 64, 129} {
+		if b.set(i) {
+			t.Errorf("Expected bit %d to be unset", i)
+		}
+		if !b.set(i) {
+			t.Errorf("Expected bit %d to be set", i)
+		}
+	}
+}
+
+func TestDeliveryTracker(t *testing.T) {
+	tracker := newDeliveryTracker("run-1", 2, 3)
+
+	msg := func(runID string, producerID int, seq uint64) *common.Message {
+		return &common.Message{RunID: runID, ProducerID: producerID, Sequence: seq}
+	}
+
+	for _, m := range []*common.Message{
+		msg("run-1", 0, 1),
+		msg("run-1", 0, 2),
+		msg("run-1", 1, 3),
+	} {
+		if !tracker.observe(m) {
+			t.Errorf("Expected message %d/%d to belong to the run", m.ProducerID, m.Sequence)
+		}
+	}
+
+	// Redelivery
+	if !tracker.observe(msg("run-1", 0, 2)) {
+		t.Error("Expected duplicate to belong to the run")
+	}
+
+	// Earlier run, unknown producer, sequence out of range, unsequenced
+	for _, m := range []*common.Message{
+		msg("run-0", 0, 3),
+		msg("run-1", 2, 1),
+		msg("run-1", 1, 4),
+		{ID: "external"},
+	} {
+		if tracker.observe(m) {
+			t.Errorf("Expected message %+v to be unexpected", m)
+		}
+	}
+
+	var result common.BenchmarkResult
+	tracker.apply(&result)
+
+	if result.DeliveredCount != 3 {
+		t.Errorf("Expected 3 delivered, got %d", result.DeliveredCount)
+	}
+
+	if result.DuplicateCount != 1 {
+		t.Errorf("Expected 1 duplicate, got %d", result.DuplicateCount)
+	}
+
+	if result.UnexpectedCount != 4 {
+		t.Errorf("Expected 4 unexpected, got %d", result.UnexpectedCount)
+	}
+
+	if result.LostCount != 3 {
+		t.Errorf("Expected 3 lost, got %d", result.LostCount)
+	}
+}
+
+func TestDeliveryTrackerDone(t *testing.T) {
+	tracker := newDeliveryTracker("run-1", 1, 4)
+	tracker.observe(&common.Message{RunID: "run-1", Sequence: 1})
+	tracker.observe(&common.Message{RunID: "run-1", Sequence: 2})
+
+	select {
+	case <-tracker.done:
+		t.Fatal("Expected tracker not to be done before all messages arrived")
+	default:
+	}
+
+	// Two messages failed to produce, so the ones received are all there is
+	tracker.expect(2)
+
+	select {
+	case <-tracker.done:
+	case <-time.After(time.Second):
+		t.Fatal("Expected tracker to be done once the expected count is reached")
+	}
+
+	var result common.BenchmarkResult
+	tracker.apply(&result)
+
+	if result.LostCount != 0 {
+		t.Errorf("Expected 0 lost, got %d", result.LostCount)
+	}
+}
+
+func TestDeliveryTrackerIdleSince(t *testing.T) {
+	tracker := newDeliveryTracker("run-1", 1, 1)
+	later := time.Now().Add(time.Hour)
+
+	if got := tracker.idleSince(later); !got.Equal(later) {
+		t.Errorf("Expected idle since %v, got %v", later, got)
+	}
+
+	before := time.Now()
+	tracker.observe(&common.Message{RunID: "run-1", Sequence: 1})
+
+	if got := tracker.idleSince(time.Time{}); got.Before(before) {
+		t.Errorf("Expected idle since the last message, got %v", got)
+	}
+}
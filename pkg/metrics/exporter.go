@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -47,6 +48,7 @@ func ExportToCSV(results []*common.BenchmarkResult, filename string) error {
 	// Write header
 	header := []string{
 		"Queue Type",
+		"Mode",
 		"Message Count",
 		"Duration (s)",
 		"Throughput (msg/s)",
@@ -60,6 +62,56 @@ func ExportToCSV(results []*common.BenchmarkResult, filename string) error {
 		"Success Count",
 		"Error Count",
 		"Bytes Processed",
+		"Avg Ack Latency (ms)",
+		"P99 Ack Latency (ms)",
+		"Avg Dwell (ms)",
+		"P99 Dwell (ms)",
+		"Avg Processing (ms)",
+		"P99 Processing (ms)",
+		"Codec",
+		"Avg Encode (us)",
+		"Avg Decode (us)",
+		"First Message (ms)",
+		"Delivered Count",
+		"Lost Count",
+		"Duplicate Count",
+		"Unexpected Count",
+		"Injected Failures",
+		"Redeliveries",
+		"Dead Lettered",
+		"Discarded",
+		"Undecodable",
+		"Sequenced Count",
+		"Out Of Order",
+		"Key Out Of Order",
+		"Sequence Gaps",
+		"Transactions",
+		"Aborted Transactions",
+		"Avg Commit (ms)",
+		"P99 Commit (ms)",
+		"Offset Commits",
+		"Offset Commit Errors",
+		"Avg Offset Commit (ms)",
+		"P99 Offset Commit (ms)",
+		"Rebalances",
+		"Rebalance Time (ms)",
+		"Reclaimed",
+		"Reclaim Exhausted",
+		"Reclaim Avg Latency (ms)",
+		"Reclaim P99 Latency (ms)",
+		"Producer Avg RTT (ms)",
+		"Producer Max Queue Depth",
+		"Producer Avg Batch Size",
+		"Producer Requests",
+		"Producer Retries",
+		"Consumer Avg RTT (ms)",
+		"Consumer Max Queue Depth",
+		"Consumer Requests",
+		"Partitions",
+		"Idle Partitions",
+		"Partition Skew",
+		"Idle Consumers",
+		"Consumer Skew",
 	}
 	if err := writer.Write(header); err != nil {
 		return fmt.Errorf("failed to write CSV header: %w", err)
@@ -69,6 +121,7 @@ func ExportToCSV(results []*common.BenchmarkResult, filename string) error {
 	for _, result := range results {
 		row := []string{
 			result.QueueType,
+			result.Mode,
 			strconv.Itoa(result.MessageCount),
 			fmt.Sprintf("%.2f", result.Duration.Seconds()),
 			fmt.Sprintf("%.2f", result.Throughput),
@@ -82,6 +135,56 @@ func ExportToCSV(results []*common.BenchmarkResult, filename string) error {
 			strconv.Itoa(result.SuccessCount),
 			strconv.Itoa(result.ErrorCount),
 			strconv.FormatInt(result.BytesProcessed, 10),
+			fmt.Sprintf("%.2f", float64(result.AckLatency.Avg.Microseconds())/1000.0),
+			fmt.Sprintf("%.2f", float64(result.AckLatency.P99.Microseconds())/1000.0),
+			fmt.Sprintf("%.2f", float64(result.DwellTime.Avg.Microseconds())/1000.0),
+			fmt.Sprintf("%.2f", float64(result.DwellTime.P99.Microseconds())/1000.0),
+			fmt.Sprintf("%.2f", float64(result.ProcessingTime.Avg.Microseconds())/1000.0),
+			fmt.Sprintf("%.2f", float64(result.ProcessingTime.P99.Microseconds())/1000.0),
+			result.Codec,
+			fmt.Sprintf("%.2f", float64(result.EncodeTime.Avg.Nanoseconds())/1000.0),
+			fmt.Sprintf("%.2f", float64(result.DecodeTime.Avg.Nanoseconds())/1000.0),
+			fmt.Sprintf("%.2f", float64(result.FirstMessageTime.Microseconds())/1000.0),
+			strconv.Itoa(result.DeliveredCount),
+			strconv.Itoa(result.LostCount),
+			strconv.Itoa(result.DuplicateCount),
+			strconv.Itoa(result.UnexpectedCount),
+			strconv.Itoa(result.InjectedFailureCount),
+			strconv.Itoa(result.RedeliveryCount),
+			strconv.Itoa(result.DeadLetterCount),
+			strconv.Itoa(result.DiscardedCount),
+			strconv.Itoa(result.UndecodableCount),
+			strconv.Itoa(result.SequencedCount),
+			strconv.Itoa(result.OutOfOrderCount),
+			strconv.Itoa(result.KeyOutOfOrderCount),
+			strconv.Itoa(result.SequenceGapCount),
+			strconv.Itoa(result.TransactionCount),
+			strconv.Itoa(result.TransactionAbortCount),
+			fmt.Sprintf("%.2f", float64(result.TransactionCommitTime.Avg.Microseconds())/1000.0),
+			fmt.Sprintf("%.2f", float64(result.TransactionCommitTime.P99.Microseconds())/1000.0),
+			strconv.Itoa(result.OffsetCommitCount),
+			strconv.Itoa(result.OffsetCommitErrorCount),
+			fmt.Sprintf("%.2f", float64(result.OffsetCommitLatency.Avg.Microseconds())/1000.0),
+			fmt.Sprintf("%.2f", float64(result.OffsetCommitLatency.P99.Microseconds())/1000.0),
+			strconv.Itoa(result.RebalanceCount),
+			fmt.Sprintf("%.2f", float64(result.RebalanceTime.Microseconds())/1000.0),
+			strconv.Itoa(result.ReclaimedCount),
+			strconv.Itoa(result.ReclaimExhaustedCount),
+			fmt.Sprintf("%.2f", float64(result.ReclaimLatency.Avg.Microseconds())/1000.0),
+			fmt.Sprintf("%.2f", float64(result.ReclaimLatency.P99.Microseconds())/1000.0),
+			fmt.Sprintf("%.2f", float64(result.ProducerStats.AvgBrokerRTT.Microseconds())/1000.0),
+			strconv.FormatInt(result.ProducerStats.MaxQueueDepth, 10),
+			fmt.Sprintf("%.2f", result.ProducerStats.AvgBatchSize),
+			strconv.FormatInt(result.ProducerStats.Requests, 10),
+			strconv.FormatInt(result.ProducerStats.Retries, 10),
+			fmt.Sprintf("%.2f", float64(result.ConsumerStats.AvgBrokerRTT.Microseconds())/1000.0),
+			strconv.FormatInt(result.ConsumerStats.MaxQueueDepth, 10),
+			strconv.FormatInt(result.ConsumerStats.Requests, 10),
+			strconv.Itoa(len(result.PartitionStats)),
+			strconv.Itoa(result.IdlePartitions),
+			fmt.Sprintf("%.2f", result.PartitionSkew),
+			strconv.Itoa(result.IdleConsumers),
+			fmt.Sprintf("%.2f", result.ConsumerSkew),
 		}
 		if err := writer.Write(row); err != nil {
 			return fmt.Errorf("failed to write CSV row: %w", err)
@@ -91,10 +194,112 @@ func ExportToCSV(results []*common.BenchmarkResult, filename string) error {
 	return nil
 }
 
+// ExportClientStatsToCSV exports the client statistics time series of the
+// results to a CSV file, one row per sample
+func ExportClientStatsToCSV(results []*common.BenchmarkResult, filename string) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("failed to create CSV file: %w", err)
+	}
+	defer func() {
+		_ = file.Close() //nolint:errcheck // Best effort cleanup in defer
+	}()
+
+	writer := csv.NewWriter(file)
+	defer writer.Flush()
+
+	header := []string{
+		"Queue Type",
+		"Client",
+		"Instance",
+		"Time",
+		"Broker RTT (ms)",
+		"P99 Broker RTT (ms)",
+		"Queue Depth",
+		"Batch Size",
+		"Requests",
+		"Retries",
+	}
+	if err := writer.Write(header); err != nil {
+		return fmt.Errorf("failed to write CSV header: %w", err)
+	}
+
+	for _, result := range results {
+		for _, s := range result.ClientStats {
+			row := []string{
+				result.QueueType,
+				s.Role.String(),
+				s.Client,
+				s.Time.Format(time.RFC3339Nano),
+				fmt.Sprintf("%.2f", float64(s.BrokerRTT.Microseconds())/1000.0),
+				fmt.Sprintf("%.2f", float64(s.BrokerRTTP99.Microseconds())/1000.0),
+				strconv.FormatInt(s.QueueDepth, 10),
+				fmt.Sprintf("%.2f", s.BatchSize),
+				strconv.FormatInt(s.Requests, 10),
+				strconv.FormatInt(s.Retries, 10),
+			}
+			if err := writer.Write(row); err != nil {
+				return fmt.Errorf("failed to write CSV row: %w", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// ExportGroupMembersToCSV exports the consumer group members listed at the
+// end of each run to a CSV file, one row per member
+func ExportGroupMembersToCSV(results []*common.BenchmarkResult, filename string) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("failed to create CSV file: %w", err)
+	}
+	defer func() {
+		_ = file.Close() //nolint:errcheck // Best effort cleanup in defer
+	}()
+
+	writer := csv.NewWriter(file)
+	defer writer.Flush()
+
+	header := []string{
+		"Queue Type",
+		"Mode",
+		"Consumer",
+		"Pending",
+		"Idle (ms)",
+		"Inactive (ms)",
+	}
+	if err := writer.Write(header); err != nil {
+		return fmt.Errorf("failed to write CSV header: %w", err)
+	}
+
+	for _, result := range results {
+		for _, m := range result.GroupMembers {
+			row := []string{
+				result.QueueType,
+				result.Mode,
+				m.Name,
+				strconv.FormatInt(m.Pending, 10),
+				strconv.FormatInt(m.Idle.Milliseconds(), 10),
+				strconv.FormatInt(m.Inactive.Milliseconds(), 10),
+			}
+			if err := writer.Write(row); err != nil {
+				return fmt.Errorf("failed to write CSV row: %w", err)
+			}
+		}
+	}
+
+	return nil
+}
+
 // PrintResults prints benchmark results to console
 func PrintResults(result *common.BenchmarkResult) {
 	fmt.Println("\n" + strings.Repeat("=", 80))
-	fmt.Printf("Benchmark Results: %s\n", result.QueueType)
+	if result.Mode != "" {
+		fmt.Printf("Benchmark Results: %s (%s)\n", result.QueueType, result.Mode)
+	} else {
+		fmt.Printf("Benchmark Results: %s\n", result.QueueType)
+	}
 	fmt.Println(strings.Repeat("=", 80))
 	fmt.Printf("Messages:           %d\n", result.MessageCount)
 	fmt.Printf("Duration:           %v\n", result.Duration)
@@ -103,16 +308,237 @@ func PrintResults(result *common.BenchmarkResult) {
 	fmt.Printf("Success Count:      %d\n", result.SuccessCount)
 	fmt.Printf("Error Count:        %d\n", result.ErrorCount)
 	fmt.Printf("Bytes Processed:    %d (%.2f MB)\n", result.BytesProcessed, float64(result.BytesProcessed)/(1024*1024))
-	fmt.Println("\nLatency Statistics:")
+	if result.Mode == common.ModeReplay {
+		fmt.Println("\nLatency Statistics (from the start of the replay):")
+	} else {
+		fmt.Println("\nLatency Statistics:")
+	}
 	fmt.Printf("  Min:              %.2f ms\n", float64(result.MinLatency.Microseconds())/1000.0)
 	fmt.Printf("  Avg:              %.2f ms\n", float64(result.AvgLatency.Microseconds())/1000.0)
 	fmt.Printf("  P50:              %.2f ms\n", float64(result.P50Latency.Microseconds())/1000.0)
 	fmt.Printf("  P95:              %.2f ms\n", float64(result.P95Latency.Microseconds())/1000.0)
 	fmt.Printf("  P99:              %.2f ms\n", float64(result.P99Latency.Microseconds())/1000.0)
 	fmt.Printf("  Max:              %.2f ms\n", float64(result.MaxLatency.Microseconds())/1000.0)
+	if result.Mode == common.ModeReplay {
+		fmt.Printf("  First Message:    %.2f ms\n", float64(result.FirstMessageTime.Microseconds())/1000.0)
+	}
+	if result.AckLatency.Count+result.DwellTime.Count+result.ProcessingTime.Count > 0 {
+		fmt.Println("\nLatency Breakdown (avg / p50 / p95 / p99):")
+		printBreakdown("Ack", result.AckLatency)
+		printBreakdown("Dwell", result.DwellTime)
+		printBreakdown("Processing", result.ProcessingTime)
+	}
+	if result.Codec != "" {
+		fmt.Printf("\nSerialization (%s codec):\n", result.Codec)
+		printCodecTimes("Encode", result.EncodeTime)
+		printCodecTimes("Decode", result.DecodeTime)
+	}
+	if result.DeliveredCount+result.LostCount+result.UnexpectedCount > 0 {
+		fmt.Println("\nDelivery:")
+		fmt.Printf("  Delivered:        %d\n", result.DeliveredCount)
+		fmt.Printf("  Lost:             %d\n", result.LostCount)
+		fmt.Printf("  Duplicates:       %d\n", result.DuplicateCount)
+		fmt.Printf("  Unexpected:       %d\n", result.UnexpectedCount)
+	}
+	if result.InjectedFailureCount+result.RedeliveryCount+result.DeadLetterCount+result.DiscardedCount > 0 {
+		fmt.Println("\nFailure Handling:")
+		fmt.Printf("  Injected:         %d\n", result.InjectedFailureCount)
+		fmt.Printf("  Redeliveries:     %d\n", result.RedeliveryCount)
+		fmt.Printf("  Dead Lettered:    %d\n", result.DeadLetterCount)
+		fmt.Printf("  Discarded:        %d\n", result.DiscardedCount)
+		fmt.Printf("  Undecodable:      %d\n", result.UndecodableCount)
+	}
+	if result.SequencedCount > 0 {
+		printOrdering(result)
+	}
+	if result.TransactionCount+result.TransactionAbortCount > 0 {
+		fmt.Println("\nTransactions:")
+		fmt.Printf("  Committed:        %d\n", result.TransactionCount)
+		fmt.Printf("  Aborted:          %d\n", result.TransactionAbortCount)
+		if commit := result.TransactionCommitTime; commit.Count > 0 {
+			fmt.Printf("  Commit:           avg %.2f ms, p99 %.2f ms\n",
+				float64(commit.Avg.Microseconds())/1000.0, float64(commit.P99.Microseconds())/1000.0)
+		}
+	}
+	if result.OffsetCommitCount+result.OffsetCommitErrorCount > 0 {
+		fmt.Println("\nOffset Commits:")
+		fmt.Printf("  Commits:          %d\n", result.OffsetCommitCount)
+		fmt.Printf("  Errors:           %d\n", result.OffsetCommitErrorCount)
+		if commit := result.OffsetCommitLatency; commit.Count > 0 {
+			fmt.Printf("  Latency:          avg %.2f ms, p99 %.2f ms\n",
+				float64(commit.Avg.Microseconds())/1000.0, float64(commit.P99.Microseconds())/1000.0)
+		}
+	}
+	if len(result.RebalanceEvents) > 0 {
+		printRebalances(result)
+	}
+	if result.ReclaimedCount+result.ReclaimExhaustedCount > 0 {
+		fmt.Println("\nReclaimed Entries:")
+		fmt.Printf("  Reclaimed:        %d\n", result.ReclaimedCount)
+		fmt.Printf("  Exhausted:        %d\n", result.ReclaimExhaustedCount)
+		if reclaim := result.ReclaimLatency; reclaim.Count > 0 {
+			fmt.Printf("  Pending For:      avg %.2f ms, p99 %.2f ms, max %.2f ms\n",
+				float64(reclaim.Avg.Microseconds())/1000.0, float64(reclaim.P99.Microseconds())/1000.0,
+				float64(reclaim.Max.Microseconds())/1000.0)
+		}
+	}
+	if len(result.GroupMembers) > 0 {
+		printGroupMembers(result)
+	}
+	if result.ProducerStats.Samples+result.ConsumerStats.Samples > 0 {
+		fmt.Println("\nClient Statistics:")
+		printClientStats("Producer", result.ProducerStats)
+		printClientStats("Consumer", result.ConsumerStats)
+	}
+	if len(result.PartitionStats) > 0 || len(result.ConsumerMessages) > 1 {
+		printLoadBalance(result)
+	}
+	if len(result.ProducerConfig)+len(result.ConsumerConfig) > 0 {
+		fmt.Println("\nClient Config:")
+		printClientConfig("producer", result.ProducerConfig)
+		printClientConfig("consumer", result.ConsumerConfig)
+	}
 	fmt.Println(strings.Repeat("=", 80) + "\n")
 }
 
+// printBreakdown prints one stage of the latency breakdown, if it was measured
+func printBreakdown(label string, stats common.LatencyStats) {
+	if stats.Count == 0 {
+		return
+	}
+	fmt.Printf("  %-17s %.2f / %.2f / %.2f / %.2f ms\n", label+":",
+		float64(stats.Avg.Microseconds())/1000.0,
+		float64(stats.P50.Microseconds())/1000.0,
+		float64(stats.P95.Microseconds())/1000.0,
+		float64(stats.P99.Microseconds())/1000.0)
+}
+
+// printCodecTimes prints one line of per-message serialization cost, which is
+// small enough to need microsecond resolution
+func printCodecTimes(label string, stats common.LatencyStats) {
+	if stats.Count == 0 {
+		return
+	}
+	fmt.Printf("  %s:           avg %.2f us, p99 %.2f us (%d messages)\n", label,
+		float64(stats.Avg.Nanoseconds())/1000.0, float64(stats.P99.Nanoseconds())/1000.0, stats.Count)
+}
+
+// printOrdering prints the ordering counters and the first few violations
+func printOrdering(result *common.BenchmarkResult) {
+	fmt.Println("\nOrdering:")
+	fmt.Printf("  Sequenced:        %d\n", result.SequencedCount)
+	fmt.Printf("  Out Of Order:     %d\n", result.OutOfOrderCount)
+	fmt.Printf("  Key Out Of Order: %d\n", result.KeyOutOfOrderCount)
+	fmt.Printf("  Sequence Gaps:    %d\n", result.SequenceGapCount)
+
+	const maxPrinted = 5
+	for i, v := range result.OrderingViolations {
+		if i == maxPrinted {
+			fmt.Printf("  ... %d more recorded in the JSON report\n", len(result.OrderingViolations)-maxPrinted)
+			break
+		}
+		if v.Key != "" {
+			fmt.Printf("  %s: producer %d key %s expected %d, got %d\n", v.Kind, v.ProducerID, v.Key, v.Expected, v.Got)
+		} else {
+			fmt.Printf("  %s: producer %d expected %d, got %d\n", v.Kind, v.ProducerID, v.Expected, v.Got)
+		}
+	}
+}
+
+// printRebalances prints the rebalance totals and the first few events
+func printRebalances(result *common.BenchmarkResult) {
+	fmt.Println("\nRebalances:")
+	fmt.Printf("  Count:            %d\n", result.RebalanceCount)
+	fmt.Printf("  Paused:           %.2f ms\n", float64(result.RebalanceTime.Microseconds())/1000.0)
+
+	const maxPrinted = 10
+	for i, e := range result.RebalanceEvents {
+		if i == maxPrinted {
+			fmt.Printf("  ... %d more recorded in the JSON report\n", len(result.RebalanceEvents)-maxPrinted)
+			break
+		}
+		switch {
+		case e.Kind == "assign":
+			fmt.Printf("  %s assign %v after %.2f ms paused\n", e.Time.Format("15:04:05.000"), e.Partitions,
+				float64(e.Paused.Microseconds())/1000.0)
+		case e.Lost:
+			fmt.Printf("  %s revoke %v (lost)\n", e.Time.Format("15:04:05.000"), e.Partitions)
+		default:
+			fmt.Printf("  %s revoke %v\n", e.Time.Format("15:04:05.000"), e.Partitions)
+		}
+	}
+}
+
+// printGroupMembers prints the first few consumer group members
+func printGroupMembers(result *common.BenchmarkResult) {
+	fmt.Println("\nConsumer Group Members:")
+
+	const maxPrinted = 10
+	for i, m := range result.GroupMembers {
+		if i == maxPrinted {
+			fmt.Printf("  ... %d more recorded in the JSON report\n", len(result.GroupMembers)-maxPrinted)
+			break
+		}
+		fmt.Printf("  %-17s %d pending, idle %.2f s\n", m.Name+":", m.Pending, m.Idle.Seconds())
+	}
+}
+
+// printClientStats prints the statistics summary of one client, if it
+// reported any
+func printClientStats(client string, stats common.ClientStatsSummary) {
+	if stats.Samples == 0 {
+		return
+	}
+	fmt.Printf("  %-17s rtt avg %.2f ms, max p99 %.2f ms; queue avg %.0f, max %d\n", client+":",
+		float64(stats.AvgBrokerRTT.Microseconds())/1000.0, float64(stats.MaxBrokerRTT.Microseconds())/1000.0,
+		stats.AvgQueueDepth, stats.MaxQueueDepth)
+	fmt.Printf("  %-17s %d requests, %d retries", "", stats.Requests, stats.Retries)
+	if stats.AvgBatchSize > 0 {
+		fmt.Printf(", %.1f msgs per batch", stats.AvgBatchSize)
+	}
+	fmt.Printf(" (%d samples)\n", stats.Samples)
+}
+
+// printLoadBalance prints how the consumed messages spread over partitions
+// and consumer goroutines
+func printLoadBalance(result *common.BenchmarkResult) {
+	fmt.Println("\nLoad Balance:")
+	if len(result.PartitionStats) > 0 {
+		fmt.Printf("  Partitions:       %d (%d idle), skew %.2f\n",
+			len(result.PartitionStats), result.IdlePartitions, result.PartitionSkew)
+		for _, p := range result.PartitionStats {
+			if p.Messages == 0 {
+				fmt.Printf("  partition %-6d idle\n", p.Partition)
+				continue
+			}
+			fmt.Printf("  partition %-6d %d msgs, p50 %.2f ms, p95 %.2f ms, p99 %.2f ms\n", p.Partition, p.Messages,
+				float64(p.Latency.P50.Microseconds())/1000.0,
+				float64(p.Latency.P95.Microseconds())/1000.0,
+				float64(p.Latency.P99.Microseconds())/1000.0)
+		}
+	}
+	if len(result.ConsumerMessages) > 0 {
+		fmt.Printf("  Consumers:        %d (%d idle), skew %.2f\n",
+			len(result.ConsumerMessages), result.IdleConsumers, result.ConsumerSkew)
+		for id, n := range result.ConsumerMessages {
+			fmt.Printf("  consumer %-7d %d msgs\n", id, n)
+		}
+	}
+}
+
+// printClientConfig prints one client's settings in key order
+func printClientConfig(client string, config map[string]string) {
+	keys := make([]string, 0, len(config))
+	for key := range config {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		fmt.Printf("  %s %s=%s\n", client, key, config[key])
+	}
+}
+
 // CompareResults prints a comparison of multiple benchmark results
 func CompareResults(results []*common.BenchmarkResult) {
 	fmt.Println("\n" + strings.Repeat("=", 100))
@@ -131,6 +557,22 @@ func CompareResults(results []*common.BenchmarkResult) {
 		)
 	}
 
+	// Relative numbers answer "what does this setting cost", e.g. for
+	// kafka-txn against kafka
+	if len(results) > 1 && results[0].Throughput > 0 {
+		base := results[0]
+		fmt.Println(strings.Repeat("-", 100))
+		fmt.Printf("Relative to %s:\n", base.QueueType)
+		for _, result := range results[1:] {
+			fmt.Printf("  %-25s throughput %+.1f%%, avg latency %+.2f ms, p99 latency %+.2f ms\n",
+				result.QueueType,
+				(result.Throughput/base.Throughput-1)*100,
+				float64((result.AvgLatency-base.AvgLatency).Microseconds())/1000.0,
+				float64((result.P99Latency-base.P99Latency).Microseconds())/1000.0,
+			)
+		}
+	}
+
 	fmt.Println(strings.Repeat("=", 100) + "\n")
 }
 
@@ -152,5 +594,31 @@ func GenerateReport(results []*common.BenchmarkResult, outputDir string) error {
 	}
 	fmt.Printf("CSV report saved to: %s\n", csvFile)
 
+	// The client statistics time series, when any backend reported one
+	for _, result := range results {
+		if len(result.ClientStats) == 0 {
+			continue
+		}
+		statsFile := fmt.Sprintf("%s/benchmark-client-stats-%s.csv", outputDir, timestamp)
+		if err := ExportClientStatsToCSV(results, statsFile); err != nil {
+			return err
+		}
+		fmt.Printf("Client statistics saved to: %s\n", statsFile)
+		break
+	}
+
+	// The consumer group members, when any backend listed them
+	for _, result := range results {
+		if len(result.GroupMembers) == 0 {
+			continue
+		}
+		membersFile := fmt.Sprintf("%s/benchmark-group-members-%s.csv", outputDir, timestamp)
+		if err := ExportGroupMembersToCSV(results, membersFile); err != nil {
+			return err
+		}
+		fmt.Printf("Consumer group members saved to: %s\n", membersFile)
+		break
+	}
+
 	return nil
 }
@@ -1,6 +1,7 @@
 package metrics
 
 import (
+	"encoding/csv"
 	"os"
 	"path/filepath"
 	"testing"
@@ -202,3 +203,83 @@ func TestCompareResults(t *testing.T) {
 	// This should not panic
 	CompareResults(results)
 }
+
+func TestExportClientStatsToCSV(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "client-stats.csv")
+
+	start := time.Now()
+	results := []*common.BenchmarkResult{
+		{
+			QueueType: "Test Queue",
+			ClientStats: []common.ClientStatsSample{
+				{Time: start, Role: common.RoleProducer, BrokerRTT: time.Millisecond, Requests: 10},
+				{Time: start.Add(time.Second), Role: common.RoleConsumer, QueueDepth: 5, Requests: 4},
+			},
+		},
+		{QueueType: "Queue Without Stats"},
+	}
+
+	if err := ExportClientStatsToCSV(results, filename); err != nil {
+		t.Fatalf("ExportClientStatsToCSV failed: %v", err)
+	}
+
+	file, err := os.Open(filename)
+	if err != nil {
+		t.Fatalf("Failed to open CSV file: %v", err)
+	}
+	defer file.Close()
+
+	rows, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatalf("Failed to read CSV file: %v", err)
+	}
+
+	// Header plus one row per sample
+	if len(rows) != 3 {
+		t.Fatalf("Expected 3 rows, got %d", len(rows))
+	}
+
+	if rows[1][1] != "producer" || rows[2][1] != "consumer" {
+		t.Errorf("Expected producer then consumer rows, got %q and %q", rows[1][1], rows[2][1])
+	}
+}
+
+func TestExportGroupMembersToCSV(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "group-members.csv")
+
+	results := []*common.BenchmarkResult{
+		{
+			QueueType: "Test Queue",
+			Mode:      common.ModeFull,
+			GroupMembers: []common.GroupMember{
+				{Name: "consumer-1", Pending: 2, Idle: 1500 * time.Millisecond, Inactive: -time.Millisecond},
+				{Name: "consumer-2", Idle: 20 * time.Millisecond},
+			},
+		},
+		{QueueType: "Queue Without Group"},
+	}
+
+	if err := ExportGroupMembersToCSV(results, filename); err != nil {
+		t.Fatalf("ExportGroupMembersToCSV failed: %v", err)
+	}
+
+	file, err := os.Open(filename)
+	if err != nil {
+		t.Fatalf("Failed to open CSV file: %v", err)
+	}
+	defer file.Close()
+
+	rows, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatalf("Failed to read CSV file: %v", err)
+	}
+
+	// Header plus one row per member
+	if len(rows) != 3 {
+		t.Fatalf("Expected 3 rows, got %d", len(rows))
+	}
+
+	if rows[1][2] != "consumer-1" || rows[1][3] != "2" || rows[1][4] != "1500" || rows[1][5] != "-1" {
+		t.Errorf("Expected consumer-1 with 2 pending, idle 1500ms and never active, got %v", rows[1])
+	}
+}
@@ -0,0 +1,104 @@
+package metrics
+
+import (
+	"sync"
+
+	"github.com/praneethys/kafka-bullmq-benchmark/pkg/common"
+)
+
+// maxOrderingViolations caps how many violations are kept for the report;
+// the counters keep counting past it
+const maxOrderingViolations = 100
+
+// keyStream identifies the messages one producer sent with one key
+type keyStream struct {
+	producerID int
+	key        string
+}
+
+// orderingTracker checks the sequence numbers of consumed messages against
+// the order they were produced in. Each message is compared with the highest
+// sequence seen so far for its producer and for its producer and key: a
+// message arriving after a later one counts as out of order, and a jump ahead
+// in a producer's sequence counts as a gap. Redelivered messages show up as
+// out of order.
+type orderingTracker struct {
+	mu         sync.Mutex
+	producers  map[int]uint64
+	keys       map[keyStream]uint64
+	sequenced  int
+	outOfOrder int
+	keyOrder   int
+	gaps       int
+	violations []common.OrderingViolation
+}
+
+func newOrderingTracker() *orderingTracker {
+	return &orderingTracker{
+		producers: make(map[int]uint64),
+		keys:      make(map[keyStream]uint64),
+	}
+}
+
+// observe records a consumed message. Unsequenced messages are ignored.
+func (o *orderingTracker) observe(msg *common.Message) {
+	if msg.Sequence == 0 {
+		return
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.sequenced++
+
+	last := o.producers[msg.ProducerID]
+	switch {
+	case msg.Sequence <= last:
+		o.outOfOrder++
+		o.addViolation("out-of-order", msg.ProducerID, "", last+1, msg.Sequence)
+	case msg.Sequence > last+1:
+		o.gaps++
+		o.addViolation("gap", msg.ProducerID, "", last+1, msg.Sequence)
+	}
+	if msg.Sequence > last {
+		o.producers[msg.ProducerID] = msg.Sequence
+	}
+
+	if msg.Key == "" {
+		return
+	}
+
+	stream := keyStream{producerID: msg.ProducerID, key: msg.Key}
+	lastKey := o.keys[stream]
+	if msg.KeySequence <= lastKey {
+		o.keyOrder++
+		o.addViolation("key-out-of-order", msg.ProducerID, msg.Key, lastKey+1, msg.KeySequence)
+	} else {
+		o.keys[stream] = msg.KeySequence
+	}
+}
+
+func (o *orderingTracker) addViolation(kind string, producerID int, key string, expected, got uint64) {
+	if len(o.violations) >= maxOrderingViolations {
+		return
+	}
+	o.violations = append(o.violations, common.OrderingViolation{
+		Kind:       kind,
+		ProducerID: producerID,
+		Key:        key,
+		Expected:   expected,
+		Got:        got,
+	})
+}
+
+// apply copies the ordering counters into result
+func (o *orderingTracker) apply(result *common.BenchmarkResult) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	result.SequencedCount = o.sequenced
+	result.OutOfOrderCount = o.outOfOrder
+	result.KeyOutOfOrderCount = o.keyOrder
+	result.SequenceGapCount = o.gaps
+	result.OrderingViolations = append([]common.OrderingViolation(nil), o.violations...)
+}
@@ -0,0 +1,121 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/praneethys/kafka-bullmq-benchmark/pkg/common"
+)
+
+func sequenced(producerID int, seq uint64, key string, keySeq uint64) *common.Message {
+	return &common.Message{ProducerID: producerID, Sequence: seq, Key: key, KeySequence: keySeq}
+}
+
+func TestOrderingInOrder(t *testing.T) {
+	tracker := newOrderingTracker()
+
+	// Two producers interleaved, each in order
+	for seq := uint64(1); seq <= 5; seq++ {
+		tracker.observe(sequenced(0, seq, "", 0))
+		tracker.observe(sequenced(1, seq, "", 0))
+	}
+
+	var result common.BenchmarkResult
+	tracker.apply(&result)
+
+	if result.SequencedCount != 10 {
+		t.Errorf("Expected 10 sequenced messages, got %d", result.SequencedCount)
+	}
+
+	if result.OutOfOrderCount != 0 || result.SequenceGapCount != 0 || len(result.OrderingViolations) != 0 {
+		t.Errorf("Expected no violations, got %+v", result.OrderingViolations)
+	}
+}
+
+func TestOrderingOutOfOrderAndGap(t *testing.T) {
+	tracker := newOrderingTracker()
+
+	// 1, 3 (gap), 2 (late), 4
+	tracker.observe(sequenced(0, 1, "", 0))
+	tracker.observe(sequenced(0, 3, "", 0))
+	tracker.observe(sequenced(0, 2, "", 0))
+	tracker.observe(sequenced(0, 4, "", 0))
+
+	var result common.BenchmarkResult
+	tracker.apply(&result)
+
+	if result.SequenceGapCount != 1 {
+		t.Errorf("Expected 1 gap, got %d", result.SequenceGapCount)
+	}
+
+	if result.OutOfOrderCount != 1 {
+		t.Errorf("Expected 1 out-of-order delivery, got %d", result.OutOfOrderCount)
+	}
+
+	expected := []common.OrderingViolation{
+		{Kind: "gap", Expected: 2, Got: 3},
+		{Kind: "out-of-order", Expected: 4, Got: 2},
+	}
+	if len(result.OrderingViolations) != len(expected) {
+		t.Fatalf("Expected violations %+v, got %+v", expected, result.OrderingViolations)
+	}
+	for i, v := range expected {
+		if result.OrderingViolations[i] != v {
+			t.Errorf("Expected violation %d to be %+v, got %+v", i, v, result.OrderingViolations[i])
+		}
+	}
+}
+
+func TestOrderingPerKey(t *testing.T) {
+	tracker := newOrderingTracker()
+
+	// Keys stay in order even though the producer sequence does not
+	tracker.observe(sequenced(0, 2, "key-1", 1))
+	tracker.observe(sequenced(0, 1, "key-0", 1))
+	tracker.observe(sequenced(0, 4, "key-1", 2))
+	tracker.observe(sequenced(0, 3, "key-0", 2))
+
+	// A redelivery breaks per-key order too
+	tracker.observe(sequenced(0, 3, "key-0", 2))
+
+	var result common.BenchmarkResult
+	tracker.apply(&result)
+
+	if result.KeyOutOfOrderCount != 1 {
+		t.Errorf("Expected 1 key out-of-order delivery, got %d", result.KeyOutOfOrderCount)
+	}
+
+	if result.OutOfOrderCount != 3 {
+		t.Errorf("Expected 3 out-of-order deliveries, got %d", result.OutOfOrderCount)
+	}
+}
+
+func TestOrderingIgnoresUnsequenced(t *testing.T) {
+	tracker := newOrderingTracker()
+	tracker.observe(&common.Message{ID: "external"})
+
+	var result common.BenchmarkResult
+	tracker.apply(&result)
+
+	if result.SequencedCount != 0 {
+		t.Errorf("Expected 0 sequenced messages, got %d", result.SequencedCount)
+	}
+}
+
+func TestOrderingViolationsCapped(t *testing.T) {
+	tracker := newOrderingTracker()
+	tracker.observe(sequenced(0, 1000, "", 0))
+	for seq := uint64(1); seq <= 2*maxOrderingViolations; seq++ {
+		tracker.observe(sequenced(0, seq, "", 0))
+	}
+
+	var result common.BenchmarkResult
+	tracker.apply(&result)
+
+	if result.OutOfOrderCount != 2*maxOrderingViolations {
+		t.Errorf("Expected %d out-of-order deliveries, got %d", 2*maxOrderingViolations, result.OutOfOrderCount)
+	}
+
+	if len(result.OrderingViolations) != maxOrderingViolations {
+		t.Errorf("Expected %d recorded violations, got %d", maxOrderingViolations, len(result.OrderingViolations))
+	}
+}
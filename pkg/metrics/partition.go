@@ -0,0 +1,109 @@
+package metrics
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/praneethys/kafka-bullmq-benchmark/pkg/common"
+)
+
+// partitionTracker counts consumed messages by source partition and by
+// consumer goroutine, to show how evenly a run spread its load
+type partitionTracker struct {
+	mu         sync.Mutex
+	partitions map[int32][]time.Duration // end-to-end latencies by partition
+	consumers  []int
+}
+
+func newPartitionTracker(consumers int) *partitionTracker {
+	return &partitionTracker{
+		partitions: make(map[int32][]time.Duration),
+		consumers:  make([]int, consumers),
+	}
+}
+
+// observe records a message handled by consumerID with the given end-to-end
+// latency
+func (p *partitionTracker) observe(consumerID int, msg *common.Message, latency time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if consumerID >= 0 && consumerID < len(p.consumers) {
+		p.consumers[consumerID]++
+	}
+	if msg.Trace.Partitioned {
+		p.partitions[msg.Trace.Partition] = append(p.partitions[msg.Trace.Partition], latency)
+	}
+}
+
+// apply copies the per-partition and per-consumer counts into result. Known
+// partitions that received nothing are reported as idle; without them only
+// the partitions messages came from are known.
+func (p *partitionTracker) apply(result *common.BenchmarkResult, known []int32) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	ids := make(map[int32]bool, len(known)+len(p.partitions))
+	for _, id := range known {
+		ids[id] = true
+	}
+	for id := range p.partitions {
+		ids[id] = true
+	}
+
+	result.PartitionStats = nil
+	for id := range ids {
+		latencies := p.partitions[id]
+		result.PartitionStats = append(result.PartitionStats, common.PartitionStats{
+			Partition: id,
+			Messages:  len(latencies),
+			Latency:   summarize(latencies),
+		})
+	}
+	sort.Slice(result.PartitionStats, func(i, j int) bool {
+		return result.PartitionStats[i].Partition < result.PartitionStats[j].Partition
+	})
+
+	counts := make([]int, len(result.PartitionStats))
+	for i, stats := range result.PartitionStats {
+		counts[i] = stats.Messages
+	}
+	result.IdlePartitions, result.PartitionSkew = balance(counts)
+
+	result.ConsumerMessages = append([]int(nil), p.consumers...)
+	result.IdleConsumers, result.ConsumerSkew = balance(result.ConsumerMessages)
+}
+
+// balance returns how many of counts are zero and the largest count over the
+// mean, or 0 if there is nothing to compare
+func balance(counts []int) (idle int, skew float64) {
+	total, busiest := 0, 0
+	for _, n := range counts {
+		if n == 0 {
+			idle++
+		}
+		total += n
+		busiest = max(busiest, n)
+	}
+	if total == 0 {
+		return idle, 0
+	}
+	return idle, float64(busiest) * float64(len(counts)) / float64(total)
+}
+
+// knownPartitions returns the partitions queue consumes from, or nil if it
+// cannot tell
+func knownPartitions(queue common.MessageQueue) []int32 {
+	pr, ok := queue.(common.PartitionReporter)
+	if !ok {
+		return nil
+	}
+	partitions, err := pr.Partitions()
+	if err != nil {
+		fmt.Printf("Could not list partitions, idle ones are not reported: %v\n", err)
+		return nil
+	}
+	return partitions
+}
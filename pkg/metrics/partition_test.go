@@ -0,0 +1,63 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/praneethys/kafka-bullmq-benchmark/pkg/common"
+)
+
+func onPartition(partition int32) *common.Message {
+	return &common.Message{Trace: common.Trace{Partition: partition, Partitioned: true}}
+}
+
+func TestPartitionTracker(t *testing.T) {
+	tracker := newPartitionTracker(3)
+
+	// Partition 1 gets three messages, partition 0 one; consumer 2 idles
+	tracker.observe(0, onPartition(1), 10*time.Millisecond)
+	tracker.observe(0, onPartition(1), 20*time.Millisecond)
+	tracker.observe(1, onPartition(1), 30*time.Millisecond)
+	tracker.observe(1, onPartition(0), 5*time.Millisecond)
+
+	var result common.BenchmarkResult
+	tracker.apply(&result, []int32{0, 1, 2, 3})
+
+	if len(result.PartitionStats) != 4 {
+		t.Fatalf("Expected 4 partitions, got %d", len(result.PartitionStats))
+	}
+
+	if p := result.PartitionStats[1]; p.Partition != 1 || p.Messages != 3 || p.Latency.Max != 30*time.Millisecond {
+		t.Errorf("Expected partition 1 with 3 messages up to 30ms, got %+v", p)
+	}
+
+	if result.IdlePartitions != 2 {
+		t.Errorf("Expected 2 idle partitions, got %d", result.IdlePartitions)
+	}
+
+	// 3 messages against a mean of 1
+	if result.PartitionSkew != 3 {
+		t.Errorf("Expected partition skew 3, got %.2f", result.PartitionSkew)
+	}
+
+	if result.IdleConsumers != 1 || result.ConsumerSkew != 1.5 {
+		t.Errorf("Expected 1 idle consumer and skew 1.5, got %d and %.2f", result.IdleConsumers, result.ConsumerSkew)
+	}
+}
+
+func TestPartitionTrackerUnpartitioned(t *testing.T) {
+	tracker := newPartitionTracker(2)
+	tracker.observe(0, &common.Message{}, time.Millisecond)
+	tracker.observe(1, &common.Message{}, time.Millisecond)
+
+	var result common.BenchmarkResult
+	tracker.apply(&result, nil)
+
+	if result.PartitionStats != nil || result.PartitionSkew != 0 {
+		t.Errorf("Expected no partition stats, got %+v", result.PartitionStats)
+	}
+
+	if result.ConsumerSkew != 1 {
+		t.Errorf("Expected consumer skew 1, got %.2f", result.ConsumerSkew)
+	}
+}
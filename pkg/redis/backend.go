@@ -0,0 +1,86 @@
+package redis
+
+import (
+	"flag"
+
+	"github.com/google/uuid"
+	"github.com/praneethys/kafka-bullmq-benchmark/pkg/common"
+)
+
+func init() {
+	common.RegisterBackend(&backend{})
+}
+
+// backend exposes Redis Streams to the benchmark CLI through the common registry
+type backend struct {
+	addr             string
+	streamKey        string
+	deadLetterStream string
+	reclaim          ReclaimPolicy
+	connPerConsumer  bool
+	options          RedisOptions
+}
+
+func (b *backend) Name() string {
+	return "redis"
+}
+
+func (b *backend) RegisterFlags(fs *flag.FlagSet) {
+	fs.StringVar(&b.addr, "redis-addr", "localhost:6379", "Redis server address")
+	fs.StringVar(&b.streamKey, "redis-stream", "benchmark-stream", "Redis stream key")
+	fs.StringVar(&b.deadLetterStream, "redis-dlq-stream", "benchmark-stream-dlq",
+		"Redis stream for entries that failed every attempt (empty drops them)")
+
+	defaults := DefaultReclaimPolicy()
+	fs.DurationVar(&b.reclaim.MinIdle, "redis-reclaim-idle", defaults.MinIdle,
+		"Reclaim Redis entries left pending this long by any consumer (0 disables)")
+	fs.DurationVar(&b.reclaim.Interval, "redis-reclaim-interval", defaults.Interval,
+		"Time between the pending-entry reclaim passes of each Redis consumer")
+	fs.Int64Var(&b.reclaim.MaxDeliveries, "redis-max-deliveries", defaults.MaxDeliveries,
+		"Dead-letter reclaimed Redis entries delivered this often (0 means no limit)")
+	fs.BoolVar(&b.connPerConsumer, "redis-conn-per-consumer", false,
+		"Give each Redis consumer goroutine a connection of its own instead of a shared pool")
+
+	b.options.RegisterFlags(fs, "redis")
+}
+
+// redisOptions returns the connection flags, with the password taken from
+// the environment if the flag is not set
+func (b *backend) redisOptions() RedisOptions {
+	return b.options.WithEnvPassword()
+}
+
+// NewQueue creates a queue for the role in opts; only consumers join the
+// consumer group. Replay consumers get a group of their own, destroyed when
+// the queue is closed. Consumer names carry an ID of the run, so members
+// left in the group by earlier runs are told apart.
+func (b *backend) NewQueue(opts common.BackendOptions) (common.MessageQueue, error) {
+	var queue *RedisQueue
+	var err error
+	name := opts.Role.String() + "-" + uuid.New().String()[:8]
+	switch {
+	case opts.Role == common.RoleProducer:
+		queue, err = NewRedisProducerWithOptions(b.addr, b.streamKey, b.redisOptions())
+	case opts.Replay:
+		// New groups start at ID 0, the beginning of the stream
+		group := "benchmark-replay-" + uuid.New().String()
+		queue, err = NewRedisConsumerWithOptions(b.addr, b.streamKey, group, name, b.redisOptions())
+		if err == nil {
+			queue.destroyGroup = true
+		}
+	default:
+		queue, err = NewRedisConsumerWithOptions(b.addr, b.streamKey, "benchmark-group", name, b.redisOptions())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Codec != nil {
+		queue.SetCodec(opts.Codec)
+	}
+	queue.SetRetryPolicy(opts.Retry)
+	queue.SetDeadLetterStream(b.deadLetterStream)
+	queue.SetReclaimPolicy(b.reclaim)
+	queue.SetConnPerConsumer(b.connPerConsumer)
+	return queue, nil
+}
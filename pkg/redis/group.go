@@ -0,0 +1,79 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/praneethys/kafka-bullmq-benchmark/pkg/common"
+	"github.com/redis/go-redis/v9"
+)
+
+// groupConsumer is the identity and connection one Consume call reads the
+// stream with
+type groupConsumer struct {
+	name string
+	cmd  redis.Cmdable
+	// close releases a connection of its own, if the consumer has one
+	close func() error
+}
+
+// SetConnPerConsumer gives every Consume call a single-connection client of
+// its own instead of sharing the queue's connection pool, so busy consumers
+// do not wait for each other's connections
+func (r *RedisQueue) SetConnPerConsumer(enabled bool) {
+	r.connPerConsumer = enabled
+}
+
+// newGroupConsumer returns the group member for the next Consume call. Each
+// call is a distinct member named after the queue's consumer name and the
+// order of the call.
+func (r *RedisQueue) newGroupConsumer() groupConsumer {
+	c := groupConsumer{
+		name:  fmt.Sprintf("%s-%d", r.consumerName, r.consumers.Add(1)),
+		cmd:   r.client,
+		close: func() error { return nil },
+	}
+
+	if r.connPerConsumer {
+		// Calls of one consumer are sequential, a single connection does
+		opts := *r.client.Options()
+		opts.PoolSize = 1
+		client := redis.NewClient(&opts)
+		c.cmd = client
+		c.close = client.Close
+	}
+	return c
+}
+
+// GroupMembers lists the consumers of the group that joined through this
+// queue, as XINFO CONSUMERS reports them. Members left by other queues, such
+// as those of earlier runs, are not listed.
+func (r *RedisQueue) GroupMembers() ([]common.GroupMember, error) {
+	if r.consumerGroup == "" {
+		return nil, errNoConsumerGroup
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	consumers, err := r.client.XInfoConsumers(ctx, r.streamKey, r.consumerGroup).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list group consumers: %w", err)
+	}
+
+	var members []common.GroupMember
+	for _, c := range consumers {
+		if !strings.HasPrefix(c.Name, r.consumerName+"-") {
+			continue
+		}
+		members = append(members, common.GroupMember{
+			Name:     c.Name,
+			Pending:  c.Pending,
+			Idle:     c.Idle,
+			Inactive: c.Inactive,
+		})
+	}
+	return members, nil
+}
@@ -0,0 +1,111 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/praneethys/kafka-bullmq-benchmark/pkg/common"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestNewGroupConsumer(t *testing.T) {
+	client := redis.NewClient(&redis.Options{Addr: testAddr})
+	defer client.Close()
+	queue := &RedisQueue{client: client, consumerName: testConsumerName}
+
+	first, second := queue.newGroupConsumer(), queue.newGroupConsumer()
+	if first.name != testConsumerName+"-1" || second.name != testConsumerName+"-2" {
+		t.Errorf("Expected numbered consumer names, got %q and %q", first.name, second.name)
+	}
+	if first.cmd != client {
+		t.Error("Expected the consumer to share the queue's client")
+	}
+
+	queue.SetConnPerConsumer(true)
+	own := queue.newGroupConsumer()
+	if own.cmd == client {
+		t.Error("Expected a client of the consumer's own")
+	}
+	if err := own.close(); err != nil {
+		t.Errorf("Expected the consumer's client to close, got %v", err)
+	}
+}
+
+func TestRedisProducerGroupMembers(t *testing.T) {
+	if _, err := (&RedisQueue{}).GroupMembers(); !errors.Is(err, errNoConsumerGroup) {
+		t.Errorf("Expected errNoConsumerGroup, got %v", err)
+	}
+}
+
+func TestRedisGroupMembers(t *testing.T) {
+	skipIfNoRedis(t)
+
+	for _, connPerConsumer := range []bool{false, true} {
+		t.Run(fmt.Sprintf("conn per consumer %v", connPerConsumer), func(t *testing.T) {
+			streamKey := fmt.Sprintf("%s-members-%d", testStream, time.Now().UnixNano())
+			queue, err := NewRedisConsumer(testAddr, streamKey, testConsumerGroup, testConsumerName)
+			if err != nil {
+				t.Fatalf("Failed to create Redis queue: %v", err)
+			}
+			defer func() {
+				queue.client.Del(context.Background(), streamKey)
+				queue.Close()
+			}()
+			queue.SetConnPerConsumer(connPerConsumer)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			// A member of an earlier run, which is not listed
+			if err := queue.client.XGroupCreateConsumer(ctx, streamKey, testConsumerGroup, "stale-1").Err(); err != nil {
+				t.Fatalf("Failed to create stale consumer: %v", err)
+			}
+
+			// Each consumer handles one message and then stops, so both
+			// have read from the group
+			var wg sync.WaitGroup
+			for i := 0; i < 2; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					consumeCtx, stop := context.WithCancel(ctx)
+					defer stop()
+					_ = queue.Consume(consumeCtx, func(*common.Message) error { //nolint:errcheck // Returns nil on cancel
+						stop()
+						return nil
+					})
+				}()
+			}
+
+			for i := 0; i < 2; i++ {
+				msg := &common.Message{ID: fmt.Sprintf("msg-%d", i), Payload: []byte("data"), Timestamp: time.Now()}
+				if err := queue.Produce(ctx, msg); err != nil {
+					t.Fatalf("Failed to produce message: %v", err)
+				}
+			}
+			wg.Wait()
+
+			members, err := queue.GroupMembers()
+			if err != nil {
+				t.Fatalf("GroupMembers failed: %v", err)
+			}
+
+			var names []string
+			for _, m := range members {
+				names = append(names, m.Name)
+				if m.Pending != 0 {
+					t.Errorf("Expected no pending entries for %s, got %d", m.Name, m.Pending)
+				}
+			}
+			sort.Strings(names)
+			if fmt.Sprint(names) != fmt.Sprintf("[%s-1 %s-2]", testConsumerName, testConsumerName) {
+				t.Errorf("Expected a member per Consume call, got %v", names)
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package redis
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisOptions holds the connection security settings of a RedisQueue. The
+// zero value connects in plaintext as the default user without a password.
+type RedisOptions struct {
+	// ACL user and password; an empty Username authenticates the default
+	// user with Password, as AUTH <password> does
+	Username string
+	Password string
+	// TLS encrypts the connection. CAFile verifies the server instead of the
+	// system roots; CertFile and KeyFile authenticate the client.
+	TLS                bool
+	CAFile             string
+	CertFile           string
+	KeyFile            string
+	InsecureSkipVerify bool
+}
+
+// passwordEnv is read for the password when the password flag is not set,
+// which keeps it out of the process list
+const passwordEnv = "REDIS_PASSWORD"
+
+// RegisterFlags adds the credential and TLS flags to fs, named after prefix:
+// -<prefix>-username, -<prefix>-tls and so on. Every backend that connects to
+// Redis registers its own set.
+func (o *RedisOptions) RegisterFlags(fs *flag.FlagSet, prefix string) {
+	fs.StringVar(&o.Username, prefix+"-username", "", "Redis ACL username (empty uses the default user)")
+	fs.StringVar(&o.Password, prefix+"-password", "", "Redis password (defaults to $"+passwordEnv+")")
+	fs.BoolVar(&o.TLS, prefix+"-tls", false, "Connect to Redis over TLS")
+	fs.StringVar(&o.CAFile, prefix+"-tls-ca", "", "PEM file of the CA that signed the Redis server certificate")
+	fs.StringVar(&o.CertFile, prefix+"-tls-cert", "", "PEM file of the Redis client certificate, for mutual TLS")
+	fs.StringVar(&o.KeyFile, prefix+"-tls-key", "", "PEM file of the Redis client key, for mutual TLS")
+	fs.BoolVar(&o.InsecureSkipVerify, prefix+"-tls-insecure", false,
+		"Skip verification of the Redis server certificate")
+}
+
+// WithEnvPassword returns the options with the password taken from the
+// environment if none is set
+func (o RedisOptions) WithEnvPassword() RedisOptions {
+	if o.Password == "" {
+		o.Password = os.Getenv(passwordEnv)
+	}
+	return o
+}
+
+// tlsConfig builds the TLS configuration of the client, or nil without TLS
+func (o RedisOptions) tlsConfig() (*tls.Config, error) {
+	if !o.TLS {
+		if o.CAFile != "" || o.CertFile != "" || o.InsecureSkipVerify {
+			return nil, fmt.Errorf("the Redis CA, client certificate and skip-verify settings need TLS")
+		}
+		return nil, nil
+	}
+
+	config := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: o.InsecureSkipVerify, //nolint:gosec // Opt-in for self-signed test servers
+	}
+
+	if o.CAFile != "" {
+		pem, err := os.ReadFile(o.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read Redis CA file: %w", err)
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in Redis CA file %s", o.CAFile)
+		}
+	}
+
+	if (o.CertFile == "") != (o.KeyFile == "") {
+		return nil, fmt.Errorf("a Redis client certificate and key must be given together")
+	}
+	if o.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(o.CertFile, o.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load Redis client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return config, nil
+}
+
+// clientOptions returns the go-redis options of a client connecting to addr
+func (o RedisOptions) clientOptions(addr string) (*redis.Options, error) {
+	tlsConfig, err := o.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	return &redis.Options{
+		Addr:         addr,
+		Username:     o.Username,
+		Password:     o.Password,
+		TLSConfig:    tlsConfig,
+		PoolSize:     100,
+		MinIdleConns: 10,
+		MaxRetries:   3,
+	}, nil
+}
+
+// ReportedConfig describes the connection for the benchmark report, with the
+// password masked
+func (o RedisOptions) ReportedConfig(addr string) map[string]string {
+	config := map[string]string{
+		"addr": addr,
+		"tls":  strconv.FormatBool(o.TLS),
+	}
+	if o.Username != "" {
+		config["username"] = o.Username
+	}
+	if o.Password != "" {
+		config["password"] = "[redacted]"
+	}
+	if o.TLS && o.InsecureSkipVerify {
+		config["tls.insecure_skip_verify"] = "true"
+	}
+	if o.CertFile != "" {
+		config["tls.client_cert"] = o.CertFile
+	}
+	return config
+}
@@ -0,0 +1,147 @@
+package redis
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"flag"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeTestCert writes a self-signed certificate and its key as PEM files
+// and returns their paths
+func writeTestCert(t *testing.T) (certFile, keyFile string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "redis-test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+
+	dir := t.TempDir()
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatalf("Failed to write certificate: %v", err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatalf("Failed to write key: %v", err)
+	}
+	return certFile, keyFile
+}
+
+func TestRedisOptionsTLSConfig(t *testing.T) {
+	certFile, keyFile := writeTestCert(t)
+
+	config, err := RedisOptions{}.tlsConfig()
+	if err != nil || config != nil {
+		t.Errorf("Expected no TLS by default, got %v, %v", config, err)
+	}
+
+	opts := RedisOptions{TLS: true, CAFile: certFile, CertFile: certFile, KeyFile: keyFile}
+	config, err = opts.tlsConfig()
+	if err != nil {
+		t.Fatalf("tlsConfig failed: %v", err)
+	}
+
+	if config.RootCAs == nil || len(config.Certificates) != 1 {
+		t.Errorf("Expected the CA pool and one client certificate, got %+v", config)
+	}
+}
+
+func TestRedisOptionsTLSConfigErrors(t *testing.T) {
+	certFile, _ := writeTestCert(t)
+	missing := filepath.Join(t.TempDir(), "missing.pem")
+
+	tests := []struct {
+		name string
+		opts RedisOptions
+	}{
+		{name: "ca without tls", opts: RedisOptions{CAFile: certFile}},
+		{name: "missing ca", opts: RedisOptions{TLS: true, CAFile: missing}},
+		{name: "ca without certificates", opts: RedisOptions{TLS: true, CAFile: writeFile(t, "not a certificate")}},
+		{name: "cert without key", opts: RedisOptions{TLS: true, CertFile: certFile}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.opts.tlsConfig(); err == nil {
+				t.Error("Expected an error, got nil")
+			}
+		})
+	}
+}
+
+// writeFile writes content to a temporary file and returns its path
+func writeFile(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "file.pem")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	return path
+}
+
+func TestRedisOptionsClientOptions(t *testing.T) {
+	opts := RedisOptions{Username: "bench", Password: "s3cret", TLS: true, InsecureSkipVerify: true}
+
+	clientOptions, err := opts.clientOptions(testAddr)
+	if err != nil {
+		t.Fatalf("clientOptions failed: %v", err)
+	}
+
+	if clientOptions.Username != "bench" || clientOptions.Password != "s3cret" || clientOptions.TLSConfig == nil {
+		t.Errorf("Expected ACL credentials and TLS, got %+v", clientOptions)
+	}
+
+	reported := opts.ReportedConfig(testAddr)
+	if reported["password"] != "[redacted]" || reported["username"] != "bench" || reported["tls"] != "true" {
+		t.Errorf("Expected the user, TLS and a redacted password, got %v", reported)
+	}
+}
+
+func TestBackendPasswordFromEnv(t *testing.T) {
+	t.Setenv(passwordEnv, "from-env")
+
+	b := &backend{}
+	fs := flag.NewFlagSet("redis", flag.ContinueOnError)
+	b.RegisterFlags(fs)
+	if err := fs.Parse([]string{"-redis-username", "bench"}); err != nil {
+		t.Fatalf("Failed to parse flags: %v", err)
+	}
+
+	if opts := b.redisOptions(); opts.Password != "from-env" || opts.Username != "bench" {
+		t.Errorf("Expected user bench with the password from $%s, got %+v", passwordEnv, opts)
+	}
+
+	if err := fs.Set("redis-password", "from-flag"); err != nil {
+		t.Fatalf("Failed to set flag: %v", err)
+	}
+	if opts := b.redisOptions(); opts.Password != "from-flag" {
+		t.Errorf("Expected the password from the flag, got %q", opts.Password)
+	}
+}
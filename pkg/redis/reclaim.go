@@ -0,0 +1,151 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/praneethys/kafka-bullmq-benchmark/pkg/common"
+	"github.com/redis/go-redis/v9"
+)
+
+// reclaimBatch is the most pending entries a consumer claims per pass
+const reclaimBatch = 100
+
+// ReclaimPolicy controls how consumers take over entries that stay pending
+// in the group because their consumer died, or stopped before it could
+// acknowledge them. MinIdle must exceed the longest time a live consumer
+// spends on an entry, retries included, or entries are handled twice.
+type ReclaimPolicy struct {
+	// MinIdle is how long an entry must have been pending since its last
+	// delivery before it is claimed; 0 disables reclaiming
+	MinIdle time.Duration
+	// Interval is the time between the reclaim passes of each consumer
+	Interval time.Duration
+	// MaxDeliveries dead-letters an entry that has been delivered this
+	// often instead of delivering it again; 0 means no limit
+	MaxDeliveries int64
+}
+
+// DefaultReclaimPolicy returns the policy the benchmark uses unless told
+// otherwise
+func DefaultReclaimPolicy() ReclaimPolicy {
+	return ReclaimPolicy{
+		MinIdle:       30 * time.Second,
+		Interval:      5 * time.Second,
+		MaxDeliveries: 5,
+	}
+}
+
+// errMaxDeliveries is recorded as the dead-letter error of entries given up
+// on after MaxDeliveries
+var errMaxDeliveries = errors.New("entry reached the maximum number of deliveries")
+
+// reclaimLog records the entries a queue's consumers reclaimed
+type reclaimLog struct {
+	mu        sync.Mutex
+	reclaimed int64
+	exhausted int64
+	latencies []time.Duration
+}
+
+// record adds a claimed entry that had been pending for idle; idle is
+// negative if unknown
+func (l *reclaimLog) record(idle time.Duration, exhausted bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if exhausted {
+		l.exhausted++
+	} else {
+		l.reclaimed++
+	}
+	if idle >= 0 {
+		l.latencies = append(l.latencies, idle)
+	}
+}
+
+// SetReclaimPolicy controls how Consume takes over entries left pending by
+// any consumer of the group, this one included
+func (r *RedisQueue) SetReclaimPolicy(policy ReclaimPolicy) {
+	r.reclaim = policy
+}
+
+// ReclaimStats reports the pending entries Consume has reclaimed so far
+func (r *RedisQueue) ReclaimStats() common.ReclaimStats {
+	r.reclaims.mu.Lock()
+	defer r.reclaims.mu.Unlock()
+
+	return common.ReclaimStats{
+		Reclaimed: r.reclaims.reclaimed,
+		Exhausted: r.reclaims.exhausted,
+		Latencies: append([]time.Duration(nil), r.reclaims.latencies...),
+	}
+}
+
+// reclaimPending claims the entries of the group that have been pending for
+// at least MinIdle and handles them again. Entries already delivered
+// MaxDeliveries times are dead-lettered instead. XPENDING supplies how long
+// each entry was pending and how often it was delivered, since XAUTOCLAIM
+// resets the first and does not report the second.
+func (r *RedisQueue) reclaimPending(ctx, ackCtx context.Context, consumer groupConsumer, handler func(*common.Message) error) error {
+	pending, err := consumer.cmd.XPendingExt(ctx, &redis.XPendingExtArgs{
+		Stream: r.streamKey,
+		Group:  r.consumerGroup,
+		Idle:   r.reclaim.MinIdle,
+		Start:  "-",
+		End:    "+",
+		Count:  reclaimBatch,
+	}).Result()
+	if err != nil {
+		return fmt.Errorf("failed to list pending entries: %w", err)
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+
+	// Entries acknowledged or claimed by another consumer in the meantime
+	// are skipped by XAUTOCLAIM
+	entries, _, err := consumer.cmd.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+		Stream:   r.streamKey,
+		Group:    r.consumerGroup,
+		Consumer: consumer.name,
+		MinIdle:  r.reclaim.MinIdle,
+		Start:    pending[0].ID,
+		Count:    int64(len(pending)),
+	}).Result()
+	if err != nil {
+		return fmt.Errorf("failed to claim pending entries: %w", err)
+	}
+
+	received := time.Now()
+	byID := make(map[string]redis.XPendingExt, len(pending))
+	for _, p := range pending {
+		byID[p.ID] = p
+	}
+
+	for _, entry := range entries {
+		// Entries that became idle after XPENDING have unknown counts
+		info, ok := byID[entry.ID]
+		if !ok {
+			info.Idle = -1
+		}
+
+		if r.reclaim.MaxDeliveries > 0 && info.RetryCount >= r.reclaim.MaxDeliveries {
+			r.reclaims.record(info.Idle, true)
+			if r.deadLetter(ackCtx, consumer, entry, int(info.RetryCount), errMaxDeliveries) {
+				consumer.cmd.XAck(ackCtx, r.streamKey, r.consumerGroup, entry.ID)
+			}
+			continue
+		}
+
+		r.reclaims.record(info.Idle, false)
+		r.handleEntry(ctx, ackCtx, consumer, entry, received, handler)
+		if ctx.Err() != nil {
+			return nil
+		}
+	}
+	return nil
+}
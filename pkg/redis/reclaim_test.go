@@ -0,0 +1,141 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/praneethys/kafka-bullmq-benchmark/pkg/common"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestReclaimStats(t *testing.T) {
+	queue := &RedisQueue{}
+	queue.reclaims.record(2*time.Second, false)
+	queue.reclaims.record(-1, false)
+	queue.reclaims.record(3*time.Second, true)
+
+	stats := queue.ReclaimStats()
+	if stats.Reclaimed != 2 || stats.Exhausted != 1 {
+		t.Errorf("Expected 2 reclaimed and 1 exhausted entry, got %+v", stats)
+	}
+
+	// Entries of unknown idle time are counted but not timed
+	if len(stats.Latencies) != 2 || stats.Latencies[0] != 2*time.Second {
+		t.Errorf("Expected the 2 known pending times, got %v", stats.Latencies)
+	}
+}
+
+// crashedConsumer produces a message and reads it into the PEL of a
+// consumer that never acknowledges it, as if it had crashed
+func crashedConsumer(t *testing.T, streamKey string) *RedisQueue {
+	t.Helper()
+
+	crashed, err := NewRedisConsumer(testAddr, streamKey, testConsumerGroup, "crashed-consumer")
+	if err != nil {
+		t.Fatalf("Failed to create Redis queue: %v", err)
+	}
+	t.Cleanup(func() {
+		crashed.client.Del(context.Background(), streamKey, streamKey+"-dlq")
+		crashed.Close()
+	})
+
+	msg := &common.Message{ID: "orphan", Payload: []byte("orphan"), Timestamp: time.Now()}
+	if err := crashed.Produce(context.Background(), msg); err != nil {
+		t.Fatalf("Failed to produce message: %v", err)
+	}
+
+	streams, err := crashed.client.XReadGroup(context.Background(), &redis.XReadGroupArgs{
+		Group:    testConsumerGroup,
+		Consumer: "crashed-consumer",
+		Streams:  []string{streamKey, ">"},
+		Count:    1,
+	}).Result()
+	if err != nil || len(streams) != 1 || len(streams[0].Messages) != 1 {
+		t.Fatalf("Failed to read the message into the crashed consumer: %v", err)
+	}
+	return crashed
+}
+
+func TestRedisReclaimCrashedConsumer(t *testing.T) {
+	skipIfNoRedis(t)
+
+	streamKey := fmt.Sprintf("%s-reclaim-%d", testStream, time.Now().UnixNano())
+	crashedConsumer(t, streamKey)
+
+	queue, err := NewRedisConsumer(testAddr, streamKey, testConsumerGroup, testConsumerName)
+	if err != nil {
+		t.Fatalf("Failed to create Redis queue: %v", err)
+	}
+	defer queue.Close()
+	queue.SetReclaimPolicy(ReclaimPolicy{MinIdle: 100 * time.Millisecond, Interval: 50 * time.Millisecond, MaxDeliveries: 3})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var received *common.Message
+	_ = queue.Consume(ctx, func(msg *common.Message) error { //nolint:errcheck // Returns nil on cancel
+		received = msg
+		cancel()
+		return nil
+	})
+
+	if received == nil || received.ID != "orphan" {
+		t.Fatalf("Expected the crashed consumer's entry to be reclaimed, got %+v", received)
+	}
+
+	stats := queue.ReclaimStats()
+	if stats.Reclaimed != 1 || len(stats.Latencies) != 1 || stats.Latencies[0] < 100*time.Millisecond {
+		t.Errorf("Expected 1 entry reclaimed after at least 100ms pending, got %+v", stats)
+	}
+
+	pending, err := queue.client.XPending(context.Background(), streamKey, testConsumerGroup).Result()
+	if err != nil {
+		t.Fatalf("Failed to read pending entries: %v", err)
+	}
+	if pending.Count != 0 {
+		t.Errorf("Expected the reclaimed entry to be acknowledged, got %d pending", pending.Count)
+	}
+}
+
+func TestRedisReclaimMaxDeliveries(t *testing.T) {
+	skipIfNoRedis(t)
+
+	streamKey := fmt.Sprintf("%s-reclaim-max-%d", testStream, time.Now().UnixNano())
+	crashedConsumer(t, streamKey)
+
+	queue, err := NewRedisConsumer(testAddr, streamKey, testConsumerGroup, testConsumerName)
+	if err != nil {
+		t.Fatalf("Failed to create Redis queue: %v", err)
+	}
+	defer queue.Close()
+	queue.SetDeadLetterStream(streamKey + "-dlq")
+	// The crashed consumer's read was the only delivery allowed
+	queue.SetReclaimPolicy(ReclaimPolicy{MinIdle: 100 * time.Millisecond, Interval: 50 * time.Millisecond, MaxDeliveries: 1})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	handled := 0
+	_ = queue.Consume(ctx, func(*common.Message) error { //nolint:errcheck // Returns nil on cancel
+		handled++
+		return nil
+	})
+
+	if handled != 0 {
+		t.Errorf("Expected the entry not to be delivered again, got %d handler calls", handled)
+	}
+
+	if stats := queue.ReclaimStats(); stats.Exhausted != 1 || stats.Reclaimed != 0 {
+		t.Errorf("Expected 1 exhausted entry, got %+v", stats)
+	}
+
+	entries, err := queue.client.XRange(context.Background(), streamKey+"-dlq", "-", "+").Result()
+	if err != nil {
+		t.Fatalf("Failed to read dead-letter stream: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Values[headerFieldPrefix+common.HeaderDeadLetterError] != errMaxDeliveries.Error() {
+		t.Errorf("Expected the entry dead-lettered for its deliveries, got %v", entries)
+	}
+}
@@ -2,95 +2,318 @@ package redis
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
+	"maps"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/praneethys/kafka-bullmq-benchmark/pkg/common"
 	"github.com/redis/go-redis/v9"
 )
 
-// RedisQueue implements the MessageQueue interface using Redis Streams (BullMQ equivalent)
+// headerFieldPrefix marks stream entry fields that carry message headers
+const headerFieldPrefix = "header:"
+
+// RedisQueue implements the MessageQueue interface using plain Redis Streams
+// commands. See the bullmq package for BullMQ's job queue layout.
 type RedisQueue struct {
 	client        *redis.Client
+	config        map[string]string // connection settings, for the report
 	streamKey     string
 	consumerGroup string
-	consumerName  string
-	ctx           context.Context
-	cancel        context.CancelFunc
+	// consumerName is suffixed with the number of each Consume call to
+	// name the group members
+	consumerName string
+	codec        common.Codec
+	// destroyGroup removes the consumer group on Close, for groups that
+	// only this queue uses
+	destroyGroup bool
+
+	retry            common.RetryPolicy
+	deadLetterStream string
+	failures         common.FailureCounters
+
+	reclaim  ReclaimPolicy
+	reclaims reclaimLog
+
+	consumers       atomic.Int64 // Consume calls so far
+	connPerConsumer bool
 }
 
-// NewRedisQueue creates a new Redis queue instance using Redis Streams
+// errNoConsumerGroup is returned by Consume on a producer-only queue
+var errNoConsumerGroup = errors.New("queue was created without a consumer group")
+
+// NewRedisQueue creates a Redis queue that both produces to and consumes from
+// the stream, creating consumerGroup if needed
 func NewRedisQueue(addr, streamKey, consumerGroup, consumerName string) (*RedisQueue, error) {
-	client := redis.NewClient(&redis.Options{
-		Addr:         addr,
-		PoolSize:     100,
-		MinIdleConns: 10,
-		MaxRetries:   3,
-	})
+	return NewRedisConsumer(addr, streamKey, consumerGroup, consumerName)
+}
 
-	ctx, cancel := context.WithCancel(context.Background())
+// NewRedisProducer creates a Redis queue that only appends to the stream. It
+// neither creates nor joins a consumer group.
+func NewRedisProducer(addr, streamKey string) (*RedisQueue, error) {
+	return NewRedisProducerWithOptions(addr, streamKey, RedisOptions{})
+}
 
-	// Test connection
-	if err := client.Ping(ctx).Err(); err != nil {
-		cancel()
-		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
+// NewRedisProducerWithOptions is NewRedisProducer connecting with the
+// credentials and TLS settings in opts
+func NewRedisProducerWithOptions(addr, streamKey string, opts RedisOptions) (*RedisQueue, error) {
+	client, err := NewClient(addr, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RedisQueue{
+		client:    client,
+		config:    opts.ReportedConfig(addr),
+		streamKey: streamKey,
+		codec:     common.JSONCodec{},
+	}, nil
+}
+
+// NewRedisConsumer creates a Redis queue that reads the stream in
+// consumerGroup, creating the group if needed. Each Consume call joins the
+// group as a member of its own, named consumerName-1, consumerName-2 and so
+// on. Its client also writes to the dead-letter stream.
+func NewRedisConsumer(addr, streamKey, consumerGroup, consumerName string) (*RedisQueue, error) {
+	return NewRedisConsumerWithOptions(addr, streamKey, consumerGroup, consumerName, RedisOptions{})
+}
+
+// NewRedisConsumerWithOptions is NewRedisConsumer connecting with the
+// credentials and TLS settings in opts
+func NewRedisConsumerWithOptions(addr, streamKey, consumerGroup, consumerName string, opts RedisOptions) (*RedisQueue, error) {
+	client, err := NewClient(addr, opts)
+	if err != nil {
+		return nil, err
 	}
 
 	rq := &RedisQueue{
 		client:        client,
+		config:        opts.ReportedConfig(addr),
 		streamKey:     streamKey,
 		consumerGroup: consumerGroup,
 		consumerName:  consumerName,
-		ctx:           ctx,
-		cancel:        cancel,
+		codec:         common.JSONCodec{},
 	}
 
 	// Create consumer group (ignore error if already exists)
-	client.XGroupCreateMkStream(ctx, streamKey, consumerGroup, "0")
+	client.XGroupCreateMkStream(context.Background(), streamKey, consumerGroup, "0")
 
 	return rq, nil
 }
 
-// Produce sends a message to Redis Stream
-func (r *RedisQueue) Produce(msg *common.Message) error {
-	data, err := json.Marshal(msg)
+// NewClient connects to the Redis server at addr with the credentials and TLS
+// settings in opts. The ping also checks the credentials, since the client
+// authenticates every new connection.
+func NewClient(addr string, opts RedisOptions) (*redis.Client, error) {
+	clientOptions, err := opts.clientOptions(addr)
 	if err != nil {
-		return fmt.Errorf("failed to marshal message: %w", err)
+		return nil, err
+	}
+	client := redis.NewClient(clientOptions)
+
+	// Test connection
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		_ = client.Close() //nolint:errcheck // Best effort cleanup on error path
+		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
 	}
 
-	args := &redis.XAddArgs{
+	return client, nil
+}
+
+// ClientConfig returns the connection settings of the client, with the
+// password masked. Both roles share them.
+func (r *RedisQueue) ClientConfig(common.Role) map[string]string {
+	return r.config
+}
+
+// SetCodec changes the wire format used by Produce and Consume. Producers and
+// consumers of the same stream must use the same codec.
+func (r *RedisQueue) SetCodec(codec common.Codec) {
+	r.codec = codec
+}
+
+// Codec returns the wire format used by this queue
+func (r *RedisQueue) Codec() common.Codec {
+	return r.codec
+}
+
+// SetRetryPolicy controls how often Consume retries a failing handler
+func (r *RedisQueue) SetRetryPolicy(policy common.RetryPolicy) {
+	r.retry = policy
+}
+
+// SetDeadLetterStream sets the stream that receives entries whose handler
+// failed every attempt. An empty stream acknowledges and drops them instead.
+func (r *RedisQueue) SetDeadLetterStream(stream string) {
+	r.deadLetterStream = stream
+}
+
+// FailureStats reports the retries and dead-lettered entries seen by Consume
+func (r *RedisQueue) FailureStats() common.FailureStats {
+	return r.failures.Stats()
+}
+
+// newXAddArgs serializes msg into the stream entry appended for it and
+// records the time spent doing so in msg.Trace
+func (r *RedisQueue) newXAddArgs(msg *common.Message) (*redis.XAddArgs, error) {
+	start := time.Now()
+
+	env, err := r.codec.Encode(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]interface{}, len(env.Headers)+1)
+	values["payload"] = env.Value
+	for key, value := range env.Headers {
+		values[headerFieldPrefix+key] = value
+	}
+
+	msg.Trace.EncodeTime = time.Since(start)
+
+	return &redis.XAddArgs{
 		Stream: r.streamKey,
-		Values: map[string]interface{}{
-			"id":        msg.ID,
-			"payload":   data,
-			"timestamp": msg.Timestamp.Unix(),
-		},
+		Values: values,
+	}, nil
+}
+
+// decodeEntry deserializes a stream entry and records the time spent doing so
+// in the result's Trace
+func (r *RedisQueue) decodeEntry(entry redis.XMessage) (*common.Message, error) {
+	start := time.Now()
+
+	data, ok := entry.Values["payload"].(string)
+	if !ok {
+		return nil, fmt.Errorf("entry %s has no payload field", entry.ID)
+	}
+
+	env := &common.Envelope{Value: []byte(data)}
+	for field, value := range entry.Values {
+		key, ok := strings.CutPrefix(field, headerFieldPrefix)
+		if !ok {
+			continue
+		}
+		if env.Headers == nil {
+			env.Headers = make(map[string]string)
+		}
+		env.Headers[key], _ = value.(string)
+	}
+
+	msg, err := r.codec.Decode(env)
+	if err != nil {
+		return nil, err
+	}
+
+	msg.Trace.DecodeTime = time.Since(start)
+	return msg, nil
+}
+
+// Produce sends a message to Redis Stream
+func (r *RedisQueue) Produce(ctx context.Context, msg *common.Message) error {
+	args, err := r.newXAddArgs(msg)
+	if err != nil {
+		return err
 	}
 
-	if _, err := r.client.XAdd(r.ctx, args).Result(); err != nil {
+	if _, err := r.client.XAdd(ctx, args).Result(); err != nil {
 		return fmt.Errorf("failed to add message to stream: %w", err)
 	}
 
 	return nil
 }
 
+// ProduceBatch sends all messages to the Redis Stream in one pipelined round trip
+func (r *RedisQueue) ProduceBatch(ctx context.Context, msgs []*common.Message) error {
+	failed := 0
+	var firstErr error
+
+	pipe := r.client.Pipeline()
+	cmds := make([]*redis.StringCmd, 0, len(msgs))
+	for _, msg := range msgs {
+		args, err := r.newXAddArgs(msg)
+		if err != nil {
+			failed++
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		cmds = append(cmds, pipe.XAdd(ctx, args))
+	}
+
+	if len(cmds) > 0 {
+		// Exec reports the first failed command; count each one individually
+		_, _ = pipe.Exec(ctx) //nolint:errcheck // Per-command errors are checked below
+	}
+
+	for _, cmd := range cmds {
+		if err := cmd.Err(); err != nil {
+			failed++
+			if firstErr == nil {
+				firstErr = fmt.Errorf("failed to add message to stream: %w", err)
+			}
+		}
+	}
+
+	if failed > 0 {
+		return &common.BatchError{Failed: failed, Total: len(msgs), Err: firstErr}
+	}
+
+	return nil
+}
+
 // ProduceAsync sends a message to Redis Stream (same as Produce for Redis)
-func (r *RedisQueue) ProduceAsync(msg *common.Message) error {
-	return r.Produce(msg)
+func (r *RedisQueue) ProduceAsync(ctx context.Context, msg *common.Message) error {
+	return r.Produce(ctx, msg)
 }
 
 // Consume reads messages from Redis Stream and processes them with the provided handler
-func (r *RedisQueue) Consume(handler func(*common.Message) error) error {
+// until ctx is cancelled. A failing handler is retried according to the retry
+// policy, after which the entry is copied to the dead-letter stream and
+// acknowledged. Entries interrupted by cancellation stay pending in the group,
+// where the reclaim policy lets a consumer take them over. Concurrent calls
+// read as distinct members of the group.
+func (r *RedisQueue) Consume(ctx context.Context, handler func(*common.Message) error) error {
+	if r.consumerGroup == "" {
+		return errNoConsumerGroup
+	}
+
+	consumer := r.newGroupConsumer()
+	defer func() {
+		_ = consumer.close() //nolint:errcheck // Best effort, the consumer is done
+	}()
+
+	// Acks must still go out for entries handled just before cancellation,
+	// otherwise they would be left pending in the group
+	ackCtx := context.WithoutCancel(ctx)
+
+	// The first pass runs right away, so a consumer that replaces a crashed
+	// one picks up its entries first
+	var lastReclaim time.Time
+
 	for {
 		select {
-		case <-r.ctx.Done():
+		case <-ctx.Done():
 			return nil
 		default:
+			if r.reclaim.MinIdle > 0 && time.Since(lastReclaim) >= r.reclaim.Interval {
+				lastReclaim = time.Now()
+				if err := r.reclaimPending(ctx, ackCtx, consumer, handler); err != nil {
+					if ctx.Err() != nil {
+						return nil
+					}
+					return fmt.Errorf("reclaim error: %w", err)
+				}
+			}
+
 			// Read from consumer group
-			streams, err := r.client.XReadGroup(r.ctx, &redis.XReadGroupArgs{
+			streams, err := consumer.cmd.XReadGroup(ctx, &redis.XReadGroupArgs{
 				Group:    r.consumerGroup,
-				Consumer: r.consumerName,
+				Consumer: consumer.name,
 				Streams:  []string{r.streamKey, ">"},
 				Count:    10,
 				Block:    100 * time.Millisecond,
@@ -102,59 +325,111 @@ func (r *RedisQueue) Consume(handler func(*common.Message) error) error {
 				}
 				// Check if context is cancelled before returning error
 				select {
-				case <-r.ctx.Done():
+				case <-ctx.Done():
 					return nil
 				default:
 					return fmt.Errorf("consumer error: %w", err)
 				}
 			}
 
+			received := time.Now()
 			for _, stream := range streams {
 				for _, message := range stream.Messages {
-					var msg common.Message
-
-					if data, ok := message.Values["payload"].(string); ok {
-						if err := json.Unmarshal([]byte(data), &msg); err != nil {
-							continue
-						}
-					} else {
-						continue
-					}
-
-					if err := handler(&msg); err != nil {
-						continue
-					}
-
-					// Acknowledge the message
-					r.client.XAck(r.ctx, r.streamKey, r.consumerGroup, message.ID)
+					r.handleEntry(ctx, ackCtx, consumer, message, received, handler)
 				}
 			}
 		}
 	}
 }
 
-// Close closes the Redis client connection
-func (r *RedisQueue) Close() error {
-	// Cancel context to stop all consumers
-	r.cancel()
+// handleEntry passes a stream entry to handler and acknowledges it once
+// handled or dead-lettered. Entries that cannot be decoded are dead-lettered
+// without calling handler, since no retry would decode them.
+func (r *RedisQueue) handleEntry(ctx, ackCtx context.Context, consumer groupConsumer, entry redis.XMessage, received time.Time, handler func(*common.Message) error) {
+	msg, err := r.decodeEntry(entry)
+	if err != nil {
+		r.failures.AddUndecodable()
+		if r.deadLetter(ackCtx, consumer, entry, 0, err) {
+			consumer.cmd.XAck(ackCtx, r.streamKey, r.consumerGroup, entry.ID)
+		}
+		return
+	}
+
+	msg.Trace.ReceiveTime = received
+	msg.Trace.BrokerTime, _ = entryTime(entry.ID)
+
+	attempts, err := r.retry.Handle(ctx, msg, handler)
+	r.failures.AddAttempts(attempts)
+	if err != nil {
+		if ctx.Err() != nil {
+			return
+		}
+		if !r.deadLetter(ackCtx, consumer, entry, attempts, err) {
+			return
+		}
+	}
+
+	// Acknowledge the message
+	consumer.cmd.XAck(ackCtx, r.streamKey, r.consumerGroup, entry.ID)
+}
+
+// entryTime returns the time Redis assigned to a stream entry, which is the
+// millisecond part of its auto-generated ID
+func entryTime(id string) (time.Time, error) {
+	ms, _, _ := strings.Cut(id, "-")
+	n, err := strconv.ParseInt(ms, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid stream entry ID %q: %w", id, err)
+	}
+	return time.UnixMilli(n), nil
+}
+
+// deadLetter copies an entry whose handler failed every attempt to the
+// dead-letter stream, adding header fields that describe the failure. It
+// reports whether the entry can be acknowledged.
+func (r *RedisQueue) deadLetter(ctx context.Context, consumer groupConsumer, entry redis.XMessage, attempts int, cause error) bool {
+	if r.deadLetterStream == "" {
+		r.failures.AddDiscarded()
+		return true
+	}
 
-	// Give consumers a moment to exit gracefully
-	time.Sleep(200 * time.Millisecond)
+	values := maps.Clone(entry.Values)
+	values[headerFieldPrefix+common.HeaderDeadLetterError] = cause.Error()
+	values[headerFieldPrefix+common.HeaderDeadLetterAttempts] = attempts
+	values[headerFieldPrefix+common.HeaderDeadLetterSource] = r.streamKey
 
+	if err := consumer.cmd.XAdd(ctx, &redis.XAddArgs{Stream: r.deadLetterStream, Values: values}).Err(); err != nil {
+		// Leave the entry pending rather than lose it
+		return false
+	}
+
+	r.failures.AddDeadLettered()
+	return true
+}
+
+// Close closes the Redis client connection, first destroying a consumer
+// group created for this queue alone. Callers must cancel and wait for
+// every Consume call first.
+func (r *RedisQueue) Close() error {
+	if r.destroyGroup {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		_ = r.client.XGroupDestroy(ctx, r.streamKey, r.consumerGroup).Err() //nolint:errcheck // Best effort, a leftover group only costs server memory
+		cancel()
+	}
 	return r.client.Close()
 }
 
 // GetName returns the name of this queue implementation
 func (r *RedisQueue) GetName() string {
-	return "Redis Streams (BullMQ)"
+	return "Redis Streams"
 }
 
 // GetStreamInfo returns information about the stream
-func (r *RedisQueue) GetStreamInfo() (*redis.XInfoStream, error) {
-	return r.client.XInfoStream(r.ctx, r.streamKey).Result()
+func (r *RedisQueue) GetStreamInfo(ctx context.Context) (*redis.XInfoStream, error) {
+	return r.client.XInfoStream(ctx, r.streamKey).Result()
 }
 
 // TrimStream trims the stream to a maximum length
-func (r *RedisQueue) TrimStream(maxLen int64) error {
-	return r.client.XTrimMaxLen(r.ctx, r.streamKey, maxLen).Err()
+func (r *RedisQueue) TrimStream(ctx context.Context, maxLen int64) error {
+	return r.client.XTrimMaxLen(ctx, r.streamKey, maxLen).Err()
 }
@@ -1,11 +1,15 @@
 package redis
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"os"
 	"testing"
 	"time"
 
 	"github.com/praneethys/kafka-bullmq-benchmark/pkg/common"
+	"github.com/redis/go-redis/v9"
 )
 
 const (
@@ -22,6 +26,21 @@ func skipIfNoRedis(t *testing.T) {
 	}
 }
 
+func TestEntryTime(t *testing.T) {
+	ts, err := entryTime("1700000000123-4")
+	if err != nil {
+		t.Fatalf("entryTime failed: %v", err)
+	}
+
+	if !ts.Equal(time.UnixMilli(1700000000123)) {
+		t.Errorf("Expected %v, got %v", time.UnixMilli(1700000000123), ts)
+	}
+
+	if _, err := entryTime("not-an-id"); err == nil {
+		t.Error("Expected error for invalid entry ID, got nil")
+	}
+}
+
 func TestNewRedisQueue(t *testing.T) {
 	skipIfNoRedis(t)
 
@@ -59,6 +78,37 @@ func TestNewRedisQueueInvalidAddr(t *testing.T) {
 	}
 }
 
+func TestRedisProducerOnly(t *testing.T) {
+	skipIfNoRedis(t)
+
+	streamKey := testStream + "-producer-only"
+	queue, err := NewRedisProducer(testAddr, streamKey)
+	if err != nil {
+		t.Fatalf("Failed to create Redis producer: %v", err)
+	}
+	defer queue.Close()
+	defer queue.client.Del(context.Background(), streamKey)
+
+	msg := &common.Message{ID: "msg-1", Payload: []byte("payload"), Timestamp: time.Now()}
+	if err := queue.Produce(context.Background(), msg); err != nil {
+		t.Fatalf("Failed to produce message: %v", err)
+	}
+
+	groups, err := queue.client.XInfoGroups(context.Background(), streamKey).Result()
+	if err != nil {
+		t.Fatalf("Failed to read consumer groups: %v", err)
+	}
+
+	if len(groups) != 0 {
+		t.Errorf("Expected the producer to create no consumer group, got %+v", groups)
+	}
+
+	err = queue.Consume(context.Background(), func(*common.Message) error { return nil })
+	if !errors.Is(err, errNoConsumerGroup) {
+		t.Errorf("Expected errNoConsumerGroup, got %v", err)
+	}
+}
+
 func TestRedisGetName(t *testing.T) {
 	skipIfNoRedis(t)
 
@@ -69,8 +119,8 @@ func TestRedisGetName(t *testing.T) {
 	defer queue.Close()
 
 	name := queue.GetName()
-	if name != "Redis Streams (BullMQ)" {
-		t.Errorf("Expected name 'Redis Streams (BullMQ)', got '%s'", name)
+	if name != "Redis Streams" {
+		t.Errorf("Expected name 'Redis Streams', got '%s'", name)
 	}
 }
 
@@ -83,7 +133,7 @@ func TestRedisProduce(t *testing.T) {
 		t.Fatalf("Failed to create Redis queue: %v", err)
 	}
 	defer queue.Close()
-	defer queue.client.Del(queue.ctx, streamKey) // Cleanup
+	defer queue.client.Del(context.Background(), streamKey) // Cleanup
 
 	msg := &common.Message{
 		ID:        "test-msg-1",
@@ -91,13 +141,13 @@ func TestRedisProduce(t *testing.T) {
 		Timestamp: time.Now(),
 	}
 
-	err = queue.Produce(msg)
+	err = queue.Produce(context.Background(), msg)
 	if err != nil {
 		t.Errorf("Failed to produce message: %v", err)
 	}
 
 	// Verify message was added to stream
-	info, err := queue.GetStreamInfo()
+	info, err := queue.GetStreamInfo(context.Background())
 	if err != nil {
 		t.Fatalf("Failed to get stream info: %v", err)
 	}
@@ -116,7 +166,7 @@ func TestRedisProduceAsync(t *testing.T) {
 		t.Fatalf("Failed to create Redis queue: %v", err)
 	}
 	defer queue.Close()
-	defer queue.client.Del(queue.ctx, streamKey)
+	defer queue.client.Del(context.Background(), streamKey)
 
 	msg := &common.Message{
 		ID:        "test-msg-async-1",
@@ -124,13 +174,13 @@ func TestRedisProduceAsync(t *testing.T) {
 		Timestamp: time.Now(),
 	}
 
-	err = queue.ProduceAsync(msg)
+	err = queue.ProduceAsync(context.Background(), msg)
 	if err != nil {
 		t.Errorf("Failed to produce async message: %v", err)
 	}
 
 	// Verify message was added
-	info, err := queue.GetStreamInfo()
+	info, err := queue.GetStreamInfo(context.Background())
 	if err != nil {
 		t.Fatalf("Failed to get stream info: %v", err)
 	}
@@ -140,6 +190,205 @@ func TestRedisProduceAsync(t *testing.T) {
 	}
 }
 
+func TestRedisProduceBatch(t *testing.T) {
+	skipIfNoRedis(t)
+
+	streamKey := testStream + "-batch"
+	queue, err := NewRedisQueue(testAddr, streamKey, testConsumerGroup, testConsumerName)
+	if err != nil {
+		t.Fatalf("Failed to create Redis queue: %v", err)
+	}
+	defer queue.Close()
+	defer queue.client.Del(context.Background(), streamKey)
+
+	msgs := make([]*common.Message, 50)
+	for i := range msgs {
+		msgs[i] = &common.Message{
+			ID:        fmt.Sprintf("batch-msg-%d", i),
+			Payload:   []byte("batch payload"),
+			Timestamp: time.Now(),
+		}
+	}
+
+	if err := queue.ProduceBatch(context.Background(), msgs); err != nil {
+		t.Errorf("Failed to produce batch: %v", err)
+	}
+
+	info, err := queue.GetStreamInfo(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get stream info: %v", err)
+	}
+
+	if info.Length != int64(len(msgs)) {
+		t.Errorf("Expected %d messages in stream, got %d", len(msgs), info.Length)
+	}
+}
+
+func TestRedisCodecs(t *testing.T) {
+	skipIfNoRedis(t)
+
+	for _, name := range common.CodecNames() {
+		t.Run(name, func(t *testing.T) {
+			codec, _ := common.LookupCodec(name)
+			streamKey := testStream + "-codec-" + name
+
+			queue, err := NewRedisQueue(testAddr, streamKey, testConsumerGroup, testConsumerName)
+			if err != nil {
+				t.Fatalf("Failed to create Redis queue: %v", err)
+			}
+			defer queue.Close()
+			defer queue.client.Del(context.Background(), streamKey)
+			queue.SetCodec(codec)
+
+			msg := &common.Message{
+				ID:        "codec-" + name,
+				Payload:   []byte{0x00, 0xff, 0x10},
+				Timestamp: time.Now(),
+				Headers:   map[string]string{"trace-id": "abc"},
+			}
+			if err := queue.Produce(context.Background(), msg); err != nil {
+				t.Fatalf("Failed to produce message: %v", err)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			var received *common.Message
+			_ = queue.Consume(ctx, func(m *common.Message) error { //nolint:errcheck // Returns nil on cancel
+				received = m
+				cancel()
+				return nil
+			})
+
+			if received == nil {
+				t.Fatal("Timeout waiting for message")
+			}
+
+			if received.ID != msg.ID || string(received.Payload) != string(msg.Payload) {
+				t.Errorf("Expected message %s with payload %v, got %s with %v", msg.ID, msg.Payload, received.ID, received.Payload)
+			}
+
+			if received.Headers["trace-id"] != "abc" {
+				t.Errorf("Expected header trace-id=abc, got %v", received.Headers)
+			}
+
+			if received.Trace.DecodeTime <= 0 {
+				t.Errorf("Expected decode time to be recorded, got %v", received.Trace.DecodeTime)
+			}
+		})
+	}
+}
+
+func TestRedisDeadLetter(t *testing.T) {
+	skipIfNoRedis(t)
+
+	streamKey := testStream + "-dlq-source"
+	deadLetterStream := testStream + "-dlq"
+
+	queue, err := NewRedisQueue(testAddr, streamKey, testConsumerGroup, testConsumerName)
+	if err != nil {
+		t.Fatalf("Failed to create Redis queue: %v", err)
+	}
+	defer queue.Close()
+	defer queue.client.Del(context.Background(), streamKey, deadLetterStream)
+	queue.SetRetryPolicy(common.RetryPolicy{MaxAttempts: 3, Backoff: 10 * time.Millisecond})
+	queue.SetDeadLetterStream(deadLetterStream)
+
+	msg := &common.Message{ID: "dlq-msg", Payload: []byte("poison"), Timestamp: time.Now()}
+	if err := queue.Produce(context.Background(), msg); err != nil {
+		t.Fatalf("Failed to produce message: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	attempts := 0
+	_ = queue.Consume(ctx, func(*common.Message) error { //nolint:errcheck // Returns nil on cancel
+		attempts++
+		if attempts == 3 {
+			// Let Consume dead-letter and acknowledge the entry before stopping
+			time.AfterFunc(500*time.Millisecond, cancel)
+		}
+		return errors.New("handler failed")
+	})
+
+	stats := queue.FailureStats()
+	if stats.Redeliveries != 2 || stats.DeadLettered != 1 {
+		t.Errorf("Expected 2 redeliveries and 1 dead-lettered entry, got %+v", stats)
+	}
+
+	entries, err := queue.client.XRange(context.Background(), deadLetterStream, "-", "+").Result()
+	if err != nil {
+		t.Fatalf("Failed to read dead-letter stream: %v", err)
+	}
+
+	if len(entries) != 1 {
+		t.Fatalf("Expected 1 dead-lettered entry, got %d", len(entries))
+	}
+
+	if got := entries[0].Values[headerFieldPrefix+common.HeaderDeadLetterAttempts]; got != "3" {
+		t.Errorf("Expected %s=3, got %v", common.HeaderDeadLetterAttempts, got)
+	}
+
+	pending, err := queue.client.XPending(context.Background(), streamKey, testConsumerGroup).Result()
+	if err != nil {
+		t.Fatalf("Failed to read pending entries: %v", err)
+	}
+
+	if pending.Count != 0 {
+		t.Errorf("Expected the failed entry to be acknowledged, got %d pending", pending.Count)
+	}
+}
+
+func TestRedisUndecodableEntry(t *testing.T) {
+	skipIfNoRedis(t)
+
+	streamKey := fmt.Sprintf("%s-undecodable-%d", testStream, time.Now().UnixNano())
+	deadLetterStream := streamKey + "-dlq"
+
+	queue, err := NewRedisQueue(testAddr, streamKey, testConsumerGroup, testConsumerName)
+	if err != nil {
+		t.Fatalf("Failed to create Redis queue: %v", err)
+	}
+	defer queue.Close()
+	defer queue.client.Del(context.Background(), streamKey, deadLetterStream)
+	queue.SetDeadLetterStream(deadLetterStream)
+
+	// An entry without a payload field, as another client might write
+	if err := queue.client.XAdd(context.Background(), &redis.XAddArgs{Stream: streamKey, Values: map[string]interface{}{"body": "x"}}).Err(); err != nil {
+		t.Fatalf("Failed to add entry: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	handled := 0
+	_ = queue.Consume(ctx, func(*common.Message) error { //nolint:errcheck // Returns nil on cancel
+		handled++
+		return nil
+	})
+
+	if handled != 0 {
+		t.Errorf("Expected the handler not to be called, got %d calls", handled)
+	}
+
+	if stats := queue.FailureStats(); stats.Undecodable != 1 || stats.DeadLettered != 1 {
+		t.Errorf("Expected 1 undecodable entry dead-lettered, got %+v", stats)
+	}
+
+	if n := queue.client.XLen(context.Background(), deadLetterStream).Val(); n != 1 {
+		t.Errorf("Expected 1 dead-lettered entry, got %d", n)
+	}
+
+	pending, err := queue.client.XPending(context.Background(), streamKey, testConsumerGroup).Result()
+	if err != nil {
+		t.Fatalf("Failed to read pending entries: %v", err)
+	}
+	if pending.Count != 0 {
+		t.Errorf("Expected the entry to be acknowledged, got %d pending", pending.Count)
+	}
+}
+
 func TestRedisProduceAndConsume(t *testing.T) {
 	skipIfNoRedis(t)
 
@@ -152,7 +401,7 @@ func TestRedisProduceAndConsume(t *testing.T) {
 		t.Fatalf("Failed to create producer queue: %v", err)
 	}
 	defer producerQueue.Close()
-	defer producerQueue.client.Del(producerQueue.ctx, streamKey)
+	defer producerQueue.client.Del(context.Background(), streamKey)
 
 	// Produce test messages
 	testMessages := []*common.Message{
@@ -160,6 +409,7 @@ func TestRedisProduceAndConsume(t *testing.T) {
 			ID:        "msg-1",
 			Payload:   []byte("payload-1"),
 			Timestamp: time.Now(),
+			Headers:   map[string]string{"trace-id": "trace-1", "tenant-id": "acme"},
 		},
 		{
 			ID:        "msg-2",
@@ -174,7 +424,7 @@ func TestRedisProduceAndConsume(t *testing.T) {
 	}
 
 	for _, msg := range testMessages {
-		if prodErr := producerQueue.Produce(msg); prodErr != nil {
+		if prodErr := producerQueue.Produce(context.Background(), msg); prodErr != nil {
 			t.Fatalf("Failed to produce message %s: %v", msg.ID, prodErr)
 		}
 	}
@@ -192,14 +442,20 @@ func TestRedisProduceAndConsume(t *testing.T) {
 	// Consume messages
 	receivedCount := 0
 	receivedMessages := make(map[string]bool)
+	receivedHeaders := make(map[string]map[string]string)
 
-	done := make(chan bool)
+	ctx, cancel := context.WithCancel(context.Background())
+	consumerDone := make(chan struct{})
+
+	done := make(chan bool, 1)
 	go func() {
-		err := consumerQueue.Consume(func(msg *common.Message) error {
+		defer close(consumerDone)
+		err := consumerQueue.Consume(ctx, func(msg *common.Message) error {
 			receivedMessages[msg.ID] = true
+			receivedHeaders[msg.ID] = msg.Headers
 			receivedCount++
 
-			if receivedCount >= len(testMessages) {
+			if receivedCount == len(testMessages) {
 				done <- true
 			}
 			return nil
@@ -217,11 +473,21 @@ func TestRedisProduceAndConsume(t *testing.T) {
 		t.Errorf("Timeout waiting for messages. Received %d/%d", receivedCount, len(testMessages))
 	}
 
+	// Stop the consumer and wait for it before the deferred Close runs
+	cancel()
+	<-consumerDone
+
 	// Verify all messages were received
 	for _, msg := range testMessages {
 		if !receivedMessages[msg.ID] {
 			t.Errorf("Message %s was not received", msg.ID)
 		}
+
+		for key, value := range msg.Headers {
+			if got := receivedHeaders[msg.ID][key]; got != value {
+				t.Errorf("Message %s: expected header %s=%s, got %q", msg.ID, key, value, got)
+			}
+		}
 	}
 }
 
@@ -242,7 +508,7 @@ func TestRedisProduceInvalidMessage(t *testing.T) {
 	}
 
 	// This should not panic
-	_ = queue.Produce(msg) //nolint:errcheck // Intentionally testing edge case
+	_ = queue.Produce(context.Background(), msg) //nolint:errcheck // Intentionally testing edge case
 }
 
 func TestRedisGetStreamInfo(t *testing.T) {
@@ -254,7 +520,7 @@ func TestRedisGetStreamInfo(t *testing.T) {
 		t.Fatalf("Failed to create Redis queue: %v", err)
 	}
 	defer queue.Close()
-	defer queue.client.Del(queue.ctx, streamKey)
+	defer queue.client.Del(context.Background(), streamKey)
 
 	// Produce some messages
 	for i := 0; i < 5; i++ {
@@ -263,10 +529,10 @@ func TestRedisGetStreamInfo(t *testing.T) {
 			Payload:   []byte("test"),
 			Timestamp: time.Now(),
 		}
-		_ = queue.Produce(msg) //nolint:errcheck // Best effort in test setup
+		_ = queue.Produce(context.Background(), msg) //nolint:errcheck // Best effort in test setup
 	}
 
-	info, err := queue.GetStreamInfo()
+	info, err := queue.GetStreamInfo(context.Background())
 	if err != nil {
 		t.Fatalf("Failed to get stream info: %v", err)
 	}
@@ -285,7 +551,7 @@ func TestRedisTrimStream(t *testing.T) {
 		t.Fatalf("Failed to create Redis queue: %v", err)
 	}
 	defer queue.Close()
-	defer queue.client.Del(queue.ctx, streamKey)
+	defer queue.client.Del(context.Background(), streamKey)
 
 	// Produce 10 messages
 	for i := 0; i < 10; i++ {
@@ -294,17 +560,17 @@ func TestRedisTrimStream(t *testing.T) {
 			Payload:   []byte("test"),
 			Timestamp: time.Now(),
 		}
-		_ = queue.Produce(msg) //nolint:errcheck // Best effort in test setup
+		_ = queue.Produce(context.Background(), msg) //nolint:errcheck // Best effort in test setup
 	}
 
 	// Trim to 5 messages
-	err = queue.TrimStream(5)
+	err = queue.TrimStream(context.Background(), 5)
 	if err != nil {
 		t.Errorf("Failed to trim stream: %v", err)
 	}
 
 	// Verify stream length
-	info, err := queue.GetStreamInfo()
+	info, err := queue.GetStreamInfo(context.Background())
 	if err != nil {
 		t.Fatalf("Failed to get stream info: %v", err)
 	}
@@ -348,7 +614,7 @@ func TestRedisHighThroughput(t *testing.T) {
 		t.Fatalf("Failed to create Redis queue: %v", err)
 	}
 	defer queue.Close()
-	defer queue.client.Del(queue.ctx, streamKey)
+	defer queue.client.Del(context.Background(), streamKey)
 
 	messageCount := 1000
 	startTime := time.Now()
@@ -360,7 +626,7 @@ func TestRedisHighThroughput(t *testing.T) {
 			Payload:   make([]byte, 1024), // 1KB payload
 			Timestamp: time.Now(),
 		}
-		if prodErr := queue.ProduceAsync(msg); prodErr != nil {
+		if prodErr := queue.ProduceAsync(context.Background(), msg); prodErr != nil {
 			t.Errorf("Failed to produce message %d: %v", i, prodErr)
 		}
 	}
@@ -371,7 +637,7 @@ func TestRedisHighThroughput(t *testing.T) {
 	t.Logf("Produced %d messages in %v (%.2f msg/sec)", messageCount, duration, throughput)
 
 	// Verify all messages were added
-	info, err := queue.GetStreamInfo()
+	info, err := queue.GetStreamInfo(context.Background())
 	if err != nil {
 		t.Fatalf("Failed to get stream info: %v", err)
 	}
@@ -397,7 +663,7 @@ func TestRedisConsumerGroupHandling(t *testing.T) {
 		t.Fatalf("Failed to create first Redis queue: %v", err)
 	}
 	defer queue1.Close()
-	defer queue1.client.Del(queue1.ctx, streamKey)
+	defer queue1.client.Del(context.Background(), streamKey)
 
 	// Create second queue with same consumer group (should not error)
 	queue2, err := NewRedisQueue(testAddr, streamKey, groupName, testConsumerName+"-2")
@@ -413,11 +679,11 @@ func TestRedisConsumerGroupHandling(t *testing.T) {
 		Timestamp: time.Now(),
 	}
 
-	if err := queue1.Produce(msg); err != nil {
+	if err := queue1.Produce(context.Background(), msg); err != nil {
 		t.Errorf("Failed to produce with first queue: %v", err)
 	}
 
-	if err := queue2.Produce(msg); err != nil {
+	if err := queue2.Produce(context.Background(), msg); err != nil {
 		t.Errorf("Failed to produce with second queue: %v", err)
 	}
 }